@@ -0,0 +1,2 @@
+data "ios_inventory" "example" {
+}
@@ -1,6 +1,7 @@
 provider "ios" {
-  host     = "192.168.100.200"
-  port     = 22
-  username = "admin"
-  password = "MyStrongPassword"
-}
\ No newline at end of file
+  host                 = "192.168.100.200"
+  port                 = 22
+  username             = "admin"
+  password             = "MyStrongPassword"
+  host_key_fingerprint = "SHA256:..."
+}
@@ -0,0 +1,5 @@
+resource "ios_save_config" "example" {
+  triggers = {
+    vlan = ios_vlan.example.id
+  }
+}
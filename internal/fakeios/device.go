@@ -0,0 +1,412 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+// Package fakeios simulates a Cisco IOS device for the tests. The device keeps
+// a running-config that the configuration commands change, and answers the
+// commands the provider sends the way IOS does.
+package fakeios
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultConfig is the running-config of a freshly booted switch.
+const DefaultConfig = `version 15.2
+service timestamps debug datetime msec
+no service password-encryption
+!
+hostname Switch
+!
+no aaa new-model
+!
+spanning-tree mode pvst
+spanning-tree extend system-id
+!
+vlan internal allocation policy ascending
+!
+interface GigabitEthernet0/0
+ no switchport
+ ip address 192.168.0.2 255.255.255.0
+!
+interface GigabitEthernet0/1
+!
+interface GigabitEthernet0/2
+!
+interface GigabitEthernet0/3
+!
+interface GigabitEthernet1/0
+ no switchport
+ no ip address
+ shutdown
+!
+interface Vlan1
+ no ip address
+ shutdown
+!
+ip route 0.0.0.0 0.0.0.0 192.168.0.1
+!
+line con 0
+line vty 0 4
+ login local
+ transport input ssh
+!
+`
+
+const invalidInput = "% Invalid input detected at '^' marker."
+
+// sections are the commands entering a configuration sub-mode, with the
+// prompt of the sub-mode.
+var sections = []struct {
+	prefix string
+	mode   string
+}{
+	{"interface ", "config-if"},
+	{"vlan ", "config-vlan"},
+	{"router ", "config-router"},
+	{"line ", "config-line"},
+	{"ip access-list ", "config-ext-nacl"},
+}
+
+// singles are the commands holding a single value, a new value replaces the
+// previous one instead of being added next to it.
+var singles = []string{
+	"hostname ",
+	"description ",
+	"name ",
+	"switchport mode ",
+	"switchport access vlan ",
+	"switchport voice vlan ",
+	"switchport trunk encapsulation ",
+	"switchport trunk native vlan ",
+	"switchport trunk allowed vlan ",
+	"spanning-tree portfast",
+	"spanning-tree bpduguard ",
+}
+
+// section is a line of the running-config with the lines of its sub-mode.
+type section struct {
+	line     string
+	children []string
+}
+
+// Device is a simulated IOS device. Its methods are safe for concurrent use,
+// every session opened to the device shares the same running-config.
+type Device struct {
+	// EnableSecret makes the sessions start at privilege level 1, the enable
+	// command then asks for it to reach level 15.
+	EnableSecret string
+	// Version and Inventory are the outputs of show version and show
+	// inventory, which the device rejects while they are empty.
+	Version   string
+	Inventory string
+
+	mu       sync.Mutex
+	config   []*section
+	startup  string
+	flash    map[string][]*section
+	rejected []rejection
+	history  []string
+}
+
+// New returns a device running config, DefaultConfig when empty.
+func New(config string) *Device {
+	if config == "" {
+		config = DefaultConfig
+	}
+	return &Device{
+		config: parse(config),
+		flash:  map[string][]*section{},
+	}
+}
+
+// parse splits a configuration in sections, the indented lines belong to the
+// line above them.
+func parse(config string) []*section {
+	var parsed []*section
+	for _, line := range strings.Split(strings.ReplaceAll(config, "\r", ""), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "!" || trimmed == "end" {
+			continue
+		}
+		if line != trimmed && len(parsed) > 0 {
+			parsed[len(parsed)-1].children = append(parsed[len(parsed)-1].children, trimmed)
+			continue
+		}
+		parsed = append(parsed, &section{line: trimmed})
+	}
+	return parsed
+}
+
+func clone(config []*section) []*section {
+	cloned := make([]*section, len(config))
+	for i, s := range config {
+		cloned[i] = &section{line: s.line, children: append([]string(nil), s.children...)}
+	}
+	return cloned
+}
+
+// rejection is a command prefix the device refuses, with the message it
+// answers instead of the invalid input marker when set.
+type rejection struct {
+	prefix  string
+	message string
+}
+
+// Reject makes the device refuse the commands starting with prefix, in exec
+// or configuration mode, like a command the platform does not support.
+func (d *Device) Reject(prefix string) {
+	d.RejectWith(prefix, "")
+}
+
+// RejectWith makes the device refuse the commands starting with prefix with
+// message, like "% Incomplete command." or "%Error opening flash:x".
+func (d *Device) RejectWith(prefix string, message string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rejected = append(d.rejected, rejection{prefix: prefix, message: message})
+}
+
+// Accept makes the device run again the commands starting with prefix it was
+// told to reject.
+func (d *Device) Accept(prefix string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rejected = slices.DeleteFunc(d.rejected, func(r rejection) bool { return r.prefix == prefix })
+}
+
+// History returns the configuration commands the device received, in order.
+func (d *Device) History() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.history...)
+}
+
+// RunningConfig returns the running-config as shown by show running-config,
+// without the header.
+func (d *Device) RunningConfig() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return render(d.config)
+}
+
+// StartupConfig returns the configuration last saved with copy
+// running-config startup-config.
+func (d *Device) StartupConfig() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.startup
+}
+
+func render(config []*section) string {
+	var b strings.Builder
+	b.WriteString("!\n")
+	for i, s := range config {
+		b.WriteString(s.line + "\n")
+		for _, child := range s.children {
+			b.WriteString(" " + child + "\n")
+		}
+		if len(s.children) > 0 || isSection(s.line) || i+1 == len(config) || isSection(config[i+1].line) {
+			b.WriteString("!\n")
+		}
+	}
+	b.WriteString("end\n")
+	return b.String()
+}
+
+func (d *Device) hostname() string {
+	for _, s := range d.config {
+		if strings.HasPrefix(s.line, "hostname ") {
+			return strings.TrimPrefix(s.line, "hostname ")
+		}
+	}
+	return "Router"
+}
+
+func isSection(line string) bool {
+	return sectionMode(line) != ""
+}
+
+func sectionMode(line string) string {
+	for _, s := range sections {
+		if !strings.HasPrefix(line, s.prefix) {
+			continue
+		}
+		// vlan is also the prefix of global commands like vlan internal
+		// allocation policy.
+		if s.prefix == "vlan " && strings.Trim(strings.TrimPrefix(line, s.prefix), "0123456789,-") != "" {
+			continue
+		}
+		return s.mode
+	}
+	return ""
+}
+
+func (d *Device) find(line string) *section {
+	for _, s := range d.config {
+		if s.line == line {
+			return s
+		}
+	}
+	return nil
+}
+
+// enter returns the section of line, created when missing.
+func (d *Device) enter(line string) *section {
+	s := d.find(line)
+	if s == nil {
+		s = &section{line: line}
+		d.insert(s)
+	}
+	return s
+}
+
+// insert adds a line to the running-config after the lines of the same
+// kind, or before the line sections closing the configuration.
+func (d *Device) insert(s *section) {
+	kind := strings.Join(strings.Fields(s.line)[:min(2, len(strings.Fields(s.line)))], " ")
+	if isSection(s.line) {
+		kind = strings.Fields(s.line)[0]
+	}
+	at := -1
+	for i, existing := range d.config {
+		if strings.HasPrefix(existing.line, kind+" ") && isSection(existing.line) == isSection(s.line) {
+			at = i + 1
+		} else if at < 0 && strings.HasPrefix(existing.line, "line ") {
+			at = i
+			break
+		}
+	}
+	if at < 0 {
+		at = len(d.config)
+	}
+	d.config = append(d.config[:at], append([]*section{s}, d.config[at:]...)...)
+}
+
+// remove deletes the top level lines matching cmd, or starting with it.
+func (d *Device) remove(cmd string) {
+	kept := d.config[:0]
+	for _, s := range d.config {
+		if !matches(s.line, cmd) {
+			kept = append(kept, s)
+		}
+	}
+	d.config = kept
+}
+
+func matches(line string, cmd string) bool {
+	return line == cmd || strings.HasPrefix(line, cmd+" ")
+}
+
+// set applies a command to the lines of a section, or to the top level lines
+// when lines are the global configuration.
+func set(lines []string, cmd string) []string {
+	if strings.HasPrefix(cmd, "no ") {
+		negated := strings.TrimPrefix(cmd, "no ")
+		kept := lines[:0]
+		for _, line := range lines {
+			if !matches(line, negated) {
+				kept = append(kept, line)
+			}
+		}
+		return kept
+	}
+	if strings.HasPrefix(cmd, "switchport trunk allowed vlan add ") {
+		for i, line := range lines {
+			if strings.HasPrefix(line, "switchport trunk allowed vlan ") {
+				lines[i] = line + "," + strings.TrimPrefix(cmd, "switchport trunk allowed vlan add ")
+				return lines
+			}
+		}
+		return append(lines, "switchport trunk allowed vlan "+strings.TrimPrefix(cmd, "switchport trunk allowed vlan add "))
+	}
+	if strings.HasPrefix(cmd, "ip address ") && !strings.HasSuffix(cmd, " secondary") {
+		// The primary address replaces the previous primary address.
+		for i, line := range lines {
+			if strings.HasPrefix(line, "ip address ") && !strings.HasSuffix(line, " secondary") || line == "no ip address" {
+				lines[i] = cmd
+				return lines
+			}
+		}
+	}
+	for _, single := range singles {
+		if !strings.HasPrefix(cmd, single) {
+			continue
+		}
+		for i, line := range lines {
+			if strings.HasPrefix(line, single) {
+				lines[i] = cmd
+				return lines
+			}
+		}
+	}
+	for _, line := range lines {
+		if line == cmd {
+			return lines
+		}
+	}
+	return append(lines, cmd)
+}
+
+// rejects returns the output of the device refusing cmd, or false when it
+// accepts it. indent is the width of the prompt the caret points under.
+func (d *Device) rejects(cmd string, indent int) (string, bool) {
+	for _, rejection := range d.rejected {
+		if !strings.HasPrefix(cmd, rejection.prefix) {
+			continue
+		}
+		if rejection.message != "" {
+			return rejection.message, true
+		}
+		return strings.Repeat(" ", indent) + "^\n" + invalidInput, true
+	}
+	return "", false
+}
+
+// showVlan renders show vlan for the vlans of the running-config, along with
+// the default vlans.
+func (d *Device) showVlan() string {
+	names := map[int]string{
+		1:    "default",
+		1002: "fddi-default",
+		1003: "token-ring-default",
+		1004: "fddinet-default",
+		1005: "trnet-default",
+	}
+	for _, s := range d.config {
+		if !strings.HasPrefix(s.line, "vlan ") {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(s.line, "vlan "))
+		if err != nil {
+			continue
+		}
+		names[id] = fmt.Sprintf("VLAN%04d", id)
+		for _, child := range s.children {
+			if strings.HasPrefix(child, "name ") {
+				names[id] = strings.TrimPrefix(child, "name ")
+			}
+		}
+	}
+	ids := make([]int, 0, len(names))
+	for id := range names {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	var b strings.Builder
+	b.WriteString("\nVLAN Name                             Status    Ports\n")
+	b.WriteString("---- -------------------------------- --------- -------------------------------\n")
+	for _, id := range ids {
+		status := "active"
+		if id >= 1002 && id <= 1005 {
+			status = "act/unsup"
+		}
+		fmt.Fprintf(&b, "%-4d %-32s %-9s \n", id, names[id], status)
+	}
+	return b.String()
+}
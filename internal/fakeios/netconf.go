@@ -0,0 +1,256 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package fakeios
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"terraform-provider-ios/internal/native"
+)
+
+const (
+	netconfBase   = "urn:ietf:params:xml:ns:netconf:base:1.0"
+	netconfBase11 = "urn:ietf:params:netconf:base:1.1"
+	endOfMessage  = "]]>]]>"
+)
+
+// netconfSessions numbers the NETCONF sessions of all the servers.
+var netconfSessions atomic.Int64
+
+// netconfRPC is an rpc sent by a client, with the parts of the operations the
+// server understands.
+type netconfRPC struct {
+	MessageID string `xml:"message-id,attr"`
+	Operation struct {
+		XMLName xml.Name
+		Target  datastore `xml:"target"`
+		Source  datastore `xml:"source"`
+		Config  struct {
+			Inner []byte `xml:",innerxml"`
+		} `xml:"config"`
+	} `xml:",any"`
+}
+
+// datastore is the target or source of an operation.
+type datastore struct {
+	Running   *struct{} `xml:"running"`
+	Candidate *struct{} `xml:"candidate"`
+}
+
+func (d datastore) String() string {
+	if d.Candidate != nil {
+		return "candidate"
+	}
+	return "running"
+}
+
+// rpcError is the rpc-error a server replies with.
+type rpcError struct {
+	tag     string
+	message string
+}
+
+// Reject makes the validation and the commit of the candidate datastore, and
+// the last edit of a YANG-Patch, fail with message. An empty message accepts
+// them again.
+func (s *Store) Reject(message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reject = message
+}
+
+// netconf runs the NETCONF server of the store on channel until the client
+// closes its session.
+func (s *Server) netconf(channel io.ReadWriter) {
+	id := netconfSessions.Add(1)
+	hello := `<hello xmlns="` + netconfBase + `"><capabilities>` +
+		`<capability>urn:ietf:params:netconf:base:1.0</capability>` +
+		`<capability>` + netconfBase11 + `</capability>` +
+		`<capability>urn:ietf:params:netconf:capability:candidate:1.0</capability>` +
+		`<capability>urn:ietf:params:netconf:capability:validate:1.1</capability>` +
+		`<capability>urn:ietf:params:netconf:capability:writable-running:1.0</capability>` +
+		`</capabilities><session-id>` + strconv.FormatInt(id, 10) + `</session-id></hello>`
+	_, err := io.WriteString(channel, hello+endOfMessage)
+	if err != nil {
+		return
+	}
+	reader := bufio.NewReader(channel)
+	data, err := readMessage(reader, false)
+	if err != nil {
+		return
+	}
+	chunked := bytes.Contains(data, []byte(netconfBase11))
+	// The locks of a session are released, and the changes to the candidate
+	// discarded, when it ends.
+	defer s.Store.release(id)
+
+	for {
+		data, err := readMessage(reader, chunked)
+		if err != nil {
+			return
+		}
+		rpc := netconfRPC{}
+		var reply string
+		if err := xml.Unmarshal(data, &rpc); err != nil {
+			reply = errorReply(rpcError{"malformed-message", err.Error()})
+		} else {
+			reply = s.Store.operation(id, &rpc)
+		}
+		reply = `<rpc-reply message-id="` + rpc.MessageID + `" xmlns="` + netconfBase + `">` + reply + `</rpc-reply>`
+		if chunked {
+			_, err = fmt.Fprintf(channel, "\n#%d\n%s\n##\n", len(reply), reply)
+		} else {
+			_, err = io.WriteString(channel, reply+endOfMessage)
+		}
+		if err != nil || rpc.Operation.XMLName.Local == "close-session" {
+			return
+		}
+	}
+}
+
+// operation runs the operation of an rpc for the session id, and returns the
+// content of the reply.
+func (s *Store) operation(id int64, rpc *netconfRPC) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op := rpc.Operation
+	name := op.XMLName.Local
+	switch name {
+	case "get-config":
+		tree := s.running
+		if op.Source.Candidate != nil {
+			tree = s.candidateTree()
+		}
+		return "<data>" + string(native.EncodeXML(tree)) + "</data>"
+	case "edit-config":
+		target := op.Target.String()
+		if owner := s.locks[target]; owner != 0 && owner != id {
+			return errorReply(rpcError{"in-use", "the " + target + " datastore is locked by session " + strconv.FormatInt(owner, 10)})
+		}
+		patch, err := native.DecodeEditXML(bytes.TrimSpace(op.Config.Inner))
+		if err != nil {
+			return errorReply(rpcError{"malformed-message", err.Error()})
+		}
+		s.edits = append(s.edits, name+" "+target)
+		if target == "candidate" {
+			native.Apply(s.candidateTree(), patch)
+		} else {
+			native.Apply(s.running, patch)
+		}
+	case "validate", "commit":
+		s.edits = append(s.edits, name)
+		if s.reject != "" {
+			return errorReply(rpcError{"operation-failed", s.reject})
+		}
+		if name == "commit" && s.candidate != nil {
+			s.running = s.candidate
+			s.candidate = nil
+		}
+	case "discard-changes":
+		s.edits = append(s.edits, name)
+		s.candidate = nil
+	case "lock":
+		target := op.Target.String()
+		if owner := s.locks[target]; owner != 0 {
+			return errorReply(rpcError{"lock-denied", "the " + target + " datastore is locked by session " + strconv.FormatInt(owner, 10)})
+		}
+		if s.locks == nil {
+			s.locks = map[string]int64{}
+		}
+		s.locks[target] = id
+	case "unlock":
+		target := op.Target.String()
+		if s.locks[target] != id {
+			return errorReply(rpcError{"operation-failed", "the " + target + " datastore is not locked by this session"})
+		}
+		delete(s.locks, target)
+	case "save-config":
+		s.startup = s.running.Clone()
+		return `<result xmlns="http://cisco.com/yang/cisco-ia">Save running config successful</result>`
+	case "close-session":
+	default:
+		return errorReply(rpcError{"operation-not-supported", name + " is not supported"})
+	}
+	return "<ok/>"
+}
+
+// candidateTree returns the candidate datastore, a copy of the running one
+// until it is edited.
+func (s *Store) candidateTree() *native.Node {
+	if s.candidate == nil {
+		s.candidate = s.running.Clone()
+	}
+	return s.candidate
+}
+
+// release releases the locks of the session id, discarding the changes to the
+// candidate datastore it held.
+func (s *Store) release(id int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for target, owner := range s.locks {
+		if owner != id {
+			continue
+		}
+		delete(s.locks, target)
+		if target == "candidate" {
+			s.candidate = nil
+		}
+	}
+}
+
+func errorReply(err rpcError) string {
+	var message bytes.Buffer
+	xml.EscapeText(&message, []byte(err.message))
+	return `<rpc-error><error-type>application</error-type><error-tag>` + err.tag +
+		`</error-tag><error-severity>error</error-severity><error-message>` + message.String() +
+		`</error-message></rpc-error>`
+}
+
+// readMessage reads a message framed with the chunks of NETCONF 1.1, or ended
+// by the end-of-message marker of NETCONF 1.0.
+func readMessage(r *bufio.Reader, chunked bool) ([]byte, error) {
+	var message bytes.Buffer
+	if !chunked {
+		for {
+			part, err := r.ReadBytes('>')
+			message.Write(part)
+			if err != nil {
+				return nil, err
+			}
+			if bytes.HasSuffix(message.Bytes(), []byte(endOfMessage)) {
+				return bytes.TrimSuffix(message.Bytes(), []byte(endOfMessage)), nil
+			}
+		}
+	}
+	for {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if header == "\n" {
+			header, err = r.ReadString('\n')
+			if err != nil {
+				return nil, err
+			}
+		}
+		if header == "##\n" {
+			return message.Bytes(), nil
+		}
+		size, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(header, "#"), "\n"))
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid chunk header %q", header)
+		}
+		_, err = io.CopyN(&message, r, int64(size))
+		if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package fakeios
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"terraform-provider-ios/internal/native"
+)
+
+// RestconfServer serves a store over RESTCONF on the loopback interface.
+type RestconfServer struct {
+	Store *Store
+	// NoYangPatch makes the server lack the yang-patch capability, the
+	// store is then only edited one resource per request.
+	NoYangPatch bool
+
+	server   *httptest.Server
+	username string
+	password string
+}
+
+// ServeRestconf starts an HTTPS RESTCONF server for store on a random port,
+// accepting the username and password given.
+func ServeRestconf(store *Store, username string, password string) *RestconfServer {
+	s := &RestconfServer{Store: store, username: username, password: password}
+	s.server = httptest.NewTLSServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Host returns the address the server listens on.
+func (s *RestconfServer) Host() string {
+	host, _, _ := net.SplitHostPort(s.server.Listener.Addr().String())
+	return host
+}
+
+// Port returns the port the server listens on.
+func (s *RestconfServer) Port() string {
+	_, port, _ := net.SplitHostPort(s.server.Listener.Addr().String())
+	return port
+}
+
+// Certificate returns the PEM encoded certificate of the server.
+func (s *RestconfServer) Certificate() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.server.Certificate().Raw})
+}
+
+// Close stops the server.
+func (s *RestconfServer) Close() {
+	s.server.Close()
+}
+
+func (s *RestconfServer) serve(w http.ResponseWriter, r *http.Request) {
+	username, password, ok := r.BasicAuth()
+	if !ok || username != s.username || password != s.password {
+		restconfError(w, http.StatusUnauthorized, "access-denied", "authentication failed", "")
+		return
+	}
+	if r.URL.Path == "/restconf/operations/cisco-ia:save-config" && r.Method == http.MethodPost {
+		s.Store.mu.Lock()
+		s.Store.startup = s.Store.running.Clone()
+		s.Store.mu.Unlock()
+		w.Header().Set("Content-Type", "application/yang-data+json")
+		fmt.Fprint(w, `{"cisco-ia:output":{"result":"Save running config successful"}}`)
+		return
+	}
+
+	if r.URL.Path == "/restconf/data/ietf-restconf-monitoring:restconf-state/capabilities" && r.Method == http.MethodGet {
+		capabilities := []string{"urn:ietf:params:restconf:capability:defaults:1.0?basic-mode=explicit"}
+		if !s.NoYangPatch {
+			capabilities = append(capabilities, "urn:ietf:params:restconf:capability:yang-patch:1.0")
+		}
+		w.Header().Set("Content-Type", "application/yang-data+json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"ietf-restconf-monitoring:capabilities": map[string]any{"capability": capabilities},
+		})
+		return
+	}
+
+	raw, ok := strings.CutPrefix(r.URL.EscapedPath(), "/restconf/data/")
+	if !ok {
+		restconfError(w, http.StatusNotFound, "invalid-value", "unknown resource", r.URL.Path)
+		return
+	}
+	path, err := native.ParsePath(raw)
+	if err != nil || path[0].Module != native.Module || path[0].Name != "native" {
+		restconfError(w, http.StatusBadRequest, "invalid-value", "invalid path", raw)
+		return
+	}
+
+	s.Store.mu.Lock()
+	defer s.Store.mu.Unlock()
+	if r.Method == http.MethodPatch && r.Header.Get("Content-Type") == "application/yang-patch+json" && !s.NoYangPatch {
+		s.yangPatch(w, r, path)
+		return
+	}
+	var body *native.Node
+	if r.Method == http.MethodPatch || r.Method == http.MethodPut {
+		data, err := io.ReadAll(r.Body)
+		if err == nil {
+			body, err = native.DecodeJSON(data)
+		}
+		if err != nil {
+			restconfError(w, http.StatusBadRequest, "malformed-message", err.Error(), raw)
+			return
+		}
+		s.Store.edits = append(s.Store.edits, r.Method+" "+raw)
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		n := native.Find(s.Store.running, path)
+		if n == nil {
+			restconfError(w, http.StatusNotFound, "invalid-value", "uri keypath not found", raw)
+			return
+		}
+		data, err := native.EncodeJSON(path.Module(), n)
+		if err != nil {
+			restconfError(w, http.StatusInternalServerError, "operation-failed", err.Error(), raw)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yang-data+json")
+		w.Write(data)
+	case http.MethodPatch:
+		n := native.Find(s.Store.running, path)
+		if n == nil || n.Name != body.Name {
+			restconfError(w, http.StatusNotFound, "invalid-value", "uri keypath not found", raw)
+			return
+		}
+		native.Merge(n, body)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodPut:
+		// The keys of the entry are only known from its path.
+		body.Keys = path.Last().Keys
+		if !native.Replace(s.Store.running, path, body) {
+			restconfError(w, http.StatusNotFound, "invalid-value", "uri keypath not found", raw)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		s.Store.edits = append(s.Store.edits, r.Method+" "+raw)
+		if !native.Delete(s.Store.running, path) {
+			restconfError(w, http.StatusNotFound, "data-missing", "uri keypath not found", raw)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		restconfError(w, http.StatusMethodNotAllowed, "operation-not-supported", r.Method+" is not supported", raw)
+	}
+}
+
+// yangPatch applies the edits of a YANG-Patch of the node at path to a copy
+// of the running configuration, which replaces it once every edit succeeded.
+// The last edit fails when the store rejects the changes.
+func (s *RestconfServer) yangPatch(w http.ResponseWriter, r *http.Request, path native.Path) {
+	var body struct {
+		Patch struct {
+			ID   string `json:"patch-id"`
+			Edit []struct {
+				ID        string          `json:"edit-id"`
+				Operation string          `json:"operation"`
+				Target    string          `json:"target"`
+				Value     json.RawMessage `json:"value"`
+			} `json:"edit"`
+		} `json:"ietf-yang-patch:yang-patch"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || len(path) != 1 {
+		restconfError(w, http.StatusBadRequest, "malformed-message", "invalid yang-patch", r.URL.Path)
+		return
+	}
+
+	running := s.Store.running.Clone()
+	for i, edit := range body.Patch.Edit {
+		s.Store.edits = append(s.Store.edits, "yang-patch "+edit.Operation+" "+edit.Target)
+		target := path
+		if edit.Target != "/" {
+			relative, err := native.ParsePath(strings.TrimPrefix(edit.Target, "/"))
+			if err != nil {
+				yangPatchError(w, body.Patch.ID, edit.ID, "invalid-value", err.Error(), edit.Target)
+				return
+			}
+			target = append(append(native.Path{}, path...), relative...)
+		}
+		var value *native.Node
+		if edit.Operation == "merge" || edit.Operation == "replace" {
+			value, err = native.DecodeJSON(edit.Value)
+			if err != nil {
+				yangPatchError(w, body.Patch.ID, edit.ID, "malformed-message", err.Error(), edit.Target)
+				return
+			}
+		}
+
+		ok := true
+		switch edit.Operation {
+		case "merge":
+			n := native.Find(running, target)
+			ok = n != nil && n.Name == value.Name
+			if ok {
+				native.Merge(n, value)
+			}
+		case "replace":
+			value.Keys = target.Last().Keys
+			ok = native.Replace(running, target, value)
+		case "remove":
+			native.Delete(running, target)
+		default:
+			yangPatchError(w, body.Patch.ID, edit.ID, "operation-not-supported", edit.Operation+" is not supported", edit.Target)
+			return
+		}
+		if !ok {
+			yangPatchError(w, body.Patch.ID, edit.ID, "invalid-value", "uri keypath not found", edit.Target)
+			return
+		}
+		if i == len(body.Patch.Edit)-1 && s.Store.reject != "" {
+			yangPatchError(w, body.Patch.ID, edit.ID, "operation-failed", s.Store.reject, edit.Target)
+			return
+		}
+	}
+	s.Store.running = running
+	w.Header().Set("Content-Type", "application/yang-data+json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"ietf-yang-patch:yang-patch-status": map[string]any{"patch-id": body.Patch.ID, "ok": []any{nil}},
+	})
+}
+
+// yangPatchError writes the ietf-yang-patch:yang-patch-status response of a
+// YANG-Patch failing on the edit id.
+func yangPatchError(w http.ResponseWriter, patch string, id string, tag string, message string, path string) {
+	body := map[string]any{
+		"ietf-yang-patch:yang-patch-status": map[string]any{
+			"patch-id": patch,
+			"edit-status": map[string]any{
+				"edit": []map[string]any{{
+					"edit-id": id,
+					"errors": map[string]any{
+						"error": []map[string]string{{
+							"error-type":    "application",
+							"error-tag":     tag,
+							"error-message": message,
+							"error-path":    path,
+						}},
+					},
+				}},
+			},
+		},
+	}
+	w.Header().Set("Content-Type", "application/yang-data+json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(body)
+}
+
+// restconfError writes an ietf-restconf:errors response.
+func restconfError(w http.ResponseWriter, status int, tag string, message string, path string) {
+	body := map[string]any{
+		"ietf-restconf:errors": map[string]any{
+			"error": []map[string]string{{
+				"error-type":    "application",
+				"error-tag":     tag,
+				"error-message": message,
+				"error-path":    path,
+			}},
+		},
+	}
+	w.Header().Set("Content-Type", "application/yang-data+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,321 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package fakeios
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Server serves a device over SSH on the loopback interface, its command
+// line to shells and its store to the netconf subsystem. A bastion server
+// forwards direct-tcpip channels instead, and a telnet server serves the
+// command line over telnet.
+type Server struct {
+	Device *Device
+	Store  *Store
+
+	listener  net.Listener
+	config    *ssh.ServerConfig
+	hostKey   ssh.PublicKey
+	bastion   bool
+	forwarded []string
+	telnet    bool
+	username  string
+	password  string
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+// Serve starts an SSH server for device on a random port, accepting the
+// username and password given.
+func Serve(device *Device, username string, password string) (*Server, error) {
+	s, err := listen(username, password)
+	if err != nil {
+		return nil, err
+	}
+	s.Device = device
+	s.start()
+	return s, nil
+}
+
+// ServeNetconf starts an SSH server on a random port serving store to the
+// netconf subsystem, accepting the username and password given.
+func ServeNetconf(store *Store, username string, password string) (*Server, error) {
+	s, err := listen(username, password)
+	if err != nil {
+		return nil, err
+	}
+	s.Store = store
+	s.start()
+	return s, nil
+}
+
+// ServeBastion starts an SSH jump host on a random port, forwarding the
+// direct-tcpip channels of the clients authenticated with the username and
+// password given.
+func ServeBastion(username string, password string) (*Server, error) {
+	s, err := listen(username, password)
+	if err != nil {
+		return nil, err
+	}
+	s.bastion = true
+	s.start()
+	return s, nil
+}
+
+func listen(username string, password string) (*Server, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(meta ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if meta.User() == username && string(pass) == password {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("authentication failed for %s", meta.User())
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		listener: listener,
+		config:   config,
+		hostKey:  signer.PublicKey(),
+		username: username,
+		password: password,
+		conns:    map[net.Conn]struct{}{},
+	}, nil
+}
+
+func (s *Server) start() {
+	s.wg.Add(1)
+	go s.accept()
+}
+
+// Host returns the address the server listens on.
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.listener.Addr().String())
+	return host
+}
+
+// Port returns the port the server listens on.
+func (s *Server) Port() string {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return port
+}
+
+// HostKey returns the public key the server presents to the clients.
+func (s *Server) HostKey() ssh.PublicKey {
+	return s.hostKey
+}
+
+// Forwarded returns the addresses the bastion forwarded channels to, in
+// order.
+func (s *Server) Forwarded() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.forwarded...)
+}
+
+// Drop closes the open connections, like a device reloading or a network
+// outage, while still accepting new ones.
+func (s *Server) Drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// Close stops the server and closes the open connections.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.Drop()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serve(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+func (s *Server) serve(conn net.Conn) {
+	if s.telnet {
+		s.serveTelnet(conn)
+		return
+	}
+	sshConn, channels, requests, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	defer sshConn.Close()
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if s.bastion && newChannel.ChannelType() == "direct-tcpip" {
+			s.forward(newChannel)
+			continue
+		}
+		if s.bastion || newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go s.session(channel, requests)
+	}
+}
+
+// forward connects a direct-tcpip channel to the address it asks for, see
+// RFC 4254 section 7.2.
+func (s *Server) forward(newChannel ssh.NewChannel) {
+	var target struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	err := ssh.Unmarshal(newChannel.ExtraData(), &target)
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, "invalid direct-tcpip request")
+		return
+	}
+	addr := net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port)))
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	s.mu.Lock()
+	s.forwarded = append(s.forwarded, addr)
+	s.conns[conn] = struct{}{}
+	s.mu.Unlock()
+
+	s.wg.Add(2)
+	go func() {
+		defer s.wg.Done()
+		io.Copy(conn, channel)
+		conn.Close()
+	}()
+	go func() {
+		defer s.wg.Done()
+		io.Copy(channel, conn)
+		channel.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+}
+
+// session answers the requests of an SSH session and runs the shell, or the
+// netconf subsystem, once requested.
+func (s *Server) session(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+	for req := range requests {
+		switch req.Type {
+		case "pty-req", "env", "window-change":
+			req.Reply(true, nil)
+		case "shell":
+			if s.Device == nil {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+			go func() {
+				s.shell(channel)
+				channel.Close()
+			}()
+		case "subsystem":
+			// The payload is the length prefixed name of the subsystem.
+			if s.Store == nil || len(req.Payload) < 4 || string(req.Payload[4:]) != "netconf" {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+			go func() {
+				s.netconf(channel)
+				channel.Close()
+			}()
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
+
+// shell runs the command line of the device on channel, echoing the commands
+// back like a terminal does.
+func (s *Server) shell(channel io.ReadWriter) {
+	sh := newShell(s.Device)
+	write := func(text string) bool {
+		_, err := io.WriteString(channel, strings.ReplaceAll(text, "\n", "\r\n"))
+		return err == nil
+	}
+	if !write("\n" + sh.prompt()) {
+		return
+	}
+	reader := bufio.NewReader(channel)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		echo := line
+		if sh.hidden {
+			echo = ""
+		}
+		if !write(echo + "\n" + sh.input(line)) {
+			return
+		}
+		if sh.closed {
+			return
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package fakeios
+
+import (
+	"fmt"
+	"strings"
+)
+
+// shell is the command line of a session opened to the device.
+type shell struct {
+	device  *Device
+	level   int
+	config  bool
+	section *section
+	// dialog answers the question asked by the previous command, hidden
+	// when the answer is a secret not echoed back.
+	dialog func(answer string) string
+	hidden bool
+	closed bool
+}
+
+func newShell(device *Device) *shell {
+	level := 15
+	if device.EnableSecret != "" {
+		level = 1
+	}
+	return &shell{device: device, level: level}
+}
+
+func (sh *shell) prompt() string {
+	hostname := sh.device.hostname()
+	switch {
+	case sh.config && sh.section != nil:
+		return hostname + "(" + sectionMode(sh.section.line) + ")#"
+	case sh.config:
+		return hostname + "(config)#"
+	case sh.level < 15:
+		return hostname + ">"
+	}
+	return hostname + "#"
+}
+
+// ask makes the next line of input the answer to question.
+func (sh *shell) ask(question string, hidden bool, answer func(string) string) string {
+	sh.dialog = answer
+	sh.hidden = hidden
+	return question
+}
+
+// input runs a line typed in the session and returns the output of the
+// device, ending with the next prompt.
+func (sh *shell) input(line string) string {
+	sh.device.mu.Lock()
+	defer sh.device.mu.Unlock()
+
+	var output string
+	if sh.dialog != nil {
+		dialog := sh.dialog
+		sh.dialog = nil
+		sh.hidden = false
+		output = dialog(line)
+	} else if sh.config {
+		output = sh.configure(line)
+	} else {
+		output = sh.exec(line)
+	}
+	if sh.dialog != nil || sh.closed {
+		return output
+	}
+	if output != "" {
+		output += "\n"
+	}
+	return output + sh.prompt()
+}
+
+// is reports whether the words of a command match the keywords, each word
+// being the keyword or an abbreviation of it.
+func is(fields []string, keywords ...string) bool {
+	if len(fields) != len(keywords) {
+		return false
+	}
+	for i, keyword := range keywords {
+		if !strings.HasPrefix(keyword, fields[i]) || len(fields[i]) < 2 && len(keyword) > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func (sh *shell) exec(line string) string {
+	d := sh.device
+	fields := strings.Fields(line)
+	switch {
+	case len(fields) == 0:
+		return ""
+	case is(fields, "show", "privilege"):
+		return fmt.Sprintf("Current privilege level is %d", sh.level)
+	case is(fields, "terminal", "length", "0"), is(fields, "terminal", "width", "0"):
+		return ""
+	case is(fields, "enable"):
+		if sh.level == 15 {
+			return ""
+		}
+		return sh.enable(1)
+	case is(fields, "disable"):
+		sh.level = 1
+		return ""
+	case is(fields, "exit"), is(fields, "logout"):
+		sh.closed = true
+		return ""
+	}
+
+	if sh.level < 15 {
+		return "                ^\n" + invalidInput
+	}
+	if output, rejected := d.rejects(strings.Join(fields, " "), 16); rejected {
+		return output
+	}
+	switch {
+	case is(fields, "show", "running-config"):
+		config := render(d.config)
+		return fmt.Sprintf("Building configuration...\n\nCurrent configuration : %d bytes\n%s", len(config), config)
+	case is(fields, "show", "startup-config"):
+		if d.startup == "" {
+			return "startup-config is not present"
+		}
+		return d.startup
+	case is(fields, "show", "vlan"), is(fields, "show", "vlan", "brief"):
+		return d.showVlan()
+	case is(fields, "show", "version") && d.Version != "":
+		return d.Version
+	case is(fields, "show", "inventory") && d.Inventory != "":
+		return d.Inventory
+	case is(fields, "configure", "terminal"):
+		sh.config = true
+		sh.section = nil
+		return "Enter configuration commands, one per line.  End with CNTL/Z."
+	case is(fields, "configure", "confirm"):
+		return ""
+	case len(fields) >= 4 && is(fields[:4], "configure", "replace", fields[2], "force"):
+		snapshot, ok := d.flash[fields[2]]
+		if !ok {
+			return fmt.Sprintf("%%Error opening %s (File not found)", fields[2])
+		}
+		d.config = clone(snapshot)
+		return "Total number of passes: 1\nRollback Done"
+	case is(fields, "copy", "running-config", "startup-config"):
+		return sh.ask("Destination filename [startup-config]? ", false, func(string) string {
+			d.startup = render(d.config)
+			return "Building configuration...\n[OK]"
+		})
+	case len(fields) == 3 && is(fields[:2], "copy", "running-config") && strings.HasPrefix(fields[2], "flash:"):
+		file := fields[2]
+		return sh.ask(fmt.Sprintf("Destination filename [%s]? ", strings.TrimPrefix(file, "flash:")), false, func(string) string {
+			d.flash[file] = clone(d.config)
+			return fmt.Sprintf("%d bytes copied in 0.112 secs", len(render(d.config)))
+		})
+	case len(fields) == 3 && is(fields[:2], "delete", "/force"):
+		delete(d.flash, fields[2])
+		return ""
+	}
+	return "                ^\n" + invalidInput
+}
+
+// enable asks for the enable secret, up to three times like IOS.
+func (sh *shell) enable(attempt int) string {
+	return sh.ask("Password: ", true, func(secret string) string {
+		if secret == sh.device.EnableSecret {
+			sh.level = 15
+			return ""
+		}
+		if attempt == 3 {
+			return "% Bad secrets"
+		}
+		return sh.enable(attempt + 1)
+	})
+}
+
+func (sh *shell) configure(line string) string {
+	d := sh.device
+	d.history = append(d.history, line)
+
+	cmd := strings.TrimSpace(line)
+	switch {
+	case cmd == "" || cmd == "!":
+		return ""
+	case cmd == "end":
+		sh.config = false
+		sh.section = nil
+		return ""
+	case cmd == "exit":
+		if sh.section == nil {
+			sh.config = false
+		}
+		sh.section = nil
+		return ""
+	}
+	if output, rejected := d.rejects(cmd, len(sh.prompt())+1); rejected {
+		return output
+	}
+
+	// The indented lines are sub-commands of the section entered last, the
+	// others are global commands.
+	if line != cmd && sh.section != nil {
+		sh.section.children = set(sh.section.children, cmd)
+		return ""
+	}
+	sh.section = nil
+
+	negated := strings.TrimPrefix(cmd, "no ")
+	switch {
+	case isSection(cmd):
+		sh.section = d.enter(cmd)
+	case negated != cmd && isSection(negated):
+		d.remove(negated)
+	case strings.HasPrefix(cmd, "default interface "):
+		if s := d.find(strings.TrimPrefix(cmd, "default ")); s != nil {
+			s.children = nil
+		}
+	case negated != cmd:
+		d.remove(negated)
+	case strings.HasPrefix(cmd, "hostname "):
+		d.remove("hostname")
+		d.config = append([]*section{{line: cmd}}, d.config...)
+	case d.find(cmd) == nil:
+		d.insert(&section{line: cmd})
+	}
+	return ""
+}
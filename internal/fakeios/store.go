@@ -0,0 +1,68 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package fakeios
+
+import (
+	"github.com/CorentinPtrl/cisconf"
+	"sync"
+	"terraform-provider-ios/internal/native"
+)
+
+// Store is the native data tree of a simulated IOS-XE device, edited through
+// its model-driven interfaces.
+type Store struct {
+	mu        sync.Mutex
+	running   *native.Node
+	startup   *native.Node
+	candidate *native.Node
+	// locks holds the NETCONF session locking each datastore.
+	locks  map[string]int64
+	reject string
+	edits  []string
+}
+
+// NewStore returns the store of a device running config, DefaultConfig when
+// empty. Only the parts of the config modelled by cisconf are kept.
+func NewStore(config string) (*Store, error) {
+	if config == "" {
+		config = DefaultConfig
+	}
+	cfg := &cisconf.Config{}
+	err := cisconf.Unmarshal(config, cfg)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := native.FromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{running: tree}, nil
+}
+
+// Config returns the running configuration of the store.
+func (s *Store) Config() (*cisconf.Config, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return native.Config(s.running)
+}
+
+// Saved reports whether the running configuration was saved since its last
+// change.
+func (s *Store) Saved() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.startup == nil {
+		return false
+	}
+	patch, err := native.Diff(s.startup, s.running)
+	return err == nil && patch.IsEmpty()
+}
+
+// Edits returns the edits the store received in order, as the method and the
+// path of the RESTCONF requests, or the NETCONF operations and their target.
+func (s *Store) Edits() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.edits...)
+}
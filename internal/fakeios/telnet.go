@@ -0,0 +1,132 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package fakeios
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+)
+
+const (
+	telnetIAC          = 255
+	telnetWILL         = 251
+	telnetDO           = 253
+	telnetEcho         = 1
+	telnetSGA          = 3
+	telnetTerminalType = 24
+)
+
+// ServeTelnet starts a telnet server for device on a random port, asking for
+// the username and password given like a vty line with login local.
+func ServeTelnet(device *Device, username string, password string) (*Server, error) {
+	s, err := listen(username, password)
+	if err != nil {
+		return nil, err
+	}
+	s.Device = device
+	s.telnet = true
+	s.start()
+	return s, nil
+}
+
+// serveTelnet negotiates the options of the connection, asks for the
+// credentials up to three times, then runs the shell.
+func (s *Server) serveTelnet(conn net.Conn) {
+	// The client is offered echo and suppress go-ahead, and asked for its
+	// terminal type, which it is free to refuse.
+	_, err := conn.Write([]byte{
+		telnetIAC, telnetWILL, telnetEcho,
+		telnetIAC, telnetWILL, telnetSGA,
+		telnetIAC, telnetDO, telnetTerminalType,
+	})
+	if err != nil {
+		return
+	}
+	terminal := &telnetTerminal{Conn: conn, reader: bufio.NewReader(conn)}
+	write := func(text string) bool {
+		_, err := io.WriteString(conn, strings.ReplaceAll(text, "\n", "\r\n"))
+		return err == nil
+	}
+	if !write("\nUser Access Verification\n\n") {
+		return
+	}
+	for attempt := 1; ; attempt++ {
+		if !write("Username: ") {
+			return
+		}
+		username, err := terminal.readLine()
+		if err != nil || !write(username+"\n") {
+			return
+		}
+		if !write("Password: ") {
+			return
+		}
+		password, err := terminal.readLine()
+		if err != nil || !write("\n") {
+			return
+		}
+		if username == s.username && password == s.password {
+			break
+		}
+		if attempt == 3 {
+			write("% Bad passwords\n")
+			return
+		}
+		if !write("% Login invalid\n\n") {
+			return
+		}
+	}
+	s.shell(terminal)
+}
+
+// telnetTerminal is a telnet connection read without the option negotiation
+// of the client.
+type telnetTerminal struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// Read returns the data sent by the client, dropping the three byte commands
+// answering the options.
+func (t *telnetTerminal) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if n > 0 && t.reader.Buffered() == 0 {
+			break
+		}
+		b, err := t.reader.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		if b == telnetIAC {
+			_, err = t.reader.Discard(2)
+			if err != nil {
+				return n, err
+			}
+			continue
+		}
+		p[n] = b
+		n++
+	}
+	return n, nil
+}
+
+// readLine reads a line typed by the client, without its line ending. It
+// reads a byte at a time so that nothing past the line is consumed.
+func (t *telnetTerminal) readLine() (string, error) {
+	var line strings.Builder
+	b := make([]byte, 1)
+	for {
+		_, err := t.Read(b)
+		if err != nil {
+			return "", err
+		}
+		if b[0] == '\n' {
+			return strings.TrimRight(line.String(), "\r"), nil
+		}
+		line.WriteByte(b[0])
+	}
+}
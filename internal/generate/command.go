@@ -0,0 +1,213 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package generate
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/CorentinPtrl/cisconf"
+	"io"
+	"os"
+	"strconv"
+	"terraform-provider-ios/internal/provider/models"
+	"terraform-provider-ios/internal/session"
+)
+
+// Run runs the generate command with args, the arguments following its
+// name, and writes the configuration to stdout unless -output is set. The
+// running-config is read from the file given with -running-config, or from
+// the device over SSH or telnet. The connection flags default to the IOS_*
+// environment variables the provider also reads, the bastion is only set
+// with the flags.
+func Run(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := flag.NewFlagSet("generate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: terraform-provider-ios generate [flags]")
+		fmt.Fprintln(stderr, "")
+		fmt.Fprintln(stderr, "Writes an import block and a resource for every vlan, interface, static route")
+		fmt.Fprintln(stderr, "and EIGRP process of the running-config of a device.")
+		fmt.Fprintln(stderr, "")
+		flags.PrintDefaults()
+	}
+	runningConfig := flags.String("running-config", "", "file holding the running-config, instead of reading it from the device")
+	output := flags.String("output", "", "file the configuration is written to, instead of the standard output")
+	d := device{}
+	flags.StringVar(&d.ssh.Host, "host", os.Getenv("IOS_HOST"), "address of the device, defaults to IOS_HOST")
+	flags.StringVar(&d.ssh.Port, "port", env("IOS_PORT", "22"), "port of the device, defaults to IOS_PORT or 22")
+	flags.StringVar(&d.ssh.Username, "username", os.Getenv("IOS_USERNAME"), "username, defaults to IOS_USERNAME")
+	flags.StringVar(&d.ssh.Password, "password", os.Getenv("IOS_PASSWORD"), "password, defaults to IOS_PASSWORD")
+	flags.StringVar(&d.ssh.EnablePassword, "enable-password", os.Getenv("IOS_ENABLE_PASSWORD"), "enable password, defaults to IOS_ENABLE_PASSWORD")
+	flags.StringVar(&d.privateKeyFile, "private-key-file", os.Getenv("IOS_PRIVATE_KEY_FILE"), "private key used for SSH public key authentication, defaults to IOS_PRIVATE_KEY_FILE, the key itself is read from IOS_PRIVATE_KEY")
+	flags.StringVar(&d.ssh.Passphrase, "passphrase", os.Getenv("IOS_PASSPHRASE"), "passphrase of the encrypted private key, defaults to IOS_PASSPHRASE")
+	flags.StringVar(&d.ssh.KnownHostsFile, "known-hosts-file", os.Getenv("IOS_KNOWN_HOSTS_FILE"), "known_hosts file the host key is verified against, defaults to IOS_KNOWN_HOSTS_FILE")
+	flags.StringVar(&d.ssh.HostKeyFingerprint, "host-key-fingerprint", os.Getenv("IOS_HOST_KEY_FINGERPRINT"), "expected fingerprint of the host key, defaults to IOS_HOST_KEY_FINGERPRINT")
+	insecure, _ := strconv.ParseBool(os.Getenv("IOS_INSECURE_IGNORE_HOST_KEY"))
+	flags.BoolVar(&d.ssh.InsecureIgnoreHostKey, "insecure-ignore-host-key", insecure, "accept any host key of the device and the bastion when neither -known-hosts-file nor -host-key-fingerprint is set, defaults to IOS_INSECURE_IGNORE_HOST_KEY")
+	flags.StringVar(&d.transport, "transport", env("IOS_TRANSPORT", "ssh"), "either ssh or telnet, defaults to IOS_TRANSPORT or ssh")
+	flags.StringVar(&d.bastion.Host, "bastion-host", "", "address of the SSH bastion the device is reached through")
+	flags.StringVar(&d.bastion.Port, "bastion-port", "22", "SSH port of the bastion")
+	flags.StringVar(&d.bastion.Username, "bastion-user", "", "username on the bastion, defaults to the username of the device")
+	flags.StringVar(&d.bastion.Password, "bastion-password", "", "password on the bastion")
+	flags.StringVar(&d.bastionKeyFile, "bastion-private-key-file", "", "private key used for the public key authentication on the bastion")
+	flags.StringVar(&d.bastion.HostKeyFingerprint, "bastion-host-key-fingerprint", "", "expected fingerprint of the host key of the bastion")
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return fmt.Errorf("unexpected arguments %q", flags.Args())
+	}
+
+	// A saved running-config is read as the running-config of an unknown
+	// platform.
+	var cfg *cisconf.Config
+	facts := &models.Facts{}
+	if *runningConfig != "" {
+		cfg, err = readFile(*runningConfig)
+	} else {
+		cfg, facts, err = readDevice(ctx, d, stderr)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		return Config(ctx, stdout, cfg, facts)
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	err = Config(ctx, file, cfg, facts)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func env(name string, def string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return def
+}
+
+func readFile(name string) (*cisconf.Config, error) {
+	text, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &cisconf.Config{}
+	err = cisconf.Unmarshal(string(text), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return cfg, nil
+}
+
+// device holds the settings of the connection to the device.
+type device struct {
+	ssh            session.SSHConfig
+	privateKeyFile string
+	transport      string
+	bastion        session.SSHConfig
+	bastionKeyFile string
+}
+
+// dialer returns the function opening the CLI connections to the device d,
+// after reading its private keys.
+func (d device) dialer(stderr io.Writer) (session.Dialer, error) {
+	switch d.transport {
+	case "telnet":
+		if d.bastion.Host != "" || d.privateKeyFile != "" || os.Getenv("IOS_PRIVATE_KEY") != "" {
+			return nil, errors.New("private keys and bastions are only supported by the ssh transport")
+		}
+		fmt.Fprintln(stderr, "Warning: the telnet transport sends the credentials and the running-config of the device in clear text.")
+		return func() (session.Conn, error) {
+			return session.DialTelnet(session.TelnetConfig{
+				Host:           d.ssh.Host,
+				Port:           d.ssh.Port,
+				Username:       d.ssh.Username,
+				Password:       d.ssh.Password,
+				EnablePassword: d.ssh.EnablePassword,
+			})
+		}, nil
+	case "ssh":
+	default:
+		return nil, fmt.Errorf("the transport %q is not supported, the running-config is read over ssh or telnet", d.transport)
+	}
+
+	ssh := d.ssh
+	if key := os.Getenv("IOS_PRIVATE_KEY"); key != "" && d.privateKeyFile != "" {
+		return nil, errors.New("both IOS_PRIVATE_KEY and -private-key-file are set, set only one of them")
+	} else if key != "" {
+		ssh.PrivateKey = []byte(key)
+	}
+	if d.privateKeyFile != "" {
+		key, err := os.ReadFile(d.privateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		ssh.PrivateKey = key
+	}
+	if ssh.KnownHostsFile == "" && ssh.HostKeyFingerprint == "" {
+		if !ssh.InsecureIgnoreHostKey {
+			return nil, errors.New("the host key of the device cannot be verified, set -known-hosts-file or -host-key-fingerprint, or -insecure-ignore-host-key to accept any host key")
+		}
+		fmt.Fprintln(stderr, "Warning: the host key of the device is accepted without verification, set -known-hosts-file or -host-key-fingerprint.")
+	}
+
+	if d.bastion.Host != "" {
+		bastion := d.bastion
+		if bastion.Username == "" {
+			bastion.Username = ssh.Username
+		}
+		if d.bastionKeyFile != "" {
+			key, err := os.ReadFile(d.bastionKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			bastion.PrivateKey = key
+		}
+		bastion.InsecureIgnoreHostKey = ssh.InsecureIgnoreHostKey
+		if bastion.HostKeyFingerprint == "" {
+			if !bastion.InsecureIgnoreHostKey {
+				return nil, errors.New("the host key of the bastion cannot be verified, set -bastion-host-key-fingerprint, or -insecure-ignore-host-key to accept any host key")
+			}
+			fmt.Fprintln(stderr, "Warning: the host key of the bastion is accepted without verification, set -bastion-host-key-fingerprint.")
+		}
+		ssh.Bastion = &bastion
+	}
+	return func() (session.Conn, error) {
+		return session.DialSSH(ssh)
+	}, nil
+}
+
+func readDevice(ctx context.Context, d device, stderr io.Writer) (*cisconf.Config, *models.Facts, error) {
+	if d.ssh.Host == "" {
+		return nil, nil, errors.New("the device is unknown, set -host or IOS_HOST, or read a saved running-config with -running-config")
+	}
+	dial, err := d.dialer(stderr)
+	if err != nil {
+		return nil, nil, err
+	}
+	client := session.New(dial, session.Options{Host: d.ssh.Host})
+	defer session.Shutdown()
+	cfg, err := client.RunningConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	facts, err := models.GetFacts(ctx, client)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, facts, nil
+}
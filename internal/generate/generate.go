@@ -0,0 +1,239 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+// Package generate writes the Terraform configuration adopting the objects
+// already configured on a device: an import block and a resource for every
+// vlan, interface, static route and EIGRP process the provider models.
+package generate
+
+import (
+	"context"
+	"fmt"
+	"github.com/CorentinPtrl/cisconf"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"terraform-provider-ios/internal/provider/models"
+	"terraform-provider-ios/internal/utils"
+)
+
+// routedKinds are the interfaces without switchport, managed by the
+// ios_ethernet_interface resource whatever their running-config says.
+var routedKinds = []string{"Vlan", "Loopback", "Tunnel", "BDI"}
+
+var nonIdentifier = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Config writes the import blocks and the resources of every object of cfg
+// to w. The attributes are converted like the resources read them on the
+// platform of facts, so that the plan following the import is empty.
+func Config(ctx context.Context, w io.Writer, cfg *cisconf.Config, facts *models.Facts) error {
+	g := &generator{ctx: ctx, facts: facts, labels: map[string]bool{}}
+
+	vlans := slices.Clone(cfg.Vlans)
+	slices.SortFunc(vlans, func(a, b cisconf.Vlan) int { return a.Id - b.Id })
+	for _, vlan := range vlans {
+		model := models.VlanFromCisconf(ctx, vlan)
+		b := &body{}
+		b.set("id", int64(model.Id.ValueInt32()))
+		b.set("name", model.Name.ValueString())
+		g.resource("ios_vlan", g.label("vlan", model.Id.String()), model.Id.String(), b)
+	}
+
+	for i := range cfg.Interfaces {
+		iface := &cfg.Interfaces[i]
+		name := iface.Parent.Identifier
+		var b *body
+		var typ string
+		var err error
+		if routed(iface) {
+			typ = "ios_ethernet_interface"
+			b, err = g.ethernet(iface)
+		} else {
+			typ = "ios_switch_interface"
+			b, err = g.switchport(iface)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to convert interface %s: %w", name, err)
+		}
+		g.resource(typ, g.label(name), name, b)
+	}
+
+	for _, route := range cfg.Routes {
+		// The routes of a vrf are not modelled by ios_static_route.
+		if route.Prefix == "" {
+			continue
+		}
+		model := models.RouteFromCisconf(route)
+		b := &body{}
+		b.set("prefix", model.Prefix.ValueString())
+		b.set("mask", model.Mask.ValueString())
+		b.set("next_hop", model.NextHop.ValueString())
+		length := route.Mask
+		if n, err := utils.SubnetMaskToCIDR(route.Mask); err == nil {
+			length = strconv.Itoa(n)
+		}
+		label := g.label("route", route.Prefix, length, "via", route.IpAddress)
+		g.resource("ios_static_route", label, route.Prefix+"/"+route.Mask+"/"+route.IpAddress, b)
+	}
+
+	for _, eigrp := range cfg.EIGRPProcess {
+		model, err := models.EigrpFromCisconf(ctx, eigrp)
+		if err != nil {
+			return fmt.Errorf("failed to convert EIGRP process %d: %w", eigrp.Asn, err)
+		}
+		b := &body{}
+		b.set("as_number", model.As.ValueInt64())
+		b.set("networks", stringList(ctx, model.Networks))
+		g.resource("ios_eigrp", g.label("eigrp", model.As.String()), model.As.String(), b)
+	}
+
+	_, err := io.WriteString(w, g.out.String())
+	return err
+}
+
+type generator struct {
+	ctx    context.Context
+	facts  *models.Facts
+	out    strings.Builder
+	labels map[string]bool
+}
+
+// resource writes the import block of the object id and its resource.
+func (g *generator) resource(typ string, label string, id string, b *body) {
+	if g.out.Len() > 0 {
+		g.out.WriteString("\n")
+	}
+	imp := &body{}
+	imp.set("to", reference(typ+"."+label))
+	imp.set("id", id)
+	g.out.WriteString("import {\n" + imp.render("  ") + "}\n\n")
+	g.out.WriteString(fmt.Sprintf("resource %q %q {\n%s}\n", typ, label, b.render("  ")))
+}
+
+// label returns a resource name made of parts, unique in the configuration.
+func (g *generator) label(parts ...string) string {
+	label := strings.Trim(nonIdentifier.ReplaceAllString(strings.ToLower(strings.Join(parts, "_")), "_"), "_")
+	if label == "" || (label[0] >= '0' && label[0] <= '9') {
+		label = "_" + label
+	}
+	unique := label
+	for i := 2; g.labels[unique]; i++ {
+		unique = label + "_" + strconv.Itoa(i)
+	}
+	g.labels[unique] = true
+	return unique
+}
+
+// routed reports whether iface is managed by ios_ethernet_interface rather
+// than ios_switch_interface.
+func routed(iface *cisconf.CiscoInterface) bool {
+	if !iface.Switchport {
+		return true
+	}
+	for _, kind := range routedKinds {
+		if strings.HasPrefix(iface.Parent.Identifier, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *generator) ethernet(iface *cisconf.CiscoInterface) (*body, error) {
+	model, err := models.InterfaceEthernetFromCisconf(g.ctx, iface)
+	if err != nil {
+		return nil, err
+	}
+	b := &body{}
+	g.common(b, model.InterfaceModel)
+	var ips []models.IpInterfaceModel
+	model.Ips.ElementsAs(g.ctx, &ips, false)
+	if len(ips) > 0 {
+		var objects []*body
+		for _, ip := range ips {
+			object := &body{}
+			object.set("ip", ip.Ip.ValueString())
+			objects = append(objects, object)
+		}
+		b.set("ips", objects)
+	}
+	if helpers := stringList(g.ctx, model.HelperAddresses); len(helpers) > 0 {
+		b.set("helper_addresses", helpers)
+	}
+	return b, nil
+}
+
+func (g *generator) switchport(iface *cisconf.CiscoInterface) (*body, error) {
+	g.facts.ReadTrunk(iface)
+	model, err := models.InterfaceSwitchFromCisconf(g.ctx, iface)
+	if err != nil {
+		return nil, err
+	}
+	b := &body{}
+	g.common(b, model.InterfaceModel)
+	if !model.Access.IsNull() {
+		access, diags := models.AccessFromObjectValue(g.ctx, model.Access)
+		if diags.HasError() {
+			return nil, fmt.Errorf("%v", diags)
+		}
+		object := &body{}
+		object.set("access_vlan", int64(access.AccessVlan.ValueInt32()))
+		b.set("access", object)
+	}
+	if !model.Trunk.IsNull() {
+		trunk, diags := models.TrunkFromObjectValue(g.ctx, model.Trunk)
+		if diags.HasError() {
+			return nil, fmt.Errorf("%v", diags)
+		}
+		object := &body{}
+		// dot1q is the default, the devices only supporting dot1q have no
+		// encapsulation to configure.
+		if value := trunk.Encapsulation.ValueString(); value != "" && value != "dot1q" {
+			object.set("encapsulation", trunk.Encapsulation.ValueString())
+		}
+		if !trunk.AllowedVlans.IsNull() {
+			var vlans []int64
+			for _, vlan := range trunk.AllowedVlans.Elements() {
+				vlans = append(vlans, int64(vlan.(types.Int32).ValueInt32()))
+			}
+			object.set("allowed_vlans", vlans)
+		}
+		b.set("trunk", object)
+	}
+	stp, diags := models.SpanningTreeFromObjectValue(g.ctx, model.SpanningTree)
+	if diags.HasError() {
+		return nil, fmt.Errorf("%v", diags)
+	}
+	if stp.Portfast.ValueString() != "" || !stp.BpduGuard.IsNull() {
+		object := &body{}
+		if stp.Portfast.ValueString() != "" {
+			object.set("portfast", stp.Portfast.ValueString())
+		}
+		if !stp.BpduGuard.IsNull() {
+			object.set("bpdu_guard", stp.BpduGuard.ValueBool())
+		}
+		b.set("spanning_tree", object)
+	}
+	return b, nil
+}
+
+// common sets the attributes shared by the interfaces, leaving out their
+// defaults.
+func (g *generator) common(b *body, model models.InterfaceModel) {
+	b.set("id", model.ID.ValueString())
+	if model.Description.ValueString() != "" {
+		b.set("description", model.Description.ValueString())
+	}
+	if model.Shutdown.ValueBool() {
+		b.set("shutdown", true)
+	}
+}
+
+// stringList returns the values of a list of strings.
+func stringList(ctx context.Context, list types.List) []string {
+	var values []string
+	list.ElementsAs(ctx, &values, false)
+	return values
+}
@@ -0,0 +1,242 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package generate
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CorentinPtrl/cisconf"
+	"golang.org/x/crypto/ssh"
+	"terraform-provider-ios/internal/fakeios"
+	"terraform-provider-ios/internal/golden"
+	"terraform-provider-ios/internal/provider/models"
+)
+
+// TestConfig generates the configuration of the running-configs of real
+// devices, shared with the tests of the models.
+func TestConfig(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "provider", "models", "testdata", "*.txt"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no running-config found: %v", err)
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".txt")
+		t.Run(name, func(t *testing.T) {
+			text, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg := &cisconf.Config{}
+			err = cisconf.Unmarshal(string(text), cfg)
+			if err != nil {
+				t.Fatalf("failed to parse %s: %s", file, err)
+			}
+			var out bytes.Buffer
+			err = Config(context.Background(), &out, cfg, &models.Facts{})
+			if err != nil {
+				t.Fatalf("Config() error = %s", err)
+			}
+			golden.Check(t, out.String())
+		})
+	}
+}
+
+// TestConfigTrunk generates a trunk without encapsulation line, which the
+// switches only running 802.1Q trunks leave out of their running-config.
+func TestConfigTrunk(t *testing.T) {
+	const runningConfig = `interface GigabitEthernet1/0/1
+ switchport mode trunk
+ switchport trunk allowed vlan 10,20
+!
+interface GigabitEthernet1/0/2
+ switchport trunk encapsulation isl
+ switchport mode trunk
+!
+`
+	for name, facts := range map[string]*models.Facts{
+		"unknown": {},
+		"dot1q":   {Platform: models.Platform{Family: "Catalyst 9000", NoTrunkEncapsulation: true}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := &cisconf.Config{}
+			err := cisconf.Unmarshal(runningConfig, cfg)
+			if err != nil {
+				t.Fatalf("failed to parse the running-config: %s", err)
+			}
+			var out bytes.Buffer
+			err = Config(context.Background(), &out, cfg, facts)
+			if err != nil {
+				t.Fatalf("Config() error = %s", err)
+			}
+			if strings.Contains(out.String(), `encapsulation = ""`) {
+				t.Errorf("Config() writes an empty encapsulation:\n%s", out.String())
+			}
+			golden.Check(t, out.String())
+		})
+	}
+}
+
+func TestLabel(t *testing.T) {
+	g := &generator{labels: map[string]bool{}}
+	for _, tt := range []struct {
+		parts []string
+		want  string
+	}{
+		{[]string{"GigabitEthernet1/0/1"}, "gigabitethernet1_0_1"},
+		{[]string{"GigabitEthernet1/0/1"}, "gigabitethernet1_0_1_2"},
+		{[]string{"Port-channel1.100"}, "port_channel1_100"},
+		{[]string{"route", "10.0.0.0", "8", "via", "192.168.0.1"}, "route_10_0_0_0_8_via_192_168_0_1"},
+		{[]string{"10"}, "_10"},
+	} {
+		if got := g.label(tt.parts...); got != tt.want {
+			t.Errorf("label(%q) = %s, want %s", tt.parts, got, tt.want)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	got := quote(`say "hi" to ${user} at 100%{x}`)
+	want := `"say \"hi\" to $${user} at 100%%{x}"`
+	if got != want {
+		t.Errorf("quote() = %s, want %s", got, want)
+	}
+}
+
+// TestRunDevice reads the running-config of a simulated device over SSH.
+func TestRunDevice(t *testing.T) {
+	device := fakeios.New(fakeios.DefaultConfig + "vlan 10\n name users\n!\n")
+	server, err := fakeios.Serve(device, "admin", "cisco")
+	if err != nil {
+		t.Fatalf("failed to start the device: %s", err)
+	}
+	defer server.Close()
+	telnet, err := fakeios.ServeTelnet(device, "admin", "cisco")
+	if err != nil {
+		t.Fatalf("failed to start the telnet device: %s", err)
+	}
+	defer telnet.Close()
+	bastion, err := fakeios.ServeBastion("jump", "secret")
+	if err != nil {
+		t.Fatalf("failed to start the bastion: %s", err)
+	}
+	defer bastion.Close()
+
+	tests := []struct {
+		name string
+		args []string
+		// warning is the start of the warning printed, empty for none.
+		warning string
+	}{
+		{"ssh", []string{
+			"-host", server.Host(),
+			"-port", server.Port(),
+			"-host-key-fingerprint", ssh.FingerprintSHA256(server.HostKey()),
+		}, ""},
+		{"telnet", []string{
+			"-host", telnet.Host(),
+			"-port", telnet.Port(),
+			"-transport", "telnet",
+		}, "Warning: the telnet transport"},
+		{"bastion", []string{
+			"-host", server.Host(),
+			"-port", server.Port(),
+			"-host-key-fingerprint", ssh.FingerprintSHA256(server.HostKey()),
+			"-bastion-host", bastion.Host(),
+			"-bastion-port", bastion.Port(),
+			"-bastion-user", "jump",
+			"-bastion-password", "secret",
+			"-bastion-host-key-fingerprint", ssh.FingerprintSHA256(bastion.HostKey()),
+		}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "main.tf")
+			var stderr bytes.Buffer
+			args := append(tt.args, "-username", "admin", "-password", "cisco", "-output", output)
+			err := Run(context.Background(), args, &bytes.Buffer{}, &stderr)
+			if err != nil {
+				t.Fatalf("Run() error = %s\n%s", err, stderr.String())
+			}
+			data, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range []string{
+				"import {\n  to = ios_vlan.vlan_10\n  id = \"10\"\n}\n",
+				"resource \"ios_ethernet_interface\" \"gigabitethernet0_0\" {\n",
+				"resource \"ios_switch_interface\" \"gigabitethernet0_1\" {\n  id = \"GigabitEthernet0/1\"\n}\n",
+				"resource \"ios_static_route\" \"route_0_0_0_0_0_via_192_168_0_1\" {\n",
+			} {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("the configuration misses %q:\n%s", want, data)
+				}
+			}
+			if (tt.warning == "") != (stderr.Len() == 0) || !strings.HasPrefix(stderr.String(), tt.warning) {
+				t.Errorf("Run() warned %q, want %q", stderr.String(), tt.warning)
+			}
+		})
+	}
+	if forwarded := bastion.Forwarded(); len(forwarded) == 0 {
+		t.Errorf("the bastion forwarded no connection to the device")
+	}
+}
+
+func TestRunSettings(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{"restconf", []string{"-transport", "restconf"}, `the transport "restconf" is not supported`},
+		{"telnet private key", []string{"-transport", "telnet", "-private-key-file", "id_ed25519"}, "only supported by the ssh transport"},
+		{"unreadable private key", []string{"-private-key-file", filepath.Join(t.TempDir(), "id_ed25519"), "-insecure-ignore-host-key"}, "no such file"},
+		{"unverified bastion", []string{"-host-key-fingerprint", "SHA256:x", "-bastion-host", "127.0.0.1"}, "-bastion-host-key-fingerprint"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append(tt.args, "-host", "127.0.0.1", "-username", "admin", "-password", "cisco")
+			err := Run(context.Background(), args, &bytes.Buffer{}, &bytes.Buffer{})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Run() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRunUnverifiedHostKey(t *testing.T) {
+	device := fakeios.New(fakeios.DefaultConfig)
+	server, err := fakeios.Serve(device, "admin", "cisco")
+	if err != nil {
+		t.Fatalf("failed to start the device: %s", err)
+	}
+	defer server.Close()
+
+	args := []string{
+		"-host", server.Host(),
+		"-port", server.Port(),
+		"-username", "admin",
+		"-password", "cisco",
+	}
+	err = Run(context.Background(), args, &bytes.Buffer{}, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "-host-key-fingerprint") {
+		t.Fatalf("Run() error = %v, want the device refused", err)
+	}
+	if len(device.History()) > 0 {
+		t.Errorf("the device ran %q", device.History())
+	}
+
+	var stderr bytes.Buffer
+	err = Run(context.Background(), append(args, "-insecure-ignore-host-key"), &bytes.Buffer{}, &stderr)
+	if err != nil {
+		t.Fatalf("Run() error = %s", err)
+	}
+	if !strings.Contains(stderr.String(), "without verification") {
+		t.Errorf("Run() did not warn, got %q", stderr.String())
+	}
+}
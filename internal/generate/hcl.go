@@ -0,0 +1,102 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package generate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// reference is an expression written as is, such as the address of a
+// resource.
+type reference string
+
+// body is the list of the attributes of a block or an object, in order.
+type body struct {
+	names  []string
+	values []any
+}
+
+// set appends the attribute name, its value being a string, an int64, a
+// bool, a reference, a nested body or a list of them.
+func (b *body) set(name string, value any) {
+	b.names = append(b.names, name)
+	b.values = append(b.values, value)
+}
+
+// render writes the attributes one per line, with the equal signs of the
+// consecutive single line attributes aligned like terraform fmt does.
+func (b *body) render(indent string) string {
+	values := make([]string, len(b.values))
+	for i, value := range b.values {
+		values[i] = expression(value, indent)
+	}
+	var out strings.Builder
+	for start := 0; start < len(values); {
+		end := start + 1
+		if !strings.Contains(values[start], "\n") {
+			for end < len(values) && !strings.Contains(values[end], "\n") {
+				end++
+			}
+		}
+		width := 0
+		for i := start; i < end; i++ {
+			width = max(width, len(b.names[i]))
+		}
+		for i := start; i < end; i++ {
+			out.WriteString(fmt.Sprintf("%s%-*s = %s\n", indent, width, b.names[i], values[i]))
+		}
+		start = end
+	}
+	return out.String()
+}
+
+// expression returns the HCL expression of value, the lines after the first
+// one being indented by indent.
+func expression(value any, indent string) string {
+	switch value := value.(type) {
+	case string:
+		return quote(value)
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case bool:
+		return strconv.FormatBool(value)
+	case reference:
+		return string(value)
+	case *body:
+		if len(value.names) == 0 {
+			return "{}"
+		}
+		return "{\n" + value.render(indent+"  ") + indent + "}"
+	case []string:
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = quote(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case []int64:
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = strconv.FormatInt(item, 10)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case []*body:
+		var out strings.Builder
+		out.WriteString("[\n")
+		for _, item := range value {
+			out.WriteString(indent + "  " + expression(item, indent+"  ") + ",\n")
+		}
+		return out.String() + indent + "]"
+	}
+	panic(fmt.Sprintf("unexpected value %T", value))
+}
+
+// quote returns the HCL string literal of s, escaping the template
+// sequences.
+func quote(s string) string {
+	s = strconv.Quote(s)
+	s = strings.ReplaceAll(s, "${", "$${")
+	return strings.ReplaceAll(s, "%{", "%%{")
+}
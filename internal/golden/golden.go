@@ -0,0 +1,42 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+// Package golden compares the output of the tests with the golden files kept
+// in the testdata directory of the package under test.
+package golden
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// update rewrites the golden files with the output of the tests, run
+// go test <package> -update after a deliberate change and review the diff of
+// testdata.
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// Check compares got with testdata/<name of the test>.golden, or rewrites the
+// file with -update.
+func Check(t *testing.T, got string) {
+	t.Helper()
+	file := filepath.Join("testdata", filepath.FromSlash(t.Name())+".golden")
+	if *update {
+		err := os.MkdirAll(filepath.Dir(file), 0o755)
+		if err == nil {
+			err = os.WriteFile(file, []byte(got), 0o644)
+		}
+		if err != nil {
+			t.Fatalf("failed to update %s: %s", file, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("failed to read %s, run the tests with -update to create it: %s", file, err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs, run the tests with -update to accept the change\ngot:\n%s\nwant:\n%s", file, got, want)
+	}
+}
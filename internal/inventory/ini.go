@@ -0,0 +1,198 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package inventory
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseINI parses an inventory in the INI format of Ansible: hosts listed
+// before the first section are ungrouped, [group] sections list hosts with
+// their variables, [group:vars] the variables of a group and
+// [group:children] its child groups.
+func ParseINI(content []byte) (*Inventory, error) {
+	b := newBuilder()
+	section, kind := "ungrouped", "hosts"
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid section %s", i+1, line)
+			}
+			section, kind = line[1:len(line)-1], "hosts"
+			if name, suffix, ok := strings.Cut(section, ":"); ok {
+				section, kind = name, suffix
+			}
+			if section == "" || (kind != "hosts" && kind != "vars" && kind != "children") {
+				return nil, fmt.Errorf("line %d: invalid section %s, expected [group], [group:vars] or [group:children]", i+1, line)
+			}
+			b.group(section)
+			continue
+		}
+
+		switch kind {
+		case "hosts":
+			fields, err := split(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			if len(fields) == 0 {
+				continue
+			}
+			vars := map[string]string{}
+			for _, field := range fields[1:] {
+				key, value, ok := strings.Cut(field, "=")
+				if !ok || key == "" {
+					return nil, fmt.Errorf("line %d: expected a variable of the form key=value, got %q", i+1, field)
+				}
+				vars[key] = value
+			}
+			pattern := fields[0]
+			// A single colon separates the SSH port, IPv6 addresses hold
+			// several.
+			if name, port, ok := strings.Cut(pattern, ":"); ok && !strings.Contains(port, ":") {
+				if _, err := strconv.Atoi(port); err == nil {
+					pattern = name
+					vars["ansible_port"] = port
+				}
+			}
+			names, err := expand(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			for _, name := range names {
+				b.host(section, name, vars)
+			}
+		case "vars":
+			key, value, ok := strings.Cut(line, "=")
+			key = strings.TrimSpace(key)
+			if !ok || key == "" {
+				return nil, fmt.Errorf("line %d: expected a variable of the form key=value, got %q", i+1, line)
+			}
+			b.group(section).Vars[key] = unquote(strings.TrimSpace(value))
+		case "children":
+			err := b.child(section, line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+		}
+	}
+	return b.build()
+}
+
+// split splits a host line on blanks, keeping the quoted parts of a field
+// together, until a comment.
+func split(line string) ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	var quote rune
+	inField := false
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				field.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inField = true
+		case r == ' ' || r == '\t':
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		case r == '#' && !inField:
+			return fields, nil
+		default:
+			field.WriteRune(r)
+			inField = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %s", line)
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields, nil
+}
+
+// unquote removes the quotes around a value.
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// expand expands the ranges of a host pattern, like switch[01:03] or
+// rack-[a:c]-[1:9:2].
+func expand(pattern string) ([]string, error) {
+	start := strings.Index(pattern, "[")
+	if start < 0 {
+		return []string{pattern}, nil
+	}
+	end := strings.Index(pattern[start:], "]")
+	if end < 0 {
+		return nil, fmt.Errorf("unterminated range in %s", pattern)
+	}
+	end += start
+	bounds := strings.Split(pattern[start+1:end], ":")
+	if len(bounds) != 2 && len(bounds) != 3 {
+		return nil, fmt.Errorf("invalid range in %s, expected [start:end] or [start:end:stride]", pattern)
+	}
+	stride := 1
+	if len(bounds) == 3 {
+		var err error
+		stride, err = strconv.Atoi(bounds[2])
+		if err != nil || stride < 1 {
+			return nil, fmt.Errorf("invalid stride in %s", pattern)
+		}
+	}
+
+	var values []string
+	first, firstErr := strconv.Atoi(bounds[0])
+	last, lastErr := strconv.Atoi(bounds[1])
+	switch {
+	case firstErr == nil && lastErr == nil && first <= last:
+		// A leading zero pads the numbers to the width of the start.
+		width := 0
+		if len(bounds[0]) > 1 && bounds[0][0] == '0' {
+			width = len(bounds[0])
+		}
+		for i := first; i <= last; i += stride {
+			values = append(values, fmt.Sprintf("%0*d", width, i))
+		}
+	case len(bounds[0]) == 1 && len(bounds[1]) == 1 && bounds[0] <= bounds[1] && firstErr != nil && lastErr != nil:
+		for c := bounds[0][0]; c <= bounds[1][0]; c += byte(stride) {
+			values = append(values, string(c))
+			if int(c)+stride > 255 {
+				break
+			}
+		}
+	default:
+		return nil, fmt.Errorf("invalid range in %s", pattern)
+	}
+
+	rests, err := expand(pattern[end+1:])
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, value := range values {
+		for _, rest := range rests {
+			names = append(names, pattern[:start]+value+rest)
+		}
+	}
+	return names, nil
+}
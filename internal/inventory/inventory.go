@@ -0,0 +1,262 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+// Package inventory reads the hosts and groups of an Ansible inventory, in
+// its YAML or INI format, and resolves the variables of each host the way
+// Ansible does.
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Inventory is the hosts and groups of an Ansible inventory.
+type Inventory struct {
+	Hosts  map[string]*Host
+	Groups map[string]*Group
+}
+
+// Host is a host of the inventory. Vars holds the variables of the host once
+// merged with those of its groups.
+type Host struct {
+	Name   string
+	Vars   map[string]string
+	Groups []string
+}
+
+// Group is a group of the inventory. Hosts lists the hosts of the group and
+// of its children.
+type Group struct {
+	Name     string
+	Hosts    []string
+	Children []string
+	Vars     map[string]string
+}
+
+// builder collects the hosts, groups and variables while an inventory is
+// parsed, before the variables of each host are resolved.
+type builder struct {
+	hosts     map[string]map[string]string
+	groups    map[string]*Group
+	direct    map[string][]string
+	hostOrder []string
+}
+
+func newBuilder() *builder {
+	b := &builder{
+		hosts:  map[string]map[string]string{},
+		groups: map[string]*Group{},
+		direct: map[string][]string{},
+	}
+	b.group("all")
+	b.group("ungrouped")
+	return b
+}
+
+// group returns the group name, creating it on first use.
+func (b *builder) group(name string) *Group {
+	g, ok := b.groups[name]
+	if !ok {
+		g = &Group{Name: name, Vars: map[string]string{}}
+		b.groups[name] = g
+	}
+	return g
+}
+
+// host adds the host name to group with vars, a host listed several times
+// gathers the variables of every listing.
+func (b *builder) host(group string, name string, vars map[string]string) {
+	hostVars, ok := b.hosts[name]
+	if !ok {
+		hostVars = map[string]string{}
+		b.hosts[name] = hostVars
+		b.hostOrder = append(b.hostOrder, name)
+	}
+	for key, value := range vars {
+		hostVars[key] = value
+	}
+	b.group(group)
+	if !slices.Contains(b.direct[group], name) {
+		b.direct[group] = append(b.direct[group], name)
+	}
+}
+
+// child makes child a child group of parent.
+func (b *builder) child(parent string, child string) error {
+	if parent == child {
+		return fmt.Errorf("group %s cannot be a child of itself", parent)
+	}
+	b.group(child)
+	g := b.group(parent)
+	if !slices.Contains(g.Children, child) {
+		g.Children = append(g.Children, child)
+	}
+	return nil
+}
+
+// Load reads the inventory at path, parsed as YAML when its extension is
+// .yml, .yaml or .json and as INI otherwise, like the plugins of Ansible.
+func Load(path string) (*Inventory, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml", ".json":
+		return ParseYAML(content)
+	}
+	return ParseINI(content)
+}
+
+// build resolves the groups and the variables of the hosts.
+func (b *builder) build() (*Inventory, error) {
+	// Every group without a parent is a child of all, and the hosts of all
+	// without another group are ungrouped.
+	parents := map[string][]string{}
+	for name, g := range b.groups {
+		for _, child := range g.Children {
+			parents[child] = append(parents[child], name)
+		}
+	}
+	for name := range b.groups {
+		if name != "all" && len(parents[name]) == 0 {
+			parents[name] = []string{"all"}
+			all := b.group("all")
+			if !slices.Contains(all.Children, name) {
+				all.Children = append(all.Children, name)
+			}
+		}
+	}
+
+	depth := map[string]int{}
+	var walk func(name string, seen []string) error
+	walk = func(name string, seen []string) error {
+		if _, ok := depth[name]; ok {
+			return nil
+		}
+		if slices.Contains(seen, name) {
+			return fmt.Errorf("group %s is its own ancestor through %s", name, strings.Join(seen, ", "))
+		}
+		d := 0
+		for _, parent := range parents[name] {
+			if err := walk(parent, append(seen, name)); err != nil {
+				return err
+			}
+			d = max(d, depth[parent]+1)
+		}
+		depth[name] = d
+		return nil
+	}
+	for name := range b.groups {
+		if err := walk(name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	inv := &Inventory{Hosts: map[string]*Host{}, Groups: b.groups}
+	for _, name := range b.hostOrder {
+		groups := map[string]bool{}
+		for group, hosts := range b.direct {
+			if slices.Contains(hosts, name) {
+				ancestors(group, parents, groups)
+			}
+		}
+		if len(groups) == 0 || (len(groups) == 1 && groups["all"]) {
+			ancestors("ungrouped", parents, groups)
+			if !slices.Contains(b.direct["ungrouped"], name) {
+				b.direct["ungrouped"] = append(b.direct["ungrouped"], name)
+			}
+		}
+
+		// The variables of the groups closer to the host win over those of
+		// their ancestors, the groups of the same depth are merged by name,
+		// and the variables of the host win over all of them.
+		order := make([]string, 0, len(groups))
+		for group := range groups {
+			order = append(order, group)
+		}
+		slices.SortFunc(order, func(a, b string) int {
+			if depth[a] != depth[b] {
+				return depth[a] - depth[b]
+			}
+			return strings.Compare(a, b)
+		})
+		vars := map[string]string{}
+		for _, group := range order {
+			for key, value := range b.groups[group].Vars {
+				vars[key] = value
+			}
+		}
+		for key, value := range b.hosts[name] {
+			vars[key] = value
+		}
+		inv.Hosts[name] = &Host{Name: name, Vars: vars, Groups: order}
+	}
+
+	for name, g := range b.groups {
+		members := map[string]bool{}
+		b.members(name, members, nil)
+		g.Hosts = make([]string, 0, len(members))
+		for host := range members {
+			g.Hosts = append(g.Hosts, host)
+		}
+		slices.Sort(g.Hosts)
+		slices.Sort(g.Children)
+	}
+	for _, host := range inv.Hosts {
+		slices.Sort(host.Groups)
+	}
+	return inv, nil
+}
+
+// ancestors adds group and its ancestors to groups.
+func ancestors(group string, parents map[string][]string, groups map[string]bool) {
+	if groups[group] {
+		return
+	}
+	groups[group] = true
+	for _, parent := range parents[group] {
+		ancestors(parent, parents, groups)
+	}
+}
+
+// members adds the hosts of group and of its children to hosts.
+func (b *builder) members(group string, hosts map[string]bool, seen []string) {
+	if slices.Contains(seen, group) {
+		return
+	}
+	for _, host := range b.direct[group] {
+		hosts[host] = true
+	}
+	for _, child := range b.groups[group].Children {
+		b.members(child, hosts, append(seen, group))
+	}
+	if group == "all" {
+		for host := range b.hosts {
+			hosts[host] = true
+		}
+	}
+}
+
+// scalar returns the string of a variable, the lists and maps being encoded
+// in JSON.
+func scalar(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case int, int64, uint64, float64, bool:
+		return fmt.Sprint(value)
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
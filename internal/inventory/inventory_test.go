@@ -0,0 +1,164 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package inventory
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	ini, err := Load("testdata/hosts.ini")
+	if err != nil {
+		t.Fatalf("Load(hosts.ini) error = %s", err)
+	}
+	yml, err := Load("testdata/hosts.yml")
+	if err != nil {
+		t.Fatalf("Load(hosts.yml) error = %s", err)
+	}
+	if !reflect.DeepEqual(ini, yml) {
+		t.Errorf("the INI and YAML inventories differ:\n%+v\n%+v", ini, yml)
+	}
+
+	tests := []struct {
+		host   string
+		groups []string
+		vars   map[string]string
+	}{
+		{"jump.example.net", []string{"all", "ungrouped"}, map[string]string{
+			"ansible_connection": "ansible.netcommon.network_cli",
+			"ansible_user":       "admin",
+		}},
+		{"core1", []string{"all", "campus", "core"}, map[string]string{
+			"ansible_connection": "ansible.netcommon.network_cli",
+			"ansible_user":       "netops",
+			"ansible_network_os": "cisco.ios.ios",
+			"ansible_host":       "10.0.0.1",
+		}},
+		{"core2", []string{"all", "campus", "core"}, map[string]string{
+			"ansible_connection": "ansible.netcommon.network_cli",
+			"ansible_user":       "netops",
+			"ansible_network_os": "cisco.ios.ios",
+			"ansible_host":       "10.0.0.2",
+			"ansible_port":       "2222",
+		}},
+		{"access01", []string{"access", "all", "campus"}, map[string]string{
+			"ansible_connection": "ansible.netcommon.network_cli",
+			"ansible_user":       "operator",
+			"ansible_network_os": "cisco.ios.ios",
+			"ansible_host":       "10.0.1.11",
+			"site":               "building a",
+		}},
+		{"access03", []string{"access", "all", "campus"}, map[string]string{
+			"ansible_connection": "ansible.netcommon.network_cli",
+			"ansible_user":       "operator",
+			"ansible_network_os": "cisco.ios.ios",
+			"ansible_host":       "10.0.1.1",
+		}},
+		{"dc1", []string{"all", "nexus"}, map[string]string{
+			"ansible_connection": "ansible.netcommon.network_cli",
+			"ansible_user":       "admin",
+			"ansible_network_os": "cisco.nxos.nxos",
+			"ansible_host":       "10.0.2.1",
+		}},
+	}
+	for _, tt := range tests {
+		host, ok := ini.Hosts[tt.host]
+		if !ok {
+			t.Errorf("host %s is missing", tt.host)
+			continue
+		}
+		if !reflect.DeepEqual(host.Groups, tt.groups) {
+			t.Errorf("%s groups = %q, want %q", tt.host, host.Groups, tt.groups)
+		}
+		if !reflect.DeepEqual(host.Vars, tt.vars) {
+			t.Errorf("%s vars = %v, want %v", tt.host, host.Vars, tt.vars)
+		}
+	}
+	if len(ini.Hosts) != 7 {
+		t.Errorf("the inventory holds %d hosts, want 7", len(ini.Hosts))
+	}
+
+	campus := ini.Groups["campus"]
+	if want := []string{"access01", "access02", "access03", "core1", "core2"}; !reflect.DeepEqual(campus.Hosts, want) {
+		t.Errorf("campus hosts = %q, want %q", campus.Hosts, want)
+	}
+	if want := []string{"access", "core"}; !reflect.DeepEqual(campus.Children, want) {
+		t.Errorf("campus children = %q, want %q", campus.Children, want)
+	}
+	if want := []string{"campus", "nexus", "ungrouped"}; !reflect.DeepEqual(ini.Groups["all"].Children, want) {
+		t.Errorf("all children = %q, want %q", ini.Groups["all"].Children, want)
+	}
+	if n := len(ini.Groups["all"].Hosts); n != 7 {
+		t.Errorf("all holds %d hosts, want 7", n)
+	}
+}
+
+func TestLoadVault(t *testing.T) {
+	// The values encrypted with Ansible Vault are kept as they are, the
+	// provider cannot decrypt them.
+	for _, path := range []string{"testdata/vault.yml", "testdata/vault.ini"} {
+		inventory, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load(%s) error = %s", path, err)
+		}
+		host, ok := inventory.Hosts["core1"]
+		if !ok {
+			t.Fatalf("%s: host core1 is missing", path)
+		}
+		for _, name := range []string{"ansible_password", "ansible_become_password"} {
+			if !strings.HasPrefix(host.Vars[name], "$ANSIBLE_VAULT;1.1;AES256") {
+				t.Errorf("%s: core1 %s = %q, want the vault payload", path, name, host.Vars[name])
+			}
+		}
+		if host.Vars["ansible_user"] != "admin" {
+			t.Errorf("%s: core1 ansible_user = %q, want admin", path, host.Vars["ansible_user"])
+		}
+	}
+}
+
+func TestParseINIErrors(t *testing.T) {
+	tests := []struct {
+		content string
+		err     string
+	}{
+		{"[core\ncore1", "line 1: invalid section"},
+		{"[core:hosts:vars]\n", "invalid section"},
+		{"[core]\ncore1 ansible_host", "line 2: expected a variable of the form key=value"},
+		{"[core:vars]\nansible_user", "line 2: expected a variable of the form key=value"},
+		{"core1 ansible_user='admin", "unterminated quote"},
+		{"switch[1:a]", "invalid range"},
+		{"[a:children]\nb\n[b:children]\na", "its own ancestor"},
+	}
+	for _, tt := range tests {
+		_, err := ParseINI([]byte(tt.content))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("ParseINI(%q) error = %v, want %q", tt.content, err, tt.err)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"core1", []string{"core1"}},
+		{"sw[1:3]", []string{"sw1", "sw2", "sw3"}},
+		{"sw[08:10]", []string{"sw08", "sw09", "sw10"}},
+		{"sw[1:5:2].lab", []string{"sw1.lab", "sw3.lab", "sw5.lab"}},
+		{"rack-[a:b]-[1:2]", []string{"rack-a-1", "rack-a-2", "rack-b-1", "rack-b-2"}},
+	}
+	for _, tt := range tests {
+		got, err := expand(tt.pattern)
+		if err != nil {
+			t.Errorf("expand(%q) error = %s", tt.pattern, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expand(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package inventory
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+)
+
+// yamlGroup is a group of a YAML inventory, a host without variables being
+// listed with a null value.
+type yamlGroup struct {
+	Hosts    map[string]map[string]any `yaml:"hosts"`
+	Vars     map[string]any            `yaml:"vars"`
+	Children map[string]*yamlGroup     `yaml:"children"`
+}
+
+// ParseYAML parses an inventory in the YAML format of Ansible, whose top
+// level keys are groups, usually only all.
+func ParseYAML(content []byte) (*Inventory, error) {
+	var groups map[string]*yamlGroup
+	err := yaml.Unmarshal(content, &groups)
+	if err != nil {
+		return nil, fmt.Errorf("invalid YAML inventory: %w", err)
+	}
+	b := newBuilder()
+	for name, group := range groups {
+		err := b.yamlGroup(name, group)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b.build()
+}
+
+func (b *builder) yamlGroup(name string, group *yamlGroup) error {
+	g := b.group(name)
+	if group == nil {
+		return nil
+	}
+	for key, value := range group.Vars {
+		g.Vars[key] = scalar(value)
+	}
+	for pattern, vars := range group.Hosts {
+		hostVars := map[string]string{}
+		for key, value := range vars {
+			hostVars[key] = scalar(value)
+		}
+		hosts, err := expand(pattern)
+		if err != nil {
+			return err
+		}
+		for _, host := range hosts {
+			b.host(name, host, hostVars)
+		}
+	}
+	for child, childGroup := range group.Children {
+		err := b.child(name, child)
+		if err != nil {
+			return err
+		}
+		err = b.yamlGroup(child, childGroup)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
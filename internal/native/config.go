@@ -0,0 +1,152 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package native
+
+import (
+	"fmt"
+	"github.com/CorentinPtrl/cisconf"
+	"strconv"
+	"strings"
+)
+
+// Config returns the configuration of cisconf held by the native root, as it
+// would be parsed from the running-config, for the parts known to the
+// provider.
+func Config(root *Node) (*cisconf.Config, error) {
+	cfg := &cisconf.Config{Hostname: root.Child("hostname").String()}
+
+	for _, n := range root.Child("vlan").List("vlan-list") {
+		id, err := n.Child("id").Int(0)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Vlans = append(cfg.Vlans, cisconf.Vlan{Id: id, Name: n.Child("name").String()})
+	}
+
+	for _, kind := range root.Child("interface").Children {
+		iface, err := configInterface(kind)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Interfaces = append(cfg.Interfaces, iface)
+	}
+
+	for _, n := range root.Child("ip", "route").List("ip-route-interface-forwarding-list") {
+		for _, fwd := range n.List("fwd-list") {
+			cfg.Routes = append(cfg.Routes, cisconf.Route{
+				Prefix:    n.Child("prefix").String(),
+				Mask:      n.Child("mask").String(),
+				IpAddress: fwd.Child("fwd").String(),
+			})
+		}
+	}
+
+	for _, n := range root.Child("router").List("eigrp") {
+		asn, err := n.Child("id").Int(0)
+		if err != nil {
+			return nil, err
+		}
+		process := cisconf.Eigrp{Asn: asn}
+		for _, network := range n.List("network") {
+			wildcard := network.Child("wild-card").String()
+			if wildcard == "" {
+				wildcard = "0.0.0.255"
+			}
+			process.Network = append(process.Network, cisconf.EigrpNetwork{
+				NetworkNumber: network.Child("number").String(),
+				WildCard:      wildcard,
+			})
+		}
+		cfg.EIGRPProcess = append(cfg.EIGRPProcess, process)
+	}
+	return cfg, nil
+}
+
+func configInterface(n *Node) (cisconf.CiscoInterface, error) {
+	name := n.Name + n.Child("name").String()
+	iface := cisconf.CiscoInterface{
+		Parent:      cisconf.CiscoInterfaceParent{Identifier: name},
+		Description: n.Child("description").String(),
+		Shutdown:    n.Child("shutdown") != nil,
+		// A port is switched unless configured with no switchport, as
+		// cisconf parses it.
+		Switchport: n.Child("switchport-conf", "switchport").String() != "false",
+		AccessVlan: 1,
+	}
+	if _, sub, ok := strings.Cut(name, "."); ok {
+		subInterface, err := strconv.Atoi(sub)
+		if err != nil {
+			return iface, fmt.Errorf("invalid subinterface %s: %w", name, err)
+		}
+		iface.Parent.SubInterface = subInterface
+	}
+
+	switchport := n.Child("switchport")
+	iface.Access = switchport.Child("mode", "access") != nil
+	iface.Trunk = switchport.Child("mode", "trunk") != nil
+	accessVlan, err := switchport.Child("access", "vlan", "vlan").Int(1)
+	if err != nil {
+		return iface, err
+	}
+	iface.AccessVlan = accessVlan
+	iface.Encapsulation = switchport.Child("trunk", "encapsulation").String()
+	if vlans := switchport.Child("trunk", "allowed", "vlan", "vlans").String(); vlans != "" {
+		iface.TrunkAllowedVlan, err = expandVlans(vlans)
+		if err != nil {
+			return iface, err
+		}
+	}
+
+	if portfast := n.Child("spanning-tree", "portfast"); portfast != nil && len(portfast.Children) > 0 {
+		iface.STPPortFast = portfast.Children[0].Name
+	}
+	if bpduguard := n.Child("spanning-tree", "bpduguard"); bpduguard != nil && len(bpduguard.Children) > 0 {
+		iface.STPBpduGuard = bpduguard.Children[0].Name
+	}
+
+	address := n.Child("ip", "address")
+	if address.Child("dhcp") != nil {
+		iface.Ips = append(iface.Ips, cisconf.Ip{DHCP: true})
+	}
+	if primary := address.Child("primary"); primary != nil {
+		iface.Ips = append(iface.Ips, cisconf.Ip{
+			Ip:     primary.Child("address").String(),
+			Subnet: primary.Child("mask").String(),
+		})
+	}
+	for _, secondary := range address.List("secondary") {
+		iface.Ips = append(iface.Ips, cisconf.Ip{
+			Ip:        secondary.Child("address").String(),
+			Subnet:    secondary.Child("mask").String(),
+			Secondary: true,
+		})
+	}
+	for _, helper := range n.Child("ip").List("helper-address") {
+		iface.IPHelperAddresses = append(iface.IPHelperAddresses, helper.Child("address").String())
+	}
+	return iface, nil
+}
+
+// expandVlans expands a list of vlans such as 10,20-22 the way cisconf does.
+func expandVlans(text string) ([]int, error) {
+	var vlans []int
+	for _, part := range strings.Split(text, ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		start, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vlan list %q: %w", text, err)
+		}
+		end := start
+		if isRange {
+			end, err = strconv.Atoi(last)
+			if err != nil {
+				return nil, fmt.Errorf("invalid vlan list %q: %w", text, err)
+			}
+		}
+		for id := start; id <= end; id++ {
+			vlans = append(vlans, id)
+		}
+	}
+	return vlans, nil
+}
@@ -0,0 +1,188 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package native
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EncodeJSON encodes n as the body of a RESTCONF request, following the JSON
+// encoding of YANG data of RFC 7951. module is the module defining n, its
+// name is qualified with it. A list entry is wrapped in an array.
+func EncodeJSON(module string, n *Node) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("{")
+	err := encodeMembers(&b, module, []*Node{n}, true)
+	if err != nil {
+		return nil, err
+	}
+	b.WriteString("}")
+	return b.Bytes(), nil
+}
+
+// encodeMembers writes nodes as the members of an object, the entries of a
+// list grouped in an array at the place of the first one.
+func encodeMembers(b *bytes.Buffer, module string, nodes []*Node, qualify bool) error {
+	done := map[string]bool{}
+	first := true
+	for _, n := range nodes {
+		if done[n.Name] {
+			continue
+		}
+		done[n.Name] = true
+		if !first {
+			b.WriteString(",")
+		}
+		first = false
+
+		name := n.Name
+		childModule := module
+		if n.Module != "" {
+			childModule = n.Module
+		}
+		if qualify || childModule != module {
+			name = childModule + ":" + name
+		}
+		key, _ := json.Marshal(name)
+		b.Write(key)
+		b.WriteString(":")
+
+		if !n.IsEntry() {
+			err := encodeValue(b, childModule, n)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		b.WriteString("[")
+		count := 0
+		for _, entry := range nodes {
+			if entry.Name != n.Name {
+				continue
+			}
+			if count > 0 {
+				b.WriteString(",")
+			}
+			count++
+			err := encodeValue(b, childModule, entry)
+			if err != nil {
+				return err
+			}
+		}
+		b.WriteString("]")
+	}
+	return nil
+}
+
+func encodeValue(b *bytes.Buffer, module string, n *Node) error {
+	switch value := n.Value.(type) {
+	case nil:
+		b.WriteString("{")
+		err := encodeMembers(b, module, n.Children, false)
+		if err != nil {
+			return err
+		}
+		b.WriteString("}")
+		return nil
+	case Empty:
+		b.WriteString("[null]")
+		return nil
+	case string, bool, int, json.Number:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		b.Write(data)
+		return nil
+	}
+	return fmt.Errorf("leaf %s has a value of unsupported type %T", n.Name, n.Value)
+}
+
+// DecodeJSON decodes the body of a RESTCONF response or request, holding a
+// single top-level member. The numbers are kept as json.Number.
+func DecodeJSON(data []byte) (*Node, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var members map[string]any
+	err := decoder.Decode(&members)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the data tree: %w", err)
+	}
+	nodes, err := decodeMembers("", members)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) != 1 {
+		return nil, fmt.Errorf("expected a single top-level node, got %d", len(nodes))
+	}
+	return nodes[0], nil
+}
+
+// decodeMembers decodes the members of an object, sorted by name since the
+// order of the members of a JSON object is lost.
+func decodeMembers(parent string, members map[string]any) ([]*Node, error) {
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var nodes []*Node
+	for _, qualified := range names {
+		module, name := "", qualified
+		if i := strings.Index(qualified, ":"); i >= 0 {
+			module, name = qualified[:i], qualified[i+1:]
+		}
+		decoded, err := decodeValue(parent, name, members[qualified])
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range decoded {
+			n.Module = module
+		}
+		nodes = append(nodes, decoded...)
+	}
+	return nodes, nil
+}
+
+func decodeValue(parent string, name string, value any) ([]*Node, error) {
+	switch value := value.(type) {
+	case map[string]any:
+		children, err := decodeMembers(name, value)
+		if err != nil {
+			return nil, err
+		}
+		return []*Node{container(name, children...)}, nil
+	case []any:
+		if len(value) == 1 && value[0] == nil {
+			return []*Node{leaf(name, Empty{})}, nil
+		}
+		var nodes []*Node
+		for _, item := range value {
+			members, ok := item.(map[string]any)
+			if !ok {
+				// A leaf-list.
+				nodes = append(nodes, leaf(name, item))
+				continue
+			}
+			children, err := decodeMembers(name, members)
+			if err != nil {
+				return nil, err
+			}
+			keys := keysOf(parent, name)
+			if keys == nil {
+				keys = []string{}
+			}
+			nodes = append(nodes, entry(name, keys, children...))
+		}
+		return nodes, nil
+	case string, bool, json.Number:
+		return []*Node{leaf(name, value)}, nil
+	}
+	return nil, fmt.Errorf("node %s has a value of unsupported type %T", name, value)
+}
@@ -0,0 +1,311 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package native
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/CorentinPtrl/cisconf"
+)
+
+const running = `hostname Switch
+!
+vlan 10
+ name users
+!
+vlan 20
+ name voice
+!
+interface GigabitEthernet1/0/1
+ description uplink
+ switchport trunk encapsulation dot1q
+ switchport trunk allowed vlan 10,20-22
+ switchport mode trunk
+!
+interface GigabitEthernet1/0/2
+ switchport access vlan 10
+ switchport mode access
+ spanning-tree portfast edge
+ spanning-tree bpduguard enable
+!
+interface Vlan10
+ no switchport
+ ip address 10.0.10.1 255.255.255.0
+ ip address 10.0.11.1 255.255.255.0 secondary
+ ip helper-address 10.0.0.5
+ shutdown
+!
+router eigrp 100
+ network 10.0.0.0 0.0.255.255
+ network 192.168.1.0
+!
+ip route 0.0.0.0 0.0.0.0 192.168.0.1
+ip route 10.1.0.0 255.255.0.0 10.0.10.254
+`
+
+func parse(t *testing.T, text string) *cisconf.Config {
+	t.Helper()
+	cfg := &cisconf.Config{}
+	err := cisconf.Unmarshal(text, cfg)
+	if err != nil {
+		t.Fatalf("cisconf.Unmarshal() error = %s", err)
+	}
+	return cfg
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	cfg := parse(t, running)
+	tree, err := FromConfig(cfg)
+	if err != nil {
+		t.Fatalf("FromConfig() error = %s", err)
+	}
+	data, err := EncodeJSON(Module, tree)
+	if err != nil {
+		t.Fatalf("EncodeJSON() error = %s", err)
+	}
+	for _, want := range []string{
+		`"Cisco-IOS-XE-native:native":{`,
+		`"Cisco-IOS-XE-vlan:vlan-list":[{"id":10,"name":"users"},{"id":20,"name":"voice"}]`,
+		`"shutdown":[null]`,
+		`"vlans":"10,20,21,22"`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("EncodeJSON() = %s, missing %s", data, want)
+		}
+	}
+
+	decoded, err := DecodeJSON(data)
+	if err != nil {
+		t.Fatalf("DecodeJSON() error = %s", err)
+	}
+	got, err := Config(decoded)
+	if err != nil {
+		t.Fatalf("Config() error = %s", err)
+	}
+	if !reflect.DeepEqual(got, cfg) {
+		t.Errorf("Config() = %+v\nwant %+v", got, cfg)
+	}
+}
+
+func TestPath(t *testing.T) {
+	iface := cisconf.CiscoInterface{Parent: cisconf.CiscoInterfaceParent{Identifier: "GigabitEthernet1/0/1"}}
+	patch, err := Remove(iface)
+	if err != nil {
+		t.Fatalf("Remove() error = %s", err)
+	}
+	if len(patch.Reset) != 1 {
+		t.Fatalf("Remove() = %s, want a reset of the interface", patch)
+	}
+	text := patch.Reset[0].String()
+	want := "Cisco-IOS-XE-native:native/interface/GigabitEthernet=1%2F0%2F1"
+	if text != want {
+		t.Errorf("Path.String() = %s, want %s", text, want)
+	}
+	path, err := ParsePath(text)
+	if err != nil {
+		t.Fatalf("ParsePath() error = %s", err)
+	}
+	if path.String() != text || path.Last().Child("name").String() != "1/0/1" {
+		t.Errorf("ParsePath() = %s, want %s", path, text)
+	}
+
+	_, err = ParsePath("Cisco-IOS-XE-native:native/hostname=Switch")
+	if err == nil {
+		t.Errorf("ParsePath() accepts the key of a leaf")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	cfg := parse(t, running)
+	access := cfg.Interfaces[1]
+	trunk := access
+	trunk.Access = false
+	trunk.Trunk = true
+	trunk.TrunkAllowedVlan = []int{10, 30}
+	trunk.STPPortFast = ""
+
+	tests := []struct {
+		name   string
+		src    any
+		dest   any
+		delete []string
+	}{
+		{name: "create", src: nil, dest: cisconf.Vlan{Id: 30, Name: "printers"}},
+		{name: "rename", src: cfg.Vlans[0], dest: cisconf.Vlan{Id: 10, Name: "staff"}},
+		{
+			name: "next hop",
+			src:  cisconf.RoutesType{Routes: cfg.Routes[1:]},
+			dest: cisconf.RoutesType{Routes: []cisconf.Route{{Prefix: "10.1.0.0", Mask: "255.255.0.0", IpAddress: "10.0.10.253"}}},
+			delete: []string{
+				"Cisco-IOS-XE-native:native/ip/route/ip-route-interface-forwarding-list=10.1.0.0,255.255.0.0/fwd-list=10.0.10.254",
+			},
+		},
+		{
+			name: "network removed",
+			src:  cfg.EIGRPProcess[0],
+			dest: cisconf.Eigrp{Asn: 100, Network: cfg.EIGRPProcess[0].Network[:1]},
+			delete: []string{
+				"Cisco-IOS-XE-native:native/router/Cisco-IOS-XE-eigrp:eigrp=100/network=192.168.1.0",
+			},
+		},
+		{
+			name: "access to trunk",
+			src:  access,
+			dest: trunk,
+			delete: []string{
+				"Cisco-IOS-XE-native:native/interface/GigabitEthernet=1%2F0%2F2/Cisco-IOS-XE-switch:switchport/mode/access",
+				"Cisco-IOS-XE-native:native/interface/GigabitEthernet=1%2F0%2F2/Cisco-IOS-XE-switch:switchport/access",
+				"Cisco-IOS-XE-native:native/interface/GigabitEthernet=1%2F0%2F2/Cisco-IOS-XE-spanning-tree:spanning-tree/portfast",
+			},
+		},
+		{name: "unchanged", src: cfg.Interfaces[2], dest: cfg.Interfaces[2]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := Diff(tt.src, tt.dest)
+			if err != nil {
+				t.Fatalf("Diff() error = %s", err)
+			}
+			var deletes []string
+			for _, path := range patch.Delete {
+				deletes = append(deletes, path.String())
+			}
+			if !reflect.DeepEqual(deletes, tt.delete) {
+				t.Errorf("Diff() deletes %q, want %q", deletes, tt.delete)
+			}
+			if tt.name == "unchanged" && !patch.IsEmpty() {
+				t.Errorf("Diff() = %s, want an empty patch", patch)
+			}
+
+			// The patch applied to src gives the tree of dest.
+			tree, err := Object(tt.src)
+			if err != nil {
+				t.Fatalf("Object() error = %s", err)
+			}
+			Apply(tree, patch)
+			want, err := Object(tt.dest)
+			if err != nil {
+				t.Fatalf("Object() error = %s", err)
+			}
+			if after, _ := Diff(tree, want); !after.IsEmpty() {
+				t.Errorf("the patch applied differs from dest:\n%s", after)
+			}
+		})
+	}
+}
+
+func TestRemove(t *testing.T) {
+	tree, err := FromConfig(parse(t, running))
+	if err != nil {
+		t.Fatalf("FromConfig() error = %s", err)
+	}
+	for _, obj := range []any{
+		cisconf.Vlan{Id: 10},
+		cisconf.RoutesType{Routes: []cisconf.Route{{Prefix: "10.1.0.0", Mask: "255.255.0.0", IpAddress: "10.0.10.254"}}},
+		cisconf.Eigrp{Asn: 100},
+		cisconf.CiscoInterface{Parent: cisconf.CiscoInterfaceParent{Identifier: "Vlan10"}},
+	} {
+		patch, err := Remove(obj)
+		if err != nil {
+			t.Fatalf("Remove() error = %s", err)
+		}
+		Apply(tree, patch)
+	}
+	cfg, err := Config(tree)
+	if err != nil {
+		t.Fatalf("Config() error = %s", err)
+	}
+	if len(cfg.Vlans) != 1 || len(cfg.Routes) != 1 || len(cfg.EIGRPProcess) != 0 {
+		t.Errorf("Remove() left %+v", cfg)
+	}
+	svi := cfg.Interfaces[2]
+	if svi.Parent.Identifier != "Vlan10" || svi.Shutdown || len(svi.Ips) != 0 || !svi.Switchport {
+		t.Errorf("Remove() of the interface left %+v, want its defaults", svi)
+	}
+}
+
+func TestXMLRoundTrip(t *testing.T) {
+	cfg := parse(t, running)
+	tree, err := FromConfig(cfg)
+	if err != nil {
+		t.Fatalf("FromConfig() error = %s", err)
+	}
+	data := EncodeXML(tree)
+	for _, want := range []string{
+		`<native xmlns="http://cisco.com/ns/yang/Cisco-IOS-XE-native">`,
+		`<vlan-list xmlns="http://cisco.com/ns/yang/Cisco-IOS-XE-vlan"><id>10</id><name>users</name></vlan-list>`,
+		`<shutdown/>`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("EncodeXML() = %s, missing %s", data, want)
+		}
+	}
+	decoded, err := DecodeXML(data)
+	if err != nil {
+		t.Fatalf("DecodeXML() error = %s", err)
+	}
+	got, err := Config(decoded)
+	if err != nil {
+		t.Fatalf("Config() error = %s", err)
+	}
+	if !reflect.DeepEqual(got, cfg) {
+		t.Errorf("Config() = %+v\nwant %+v", got, cfg)
+	}
+}
+
+func TestEditXML(t *testing.T) {
+	cfg := parse(t, running)
+	trunk := cfg.Interfaces[1]
+	trunk.Access = false
+	trunk.Trunk = true
+	trunk.TrunkAllowedVlan = []int{10, 30}
+	patch, err := Diff(cfg.Interfaces[1], trunk)
+	if err != nil {
+		t.Fatalf("Diff() error = %s", err)
+	}
+	removed, err := Remove(cisconf.Vlan{Id: 20})
+	if err != nil {
+		t.Fatalf("Remove() error = %s", err)
+	}
+	patch.Delete = append(patch.Delete, removed.Delete...)
+	reset, err := Remove(cfg.Interfaces[2])
+	if err != nil {
+		t.Fatalf("Remove() error = %s", err)
+	}
+	patch.Reset = reset.Reset
+
+	data := EncodeEditXML(patch)
+	if !strings.Contains(string(data), `<vlan-list xmlns="http://cisco.com/ns/yang/Cisco-IOS-XE-vlan" xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0" nc:operation="remove"><id>20</id></vlan-list>`) {
+		t.Errorf("EncodeEditXML() = %s, missing the removal of vlan 20", data)
+	}
+	decoded, err := DecodeEditXML(data)
+	if err != nil {
+		t.Fatalf("DecodeEditXML() error = %s", err)
+	}
+	if len(decoded.Delete) != len(patch.Delete) || len(decoded.Reset) != 1 || decoded.Merge == nil {
+		t.Fatalf("DecodeEditXML() = %s\nwant %s", decoded, patch)
+	}
+
+	tree, err := FromConfig(cfg)
+	if err != nil {
+		t.Fatalf("FromConfig() error = %s", err)
+	}
+	Apply(tree, decoded)
+	got, err := Config(tree)
+	if err != nil {
+		t.Fatalf("Config() error = %s", err)
+	}
+	if len(got.Vlans) != 1 || got.Interfaces[2].Shutdown || len(got.Interfaces[2].Ips) != 0 {
+		t.Errorf("the decoded edit applied gives %+v", got)
+	}
+	want, err := Object(trunk)
+	if err != nil {
+		t.Fatalf("Object() error = %s", err)
+	}
+	if after, _ := Diff(got.Interfaces[1], want); !after.IsEmpty() {
+		t.Errorf("the decoded edit applied differs from dest:\n%s", after)
+	}
+}
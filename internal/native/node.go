@@ -0,0 +1,236 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+// Package native maps the configuration modelled by the provider to the
+// Cisco-IOS-XE-native YANG data tree, the model the RESTCONF and NETCONF
+// servers of IOS-XE devices expose, and computes the edits of the tree
+// turning a configuration into another.
+package native
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Module is the YANG module of the root of the data tree.
+const Module = "Cisco-IOS-XE-native"
+
+// Modules augmenting the native tree with the features of the provider.
+const (
+	vlanModule         = "Cisco-IOS-XE-vlan"
+	switchModule       = "Cisco-IOS-XE-switch"
+	spanningTreeModule = "Cisco-IOS-XE-spanning-tree"
+	eigrpModule        = "Cisco-IOS-XE-eigrp"
+)
+
+// Node is a container, a list entry or a leaf of the data tree.
+type Node struct {
+	// Module is the YANG module defining the node, empty when it is the
+	// module of its parent.
+	Module string
+	Name   string
+	// Value is the value of a leaf: a string, a number, a bool or Empty.
+	// It is nil for the containers and the list entries.
+	Value any
+	// Keys names the key leaves of a list entry, nil for the other nodes.
+	Keys     []string
+	Children []*Node
+}
+
+// Empty is the value of the leaves of the empty type, set by their presence.
+type Empty struct{}
+
+// listKeys are the keys of the lists of the tree, by list name. The entries
+// of the interface lists are keyed by name.
+var listKeys = map[string][]string{
+	"vlan-list":                          {"id"},
+	"ip-route-interface-forwarding-list": {"prefix", "mask"},
+	"fwd-list":                           {"fwd"},
+	"eigrp":                              {"id"},
+	"network":                            {"number"},
+	"secondary":                          {"address"},
+	"helper-address":                     {"address"},
+}
+
+// keysOf returns the keys of the list name under parent, nil when name is not
+// a list.
+func keysOf(parent string, name string) []string {
+	if parent == "interface" {
+		return []string{"name"}
+	}
+	return listKeys[name]
+}
+
+func container(name string, children ...*Node) *Node {
+	return (&Node{Name: name}).add(children...)
+}
+
+func entry(name string, keys []string, children ...*Node) *Node {
+	return (&Node{Name: name, Keys: keys}).add(children...)
+}
+
+func leaf(name string, value any) *Node {
+	return &Node{Name: name, Value: value}
+}
+
+// in sets the module defining n.
+func (n *Node) in(module string) *Node {
+	n.Module = module
+	return n
+}
+
+// add appends the children not nil.
+func (n *Node) add(children ...*Node) *Node {
+	for _, child := range children {
+		if child != nil {
+			n.Children = append(n.Children, child)
+		}
+	}
+	return n
+}
+
+// IsLeaf reports whether n is a leaf.
+func (n *Node) IsLeaf() bool {
+	return n.Value != nil
+}
+
+// IsEntry reports whether n is a list entry.
+func (n *Node) IsEntry() bool {
+	return n.Keys != nil
+}
+
+// Child returns the node at the end of names under n, nil when missing.
+func (n *Node) Child(names ...string) *Node {
+	for _, name := range names {
+		if n == nil {
+			return nil
+		}
+		var next *Node
+		for _, child := range n.Children {
+			if child.Name == name {
+				next = child
+				break
+			}
+		}
+		n = next
+	}
+	return n
+}
+
+// List returns the children of n named name.
+func (n *Node) List(name string) []*Node {
+	if n == nil {
+		return nil
+	}
+	var result []*Node
+	for _, child := range n.Children {
+		if child.Name == name {
+			result = append(result, child)
+		}
+	}
+	return result
+}
+
+// String returns the value of a leaf as text, empty for a missing leaf.
+func (n *Node) String() string {
+	if n == nil || n.Value == nil {
+		return ""
+	}
+	if _, ok := n.Value.(Empty); ok {
+		return ""
+	}
+	return fmt.Sprint(n.Value)
+}
+
+// Int returns the value of a numeric leaf, def when the leaf is missing.
+func (n *Node) Int(def int) (int, error) {
+	if n == nil || n.Value == nil {
+		return def, nil
+	}
+	value, err := strconv.Atoi(n.String())
+	if err != nil {
+		return 0, fmt.Errorf("leaf %s is not a number: %w", n.Name, err)
+	}
+	return value, nil
+}
+
+// KeyValues returns the values of the key leaves of a list entry.
+func (n *Node) KeyValues() []string {
+	values := make([]string, len(n.Keys))
+	for i, key := range n.Keys {
+		values[i] = n.Child(key).String()
+	}
+	return values
+}
+
+// same reports whether n and other are the same node of the tree: the same
+// container or leaf, or the entries of a list with the same keys.
+func (n *Node) same(other *Node) bool {
+	if n.Name != other.Name || n.IsEntry() != other.IsEntry() {
+		return false
+	}
+	if !n.IsEntry() {
+		return true
+	}
+	a, b := n.KeyValues(), other.KeyValues()
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// find returns the child of n which is the same node as other.
+func (n *Node) find(other *Node) *Node {
+	if n == nil {
+		return nil
+	}
+	for _, child := range n.Children {
+		if child.same(other) {
+			return child
+		}
+	}
+	return nil
+}
+
+// Clone returns a deep copy of n.
+func (n *Node) Clone() *Node {
+	clone := *n
+	clone.Children = nil
+	for _, child := range n.Children {
+		clone.Children = append(clone.Children, child.Clone())
+	}
+	return &clone
+}
+
+// keysOnly returns a copy of the list entry n holding its key leaves alone.
+func (n *Node) keysOnly() *Node {
+	clone := &Node{Module: n.Module, Name: n.Name, Keys: n.Keys}
+	for _, key := range n.Keys {
+		if child := n.Child(key); child != nil {
+			clone.Children = append(clone.Children, child.Clone())
+		}
+	}
+	return clone
+}
+
+// Merge merges src into dst the way an edit-config merge or a RESTCONF PATCH
+// does: the leaves of src replace those of dst, and the missing nodes are
+// created.
+func Merge(dst *Node, src *Node) {
+	for _, child := range src.Children {
+		existing := dst.find(child)
+		switch {
+		case existing == nil:
+			dst.Children = append(dst.Children, child.Clone())
+		case child.IsLeaf():
+			existing.Value = child.Value
+		default:
+			Merge(existing, child)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package native
+
+import (
+	"fmt"
+	"github.com/CorentinPtrl/cisconf"
+	"strconv"
+	"strings"
+)
+
+// Object returns the data tree, from the native root, configuring obj: a
+// vlan, a route, an EIGRP process, an interface or a whole config of cisconf.
+// A native root is returned as is.
+func Object(obj any) (*Node, error) {
+	root := container("native").in(Module)
+	switch obj := obj.(type) {
+	case nil:
+	case *Node:
+		return obj, nil
+	case cisconf.Vlan:
+		root.add(container("vlan", vlan(obj)))
+	case cisconf.Route:
+		root.add(container("ip", container("route", route(obj))))
+	case cisconf.RoutesType:
+		routes := container("route")
+		for _, r := range obj.Routes {
+			Merge(routes, container("route", route(r)))
+		}
+		root.add(container("ip", routes))
+	case cisconf.Eigrp:
+		root.add(container("router", eigrp(obj)))
+	case cisconf.CiscoInterface:
+		iface, err := ciscoInterface(obj)
+		if err != nil {
+			return nil, err
+		}
+		root.add(container("interface", iface))
+	case *cisconf.CiscoInterface:
+		return Object(*obj)
+	case *cisconf.Config:
+		return FromConfig(obj)
+	default:
+		return nil, fmt.Errorf("%T has no native model", obj)
+	}
+	return root, nil
+}
+
+// FromConfig returns the data tree of the parts of cfg known to the provider.
+func FromConfig(cfg *cisconf.Config) (*Node, error) {
+	root := container("native").in(Module)
+	if cfg.Hostname != "" {
+		root.add(leaf("hostname", cfg.Hostname))
+	}
+	for _, v := range cfg.Vlans {
+		Merge(root, container("native", container("vlan", vlan(v))))
+	}
+	for _, iface := range cfg.Interfaces {
+		n, err := ciscoInterface(iface)
+		if err != nil {
+			return nil, err
+		}
+		Merge(root, container("native", container("interface", n)))
+	}
+	for _, r := range cfg.Routes {
+		// The routes of a vrf are not modelled by cisconf.
+		if r.Prefix == "" {
+			continue
+		}
+		Merge(root, container("native", container("ip", container("route", route(r)))))
+	}
+	for _, e := range cfg.EIGRPProcess {
+		Merge(root, container("native", container("router", eigrp(e))))
+	}
+	return root, nil
+}
+
+func vlan(v cisconf.Vlan) *Node {
+	n := entry("vlan-list", listKeys["vlan-list"], leaf("id", v.Id)).in(vlanModule)
+	if v.Name != "" {
+		n.add(leaf("name", v.Name))
+	}
+	return n
+}
+
+func route(r cisconf.Route) *Node {
+	return entry("ip-route-interface-forwarding-list", listKeys["ip-route-interface-forwarding-list"],
+		leaf("prefix", r.Prefix),
+		leaf("mask", r.Mask),
+		entry("fwd-list", listKeys["fwd-list"], leaf("fwd", r.IpAddress)),
+	)
+}
+
+func eigrp(e cisconf.Eigrp) *Node {
+	n := entry("eigrp", listKeys["eigrp"], leaf("id", e.Asn)).in(eigrpModule)
+	for _, network := range e.Network {
+		wildcard := network.WildCard
+		if wildcard == "" {
+			wildcard = "0.0.0.255"
+		}
+		n.add(entry("network", listKeys["network"],
+			leaf("number", network.NetworkNumber),
+			leaf("wild-card", wildcard),
+		))
+	}
+	return n
+}
+
+// SplitInterface splits the name of an interface into its type, the list of
+// the interface in the tree, and its number, the key of the entry.
+func SplitInterface(name string) (string, string, error) {
+	i := strings.IndexAny(name, "0123456789")
+	if i <= 0 {
+		return "", "", fmt.Errorf("invalid interface name %q", name)
+	}
+	return name[:i], name[i:], nil
+}
+
+func ciscoInterface(iface cisconf.CiscoInterface) (*Node, error) {
+	kind, number, err := SplitInterface(iface.Parent.Identifier)
+	if err != nil {
+		return nil, err
+	}
+	// The interfaces numbered by a single integer, like Vlan10, are keyed by
+	// a number.
+	var name any = number
+	if id, err := strconv.Atoi(number); err == nil {
+		name = id
+	}
+	n := entry(kind, []string{"name"}, leaf("name", name))
+	if iface.Description != "" {
+		n.add(leaf("description", iface.Description))
+	}
+	if iface.Shutdown {
+		n.add(leaf("shutdown", Empty{}))
+	}
+	n.add(container("switchport-conf", leaf("switchport", iface.Switchport)))
+
+	switchport := container("switchport").in(switchModule)
+	switch {
+	case iface.Access:
+		switchport.add(container("mode", container("access")))
+	case iface.Trunk:
+		switchport.add(container("mode", container("trunk")))
+	}
+	if iface.Access && iface.AccessVlan != 0 {
+		switchport.add(container("access", container("vlan", leaf("vlan", iface.AccessVlan))))
+	}
+	trunk := container("trunk")
+	if iface.Encapsulation != "" {
+		trunk.add(leaf("encapsulation", iface.Encapsulation))
+	}
+	if len(iface.TrunkAllowedVlan) > 0 {
+		vlans := make([]string, len(iface.TrunkAllowedVlan))
+		for i, id := range iface.TrunkAllowedVlan {
+			vlans[i] = strconv.Itoa(id)
+		}
+		trunk.add(container("allowed", container("vlan", leaf("vlans", strings.Join(vlans, ",")))))
+	}
+	if len(trunk.Children) > 0 {
+		switchport.add(trunk)
+	}
+	if len(switchport.Children) > 0 {
+		n.add(switchport)
+	}
+
+	spanningTree := container("spanning-tree").in(spanningTreeModule)
+	if iface.STPPortFast != "" {
+		spanningTree.add(container("portfast", leaf(iface.STPPortFast, Empty{})))
+	}
+	if iface.STPBpduGuard != "" {
+		spanningTree.add(container("bpduguard", leaf(iface.STPBpduGuard, Empty{})))
+	}
+	if len(spanningTree.Children) > 0 {
+		n.add(spanningTree)
+	}
+
+	ip := container("ip")
+	address := container("address")
+	for _, addr := range iface.Ips {
+		switch {
+		case addr.DHCP:
+			address.add(container("dhcp"))
+		case addr.Secondary:
+			address.add(entry("secondary", listKeys["secondary"],
+				leaf("address", addr.Ip),
+				leaf("mask", addr.Subnet),
+				leaf("secondary", Empty{}),
+			))
+		default:
+			address.add(container("primary", leaf("address", addr.Ip), leaf("mask", addr.Subnet)))
+		}
+	}
+	if len(address.Children) > 0 {
+		ip.add(address)
+	}
+	for _, helper := range iface.IPHelperAddresses {
+		ip.add(entry("helper-address", listKeys["helper-address"], leaf("address", helper)))
+	}
+	if len(ip.Children) > 0 {
+		n.add(ip)
+	}
+	return n, nil
+}
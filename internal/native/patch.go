@@ -0,0 +1,145 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package native
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Patch is an edit of the data tree, applied in order: Merge is merged into
+// the tree, then the nodes of Delete are removed and those of Reset replaced
+// by their key leaves alone.
+type Patch struct {
+	// Merge is the native root holding the nodes to create or change, nil
+	// when there are none.
+	Merge  *Node
+	Delete []Path
+	// Reset lists the list entries brought back to their defaults, the
+	// equivalent of default interface.
+	Reset []Path
+}
+
+// IsEmpty reports whether the patch changes nothing.
+func (p Patch) IsEmpty() bool {
+	return p.Merge == nil && len(p.Delete) == 0 && len(p.Reset) == 0
+}
+
+// String describes the patch, for the errors and the logs.
+func (p Patch) String() string {
+	var lines []string
+	if p.Merge != nil {
+		lines = append(lines, "merge "+Path{p.Merge}.String())
+	}
+	for _, path := range p.Delete {
+		lines = append(lines, "delete "+path.String())
+	}
+	for _, path := range p.Reset {
+		lines = append(lines, "reset "+path.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Diff returns the patch turning the tree of src into the tree of dest, src
+// being nil when the object does not exist yet.
+//
+// Like the commands of cisconf, a change repeats the leaves of dest next to
+// the leaf changed, so that a list entry is always sent with its keys.
+func Diff(src any, dest any) (Patch, error) {
+	srcTree, err := Object(src)
+	if err != nil {
+		return Patch{}, err
+	}
+	destTree, err := Object(dest)
+	if err != nil {
+		return Patch{}, err
+	}
+	patch := Patch{}
+	patch.Merge = diff(srcTree, destTree, Path{destTree}, &patch.Delete)
+	return patch, nil
+}
+
+// diff returns the nodes of dest missing or different in src, nil when there
+// are none, and appends the paths of the nodes of src missing from dest to
+// deletes.
+func diff(src *Node, dest *Node, path Path, deletes *[]Path) *Node {
+	merge := &Node{Module: dest.Module, Name: dest.Name, Keys: dest.Keys}
+	changed := false
+	for _, child := range dest.Children {
+		existing := src.find(child)
+		switch {
+		case existing == nil:
+			merge.add(child.Clone())
+			changed = true
+		case child.IsLeaf():
+			if fmt.Sprint(existing.Value) != fmt.Sprint(child.Value) {
+				changed = true
+			}
+		default:
+			childPath := append(append(Path{}, path...), child)
+			if m := diff(existing, child, childPath, deletes); m != nil {
+				merge.add(m)
+				changed = true
+			}
+		}
+	}
+	for _, child := range src.Children {
+		if dest.find(child) == nil {
+			*deletes = append(*deletes, append(append(Path{}, path...), child.keysOnly()))
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	// The leaves of dest are repeated in front of the nodes changed.
+	var leaves []*Node
+	for _, child := range dest.Children {
+		if child.IsLeaf() && merge.find(child) == nil {
+			leaves = append(leaves, child.Clone())
+		}
+	}
+	merge.Children = append(leaves, merge.Children...)
+	return merge
+}
+
+// Remove returns the patch deleting obj from the tree: the outermost list
+// entries of its tree are deleted, while an interface is reset to its
+// defaults since a physical interface cannot be deleted.
+func Remove(obj any) (Patch, error) {
+	tree, err := Object(obj)
+	if err != nil {
+		return Patch{}, err
+	}
+	patch := Patch{}
+	var walk func(n *Node, path Path)
+	walk = func(n *Node, path Path) {
+		for _, child := range n.Children {
+			childPath := append(append(Path{}, path...), child.keysOnly())
+			switch {
+			case child.IsEntry() && n.Name == "interface":
+				patch.Reset = append(patch.Reset, childPath)
+			case child.IsEntry():
+				patch.Delete = append(patch.Delete, childPath)
+			case !child.IsLeaf():
+				walk(child, childPath)
+			}
+		}
+	}
+	walk(tree, Path{tree.keysOnly()})
+	return patch, nil
+}
+
+// Apply applies the patch to root, the way the device does.
+func Apply(root *Node, patch Patch) {
+	if patch.Merge != nil {
+		Merge(root, patch.Merge)
+	}
+	for _, path := range patch.Delete {
+		Delete(root, path)
+	}
+	for _, path := range patch.Reset {
+		Replace(root, path, path.Last())
+	}
+}
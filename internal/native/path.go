@@ -0,0 +1,148 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package native
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Path is the chain of nodes from the root of the tree to a node, the list
+// entries along it are identified by their key leaves.
+type Path []*Node
+
+// String returns the path as a RESTCONF data resource identifier, such as
+// Cisco-IOS-XE-native:native/vlan/Cisco-IOS-XE-vlan:vlan-list=10.
+func (p Path) String() string {
+	var b strings.Builder
+	module := ""
+	for i, n := range p {
+		if i > 0 {
+			b.WriteString("/")
+		}
+		if n.Module != "" && n.Module != module {
+			module = n.Module
+			b.WriteString(module + ":")
+		}
+		b.WriteString(n.Name)
+		if n.IsEntry() {
+			values := n.KeyValues()
+			for i, value := range values {
+				values[i] = url.PathEscape(value)
+			}
+			b.WriteString("=" + strings.Join(values, ","))
+		}
+	}
+	return b.String()
+}
+
+// Module returns the module defining the last node of the path.
+func (p Path) Module() string {
+	module := ""
+	for _, n := range p {
+		if n.Module != "" {
+			module = n.Module
+		}
+	}
+	return module
+}
+
+// Last returns the node the path leads to.
+func (p Path) Last() *Node {
+	if len(p) == 0 {
+		return nil
+	}
+	return p[len(p)-1]
+}
+
+// ParsePath parses a RESTCONF data resource identifier with its key values
+// still percent-encoded.
+func ParsePath(text string) (Path, error) {
+	var path Path
+	parent := ""
+	for _, segment := range strings.Split(strings.Trim(text, "/"), "/") {
+		name, keys, isEntry := strings.Cut(segment, "=")
+		n := &Node{Name: name}
+		if module, local, ok := strings.Cut(name, ":"); ok {
+			n.Module, n.Name = module, local
+		}
+		if isEntry {
+			n.Keys = keysOf(parent, n.Name)
+			if n.Keys == nil {
+				return nil, fmt.Errorf("%s is not a list", n.Name)
+			}
+			values := strings.Split(keys, ",")
+			if len(values) != len(n.Keys) {
+				return nil, fmt.Errorf("%s is keyed by %s", n.Name, strings.Join(n.Keys, ","))
+			}
+			for i, value := range values {
+				value, err := url.PathUnescape(value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid key of %s: %w", n.Name, err)
+				}
+				n.add(leaf(n.Keys[i], value))
+			}
+		}
+		path = append(path, n)
+		parent = n.Name
+	}
+	return path, nil
+}
+
+// Find returns the node of root the path leads to, the path starting with the
+// root itself, nil when it is missing.
+func Find(root *Node, path Path) *Node {
+	if len(path) == 0 || !root.same(path[0]) {
+		return nil
+	}
+	n := root
+	for _, step := range path[1:] {
+		n = n.find(step)
+		if n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// Delete removes the node the path leads to from root, and reports whether it
+// was found.
+func Delete(root *Node, path Path) bool {
+	if len(path) < 2 {
+		return false
+	}
+	parent := Find(root, path[:len(path)-1])
+	if parent == nil {
+		return false
+	}
+	for i, child := range parent.Children {
+		if child.same(path.Last()) {
+			parent.Children = append(parent.Children[:i], parent.Children[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Replace replaces the node the path leads to with n, creating it when it is
+// missing, the way a RESTCONF PUT does. The containers leading to it must
+// exist.
+func Replace(root *Node, path Path, n *Node) bool {
+	if len(path) < 2 {
+		return false
+	}
+	parent := Find(root, path[:len(path)-1])
+	if parent == nil {
+		return false
+	}
+	for i, child := range parent.Children {
+		if child.same(path.Last()) {
+			parent.Children[i] = n.Clone()
+			return true
+		}
+	}
+	parent.Children = append(parent.Children, n.Clone())
+	return true
+}
@@ -0,0 +1,206 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package native
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// namespacePrefix is the prefix of the XML namespaces of the Cisco IOS-XE
+// YANG modules.
+const namespacePrefix = "http://cisco.com/ns/yang/"
+
+// netconfNamespace is the XML namespace of the NETCONF base protocol, where
+// the operation attribute of edit-config is defined.
+const netconfNamespace = "urn:ietf:params:xml:ns:netconf:base:1.0"
+
+// Namespace returns the XML namespace of a module.
+func Namespace(module string) string {
+	return namespacePrefix + module
+}
+
+// EncodeXML encodes the native root as XML, the data of a NETCONF reply.
+func EncodeXML(root *Node) []byte {
+	var b bytes.Buffer
+	encodeElement(&b, "", root, nil)
+	return b.Bytes()
+}
+
+// EncodeEditXML encodes the patch as the config of a NETCONF edit-config: the
+// merge tree with the nodes to delete marked with the remove operation, and
+// those to reset with the replace operation.
+func EncodeEditXML(patch Patch) []byte {
+	root := container("native").in(Module)
+	if patch.Merge != nil {
+		root = patch.Merge.Clone()
+	}
+	operations := map[*Node]string{}
+	mark := func(path Path, operation string) {
+		n := root
+		for _, step := range path[1 : len(path)-1] {
+			next := n.find(step)
+			if next == nil {
+				next = step.keysOnly()
+				n.Children = append(n.Children, next)
+			}
+			n = next
+		}
+		last := path.Last().Clone()
+		n.Children = append(n.Children, last)
+		operations[last] = operation
+	}
+	for _, path := range patch.Delete {
+		mark(path, "remove")
+	}
+	for _, path := range patch.Reset {
+		mark(path, "replace")
+	}
+
+	var b bytes.Buffer
+	encodeElement(&b, "", root, operations)
+	return b.Bytes()
+}
+
+func encodeElement(b *bytes.Buffer, module string, n *Node, operations map[*Node]string) {
+	b.WriteString("<" + n.Name)
+	if n.Module != "" && n.Module != module {
+		module = n.Module
+		b.WriteString(` xmlns="` + Namespace(module) + `"`)
+	}
+	if operation, ok := operations[n]; ok {
+		b.WriteString(` xmlns:nc="` + netconfNamespace + `" nc:operation="` + operation + `"`)
+	}
+	text := ""
+	switch n.Value.(type) {
+	case nil, Empty:
+	default:
+		text = fmt.Sprint(n.Value)
+	}
+	if text == "" && len(n.Children) == 0 {
+		b.WriteString("/>")
+		return
+	}
+	b.WriteString(">")
+	xml.EscapeText(b, []byte(text))
+	for _, child := range n.Children {
+		encodeElement(b, module, child, operations)
+	}
+	b.WriteString("</" + n.Name + ">")
+}
+
+// DecodeXML decodes the native root of the data of a NETCONF reply. The
+// values of the leaves are kept as text, and an empty element is a leaf of
+// the empty type.
+func DecodeXML(data []byte) (*Node, error) {
+	root, _, err := decodeXML(data)
+	return root, err
+}
+
+// DecodeEditXML decodes the config of an edit-config encoded by
+// EncodeEditXML back into a patch.
+func DecodeEditXML(data []byte) (Patch, error) {
+	root, operations, err := decodeXML(data)
+	if err != nil {
+		return Patch{}, err
+	}
+	patch := Patch{}
+	var walk func(n *Node, path Path)
+	walk = func(n *Node, path Path) {
+		kept := n.Children[:0]
+		for _, child := range n.Children {
+			childPath := append(append(Path{}, path...), child)
+			switch operations[child] {
+			case "remove", "delete":
+				patch.Delete = append(patch.Delete, childPath)
+			case "replace":
+				patch.Reset = append(patch.Reset, childPath)
+			default:
+				walk(child, childPath)
+				kept = append(kept, child)
+			}
+		}
+		n.Children = kept
+	}
+	walk(root, Path{root})
+	if len(root.Children) > 0 {
+		patch.Merge = root
+	}
+	return patch, nil
+}
+
+func decodeXML(data []byte) (*Node, map[*Node]string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	operations := map[*Node]string{}
+	var stack []*Node
+	var text strings.Builder
+	var root *Node
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode the data tree: %w", err)
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			parent := ""
+			parentModule := ""
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1].Name
+				parentModule = moduleOf(stack)
+			}
+			n := &Node{Name: token.Name.Local, Keys: keysOf(parent, token.Name.Local)}
+			if module, ok := strings.CutPrefix(token.Name.Space, namespacePrefix); ok && module != parentModule {
+				n.Module = module
+			}
+			for _, attr := range token.Attr {
+				if attr.Name.Space == netconfNamespace && attr.Name.Local == "operation" {
+					operations[n] = attr.Value
+				}
+			}
+			if len(stack) > 0 {
+				stack[len(stack)-1].add(n)
+			} else if root == nil {
+				root = n
+			}
+			stack = append(stack, n)
+			text.Reset()
+		case xml.CharData:
+			text.Write(token)
+		case xml.EndElement:
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(n.Children) == 0 && !n.IsEntry() {
+				value := strings.TrimSpace(text.String())
+				if value == "" {
+					n.Value = Empty{}
+				} else {
+					n.Value = value
+				}
+			}
+			text.Reset()
+		}
+	}
+	if root == nil {
+		return nil, nil, fmt.Errorf("the data tree is empty")
+	}
+	// An empty root is a container without children, not a leaf.
+	root.Value = nil
+	return root, operations, nil
+}
+
+// moduleOf returns the module of the innermost element of the stack.
+func moduleOf(stack []*Node) string {
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i].Module != "" {
+			return stack[i].Module
+		}
+	}
+	return ""
+}
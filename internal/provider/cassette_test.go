@@ -0,0 +1,78 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"terraform-provider-ios/internal/session"
+)
+
+// planThenApply plans and applies a vlan and the vlans data source on d,
+// which is stopped when the cassette is replayed.
+func planThenApply(t *testing.T, d *accDevice) {
+	t.Helper()
+	replay := os.Getenv("IOS_CASSETTE_MODE") == "replay"
+	checks := []resource.TestCheckFunc{
+		resource.TestCheckResourceAttr("ios_vlan.test", "id", "30"),
+		resource.TestCheckResourceAttr("ios_vlan.test", "name", "printers"),
+		resource.TestCheckTypeSetElemNestedAttrs("data.ios_vlans.test", "vlans.*", map[string]string{"id": "1", "name": "default"}),
+	}
+	if !replay {
+		checks = append(checks, d.contains("vlan 30\n name printers\n"))
+	}
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: d.provider() + `
+data "ios_vlans" "test" {}
+
+resource "ios_vlan" "test" {
+  id   = 30
+  name = "printers"
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(checks...),
+				// A replaying process cannot tell the plans Terraform runs
+				// after the apply from the plan before it, they are
+				// answered the running-config read before the vlan was
+				// created.
+				ExpectNonEmptyPlan: replay,
+			},
+		},
+	})
+	session.Shutdown()
+}
+
+func TestAccCassette(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	t.Setenv("IOS_CASSETTE", path)
+
+	t.Setenv("IOS_CASSETTE_MODE", "record")
+	d := newAccDevice(t, "")
+	planThenApply(t, d)
+
+	// Terraform starts a provider process for each command, each records a
+	// run of its own.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read the cassette: %s", err)
+	}
+	var cassette struct {
+		Runs []json.RawMessage `json:"runs"`
+	}
+	err = json.Unmarshal(data, &cassette)
+	if err != nil || len(cassette.Runs) < 2 {
+		t.Fatalf("the cassette holds %d runs, want the plan and the apply at least: %v", len(cassette.Runs), err)
+	}
+
+	t.Setenv("IOS_CASSETTE_MODE", "replay")
+	d.server.Close()
+	planThenApply(t, d)
+}
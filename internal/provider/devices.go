@@ -0,0 +1,392 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	datasourceschema "github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	resourceschema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"terraform-provider-ios/internal/inventory"
+	"terraform-provider-ios/internal/provider/models"
+	"terraform-provider-ios/internal/session"
+	"terraform-provider-ios/internal/utils"
+)
+
+// Devices is the data the provider hands to its resources and data sources:
+// the session of the device of the provider block, and the devices of the
+// devices map, whose sessions are only created once a resource selects them.
+type Devices struct {
+	// fallback is the session of the device of the provider block, used by
+	// the resources without a device, nil when only the devices map is set.
+	fallback  *session.Session
+	targets   map[string]target
+	connector connector
+	mu        sync.Mutex
+	sessions  map[string]*session.Session
+	// inventory is the Ansible inventory of the provider, nil when it has
+	// none. rejected holds the reasons the hosts of the inventory the
+	// provider cannot manage are refused, reported once a resource selects
+	// them.
+	inventory *inventory.Inventory
+	rejected  map[string]diag.Diagnostics
+}
+
+// target holds the settings of the connection to a device.
+type target struct {
+	host               string
+	port               string
+	username           string
+	password           string
+	enablePassword     string
+	privateKey         string
+	privateKeyFile     string
+	passphrase         string
+	knownHostsFile     string
+	hostKeyFingerprint string
+	ignoreHostKey      bool
+	transport          string
+}
+
+// connector creates the sessions of the devices, with the settings shared by
+// all the devices of the provider.
+type connector struct {
+	options  session.Options
+	bastion  *session.SSHConfig
+	caCert   []byte
+	insecure bool
+}
+
+// unknownDevices returns the devices of a provider whose configuration is not
+// known yet, every device selected is unknown.
+func unknownDevices() *Devices {
+	return &Devices{fallback: session.Unknown()}
+}
+
+// IsUnknown reports whether the provider configuration is not known yet.
+func (d *Devices) IsUnknown() bool {
+	return d.fallback != nil && d.fallback.IsUnknown()
+}
+
+// With returns the session of the device selected by the device attribute of
+// a resource, the device of the provider block when device is null, scoped
+// like session.With.
+func (d *Devices) With(ctx context.Context, device types.String, resource string, id string) (*session.Session, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	if d.IsUnknown() {
+		return d.fallback.With(ctx, resource, id), diags
+	}
+
+	if device.IsNull() {
+		if d.fallback == nil {
+			diags.AddAttributeError(
+				path.Root("device"),
+				"Missing Cisco IOS Device",
+				fmt.Sprintf("The provider block only configures the devices map, set device to one of %s.", d.names()),
+			)
+			return nil, diags
+		}
+		return d.fallback.With(ctx, resource, id), diags
+	}
+
+	name := device.ValueString()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	client, ok := d.sessions[name]
+	if !ok {
+		if rejected, ok := d.rejected[name]; ok {
+			return nil, rejected
+		}
+		t, ok := d.targets[name]
+		if !ok {
+			diags.AddAttributeError(
+				path.Root("device"),
+				"Unknown Cisco IOS Device",
+				fmt.Sprintf("The device %q is neither in the devices map nor in the inventory of the provider, set device to one of %s.", name, d.names()),
+			)
+			return nil, diags
+		}
+		var err error
+		client, err = d.connector.open(t, d.connector.dialer(t))
+		if err != nil {
+			diags.AddAttributeError(
+				path.Root("device"),
+				"Unable to Create Cisco IOS Client",
+				fmt.Sprintf("The session to the device %q cannot be created: %s.", name, err),
+			)
+			return nil, diags
+		}
+		if d.sessions == nil {
+			d.sessions = map[string]*session.Session{}
+		}
+		d.sessions[name] = client
+	}
+	return client.With(ctx, resource, id), diags
+}
+
+// checkFeature reports an error on the attribute at when the platform of the
+// device lacks feature, the platform being read from its facts.
+func checkFeature(ctx context.Context, client *session.Session, feature models.Feature, at path.Path) diag.Diagnostics {
+	var diags diag.Diagnostics
+	facts, err := models.GetFacts(ctx, client)
+	if err != nil {
+		diags.AddError(
+			"Failed to read device facts",
+			fmt.Sprintf("Unable to read the platform of the device: %s", err),
+		)
+		return diags
+	}
+	if err := facts.Check(feature); err != nil {
+		diags.AddAttributeError(
+			at,
+			"Unsupported Cisco IOS Feature",
+			fmt.Sprintf("The configuration cannot be applied as %s.", err),
+		)
+	}
+	return diags
+}
+
+// unlock releases client once a resource is applied. In end_of_apply mode
+// the last resource saves the changes of the resources applied before it, a
+// failure of the save is reported on diags.
+func unlock(client *session.Session, diags *diag.Diagnostics) {
+	err := client.Unlock()
+	if err != nil {
+		diags.AddError(
+			"Failed to save configuration",
+			fmt.Sprintf("Unable to copy the running-config to the startup-config: %s", err),
+		)
+	}
+}
+
+// setPlannedCommands plans the lines of marshal as the planned_commands. The
+// state keeps the commands of the last apply until it is refreshed, a plan
+// pushing nothing keeps them rather than showing them removed. The
+// structured transports send YANG payloads rather than commands, the
+// planned_commands are left null.
+func setPlannedCommands(ctx context.Context, client *session.Session, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse, marshal string) {
+	if client.Structured() {
+		return
+	}
+	commands := utils.PlannedCommands(marshal)
+	if len(commands.Elements()) == 0 && !req.State.Raw.IsNull() {
+		resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("planned_commands"), &commands)...)
+	}
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("planned_commands"), commands)...)
+}
+
+// appliedCommands returns the planned_commands of the state once planned is
+// applied, null with the structured transports.
+func appliedCommands(client *session.Session, planned types.List) types.List {
+	if client.Structured() {
+		return types.ListNull(types.StringType)
+	}
+	return utils.AppliedCommands(planned)
+}
+
+// refreshedCommands returns the planned_commands of a state read from the
+// device: none, or null with the structured transports.
+func refreshedCommands(client *session.Session) types.List {
+	if client.Structured() {
+		return types.ListNull(types.StringType)
+	}
+	return utils.PlannedCommands("")
+}
+
+// names lists the devices of the devices map for the diagnostics.
+func (d *Devices) names() string {
+	names := slices.Sorted(maps.Keys(d.targets))
+	if len(names) == 0 {
+		return "the devices of the devices map, which is empty"
+	}
+	return "'" + strings.Join(names, "', '") + "'"
+}
+
+// iosNetworkOS are the values of ansible_network_os of the hosts of an
+// inventory the provider manages, hosts without one are assumed to run IOS.
+var iosNetworkOS = []string{"", "ios", "cisco.ios.ios"}
+
+// inventoryTransports are the transports of the values of
+// ansible_connection.
+var inventoryTransports = map[string]string{
+	"network_cli":                   "ssh",
+	"ansible.netcommon.network_cli": "ssh",
+	"ssh":                           "ssh",
+	"paramiko":                      "ssh",
+	"netconf":                       "netconf",
+	"ansible.netcommon.netconf":     "netconf",
+	"httpapi":                       "restconf",
+	"ansible.netcommon.httpapi":     "restconf",
+	"telnet":                        "telnet",
+}
+
+// secretVars are the variables of the hosts of an inventory holding
+// passwords, left out of the ios_inventory data source.
+var secretVars = []string{"ansible_password", "ansible_ssh_pass", "ansible_ssh_password", "ansible_become_password", "ansible_become_pass"}
+
+// fromHost returns the settings of a host of an Ansible inventory, the
+// variables it leaves out being those of t. The variables encrypted with
+// Ansible Vault cannot be read and are left out, vaulted lists them.
+func (t target) fromHost(host *inventory.Host) (result target, vaulted []string, err error) {
+	lookup := func(names ...string) (string, bool) {
+		for _, name := range names {
+			value, ok := host.Vars[name]
+			if !ok {
+				continue
+			}
+			if strings.HasPrefix(strings.TrimSpace(value), "$ANSIBLE_VAULT;") {
+				vaulted = append(vaulted, name)
+				continue
+			}
+			return value, true
+		}
+		return "", false
+	}
+
+	if networkOS := host.Vars["ansible_network_os"]; !slices.Contains(iosNetworkOS, networkOS) {
+		return t, nil, fmt.Errorf("its ansible_network_os is %s, the provider only manages cisco.ios.ios hosts", networkOS)
+	}
+
+	t.host = host.Name
+	if value, ok := lookup("ansible_host", "ansible_ssh_host"); ok {
+		t.host = value
+	}
+	if value, ok := lookup("ansible_port", "ansible_ssh_port"); ok {
+		if _, err := strconv.Atoi(value); err != nil {
+			return t, nil, fmt.Errorf("its ansible_port %q is not a number", value)
+		}
+		t.port = value
+	}
+	if value, ok := lookup("ansible_user", "ansible_ssh_user"); ok {
+		t.username = value
+	}
+	if value, ok := lookup("ansible_password", "ansible_ssh_pass", "ansible_ssh_password"); ok {
+		t.password = value
+	}
+	if value, ok := lookup("ansible_become_password", "ansible_become_pass"); ok {
+		t.enablePassword = value
+	}
+	if value, ok := lookup("ansible_ssh_private_key_file", "ansible_private_key_file"); ok {
+		t.privateKey = ""
+		t.privateKeyFile = value
+	}
+	if value, ok := lookup("ansible_connection"); ok {
+		transport, ok := inventoryTransports[value]
+		if !ok {
+			return t, nil, fmt.Errorf("its ansible_connection %s is not supported, expected network_cli, netconf, httpapi or telnet", value)
+		}
+		t.transport = transport
+	}
+	return t, vaulted, nil
+}
+
+// dialer returns the function opening the CLI connections to the device t.
+func (c connector) dialer(t target) session.Dialer {
+	return func() (session.Conn, error) {
+		if t.transport == "telnet" {
+			return session.DialTelnet(session.TelnetConfig{
+				Host:           t.host,
+				Port:           t.port,
+				Username:       t.username,
+				Password:       t.password,
+				EnablePassword: t.enablePassword,
+			})
+		}
+		return session.DialSSH(c.ssh(t))
+	}
+}
+
+func (c connector) ssh(t target) session.SSHConfig {
+	return session.SSHConfig{
+		Host:               t.host,
+		Port:               t.port,
+		Username:           t.username,
+		Password:           t.password,
+		EnablePassword:     t.enablePassword,
+		PrivateKey:         []byte(t.privateKey),
+		Passphrase:         t.passphrase,
+		KnownHostsFile:     t.knownHostsFile,
+		HostKeyFingerprint: t.hostKeyFingerprint,
+		// checkTarget refuses the devices with neither pin unless
+		// insecure_ignore_host_key is set.
+		InsecureIgnoreHostKey: t.ignoreHostKey,
+		Bastion:               c.bastion,
+	}
+}
+
+// open creates the session to the device t, the CLI transports connecting
+// through dial. Nothing is connected until a command is sent.
+func (c connector) open(t target, dial session.Dialer) (*session.Session, error) {
+	options := c.options
+	options.Host = t.host
+	switch t.transport {
+	case "restconf":
+		store, err := session.DialRestconf(session.RestconfConfig{
+			Host:     t.host,
+			Port:     t.port,
+			Username: t.username,
+			Password: t.password,
+			CACert:   c.caCert,
+			Insecure: c.insecure,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return session.NewDatastore(store, options), nil
+	case "netconf":
+		return session.NewDatastore(session.DialNetconf(c.ssh(t)), options), nil
+	}
+	return session.New(dial, options), nil
+}
+
+// deviceResourceAttribute is the device attribute of the resources, moving a
+// resource to another device destroys it on the former one.
+func deviceResourceAttribute() resourceschema.StringAttribute {
+	return resourceschema.StringAttribute{
+		Optional: true,
+		PlanModifiers: []planmodifier.String{
+			stringplanmodifier.RequiresReplace(),
+		},
+		Description: "Name of the device of the devices map or of the inventory of the provider the object is configured on. Defaults to the device of the provider block.",
+	}
+}
+
+// deviceDataSourceAttribute is the device attribute of the data sources.
+func deviceDataSourceAttribute() datasourceschema.StringAttribute {
+	return datasourceschema.StringAttribute{
+		Optional:    true,
+		Description: "Name of the device of the devices map or of the inventory of the provider the data is read from. Defaults to the device of the provider block.",
+	}
+}
+
+// importDevice splits the identifier of an imported object of the form
+// <device>:<id>, sets the device of the state, and returns the id. The
+// identifier is only split when the text before the first colon names a
+// device of the provider, since ids like the interface Serial0/0/0:0 hold
+// colons too. Other identifiers import from the device of the provider
+// block.
+func (d *Devices) importDevice(ctx context.Context, id string, resp *resource.ImportStateResponse) string {
+	device, rest, ok := strings.Cut(id, ":")
+	if !ok || d == nil {
+		return id
+	}
+	_, target := d.targets[device]
+	_, rejected := d.rejected[device]
+	if !target && !rejected {
+		return id
+	}
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("device"), device)...)
+	return rest
+}
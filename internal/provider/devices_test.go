@@ -0,0 +1,266 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
+	"terraform-provider-ios/internal/fakeios"
+)
+
+func TestAccDevices(t *testing.T) {
+	d := newAccDevice(t, "")
+	core := newAccDevice(t, strings.Replace(fakeios.DefaultConfig, "vlan internal", "vlan 30\n name printers\n!\nvlan internal", 1))
+	provider := d.provider("devices = {\ncore = {\n" + core.connection() + "}\n}")
+	printers := `
+resource "ios_vlan" "printers" {
+  id     = 30
+  name   = "printers"
+  device = "core"
+}
+`
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				// The resources without a device manage the device of the
+				// provider block.
+				Config: provider + `
+resource "ios_vlan" "users" {
+  id     = 10
+  name   = "users"
+  device = "core"
+}
+
+resource "ios_vlan" "staff" {
+  id   = 20
+  name = "staff"
+}
+
+data "ios_vlan" "printers" {
+  id     = 30
+  device = "core"
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_vlan.users", "device", "core"),
+					core.contains("vlan 10\n name users\n"),
+					d.lacks("vlan 10\n"),
+					d.contains("vlan 20\n name staff\n"),
+					core.lacks("vlan 20\n"),
+					resource.TestCheckResourceAttr("data.ios_vlan.printers", "name", "printers"),
+					resource.TestCheckResourceAttr("data.ios_vlan.printers", "device", "core"),
+				),
+			},
+			{
+				Config: provider + `
+resource "ios_vlan" "users" {
+  id     = 10
+  name   = "users"
+  device = "core"
+}
+
+resource "ios_vlan" "staff" {
+  id   = 20
+  name = "staff"
+}
+` + printers,
+				ResourceName:       "ios_vlan.printers",
+				ImportState:        true,
+				ImportStateId:      "core:30",
+				ImportStatePersist: true,
+			},
+			{
+				// Moving a resource to another device replaces it.
+				Config: provider + `
+resource "ios_vlan" "users" {
+  id   = 10
+  name = "users"
+}
+
+resource "ios_vlan" "staff" {
+  id   = 20
+  name = "staff"
+}
+` + printers,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("ios_vlan.users", plancheck.ResourceActionReplace),
+						plancheck.ExpectResourceAction("ios_vlan.printers", plancheck.ResourceActionNoop),
+					},
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_vlan.printers", "device", "core"),
+					resource.TestCheckResourceAttr("ios_vlan.printers", "name", "printers"),
+					core.lacks("vlan 10\n"),
+					d.contains("vlan 10\n name users\n"),
+				),
+			},
+		},
+		CheckDestroy: resource.ComposeAggregateTestCheckFunc(
+			d.lacks("vlan 10\n"),
+			d.lacks("vlan 20\n"),
+			core.lacks("vlan 30\n"),
+		),
+	})
+}
+
+func TestAccDevicesImportColon(t *testing.T) {
+	serial := strings.Replace(fakeios.DefaultConfig, "interface Vlan1", "interface Serial0/0/0:0\n ip address 10.0.0.1 255.255.255.252\n!\ninterface Vlan1", 1)
+	d := newAccDevice(t, serial)
+	core := newAccDevice(t, serial)
+	config := d.provider("devices = {\ncore = {\n"+core.connection()+"}\n}") + `
+resource "ios_ethernet_interface" "local" {
+  id  = "Serial0/0/0:0"
+  ips = [{ ip = "10.0.0.1/30" }]
+}
+
+resource "ios_ethernet_interface" "core" {
+  id     = "Serial0/0/0:0"
+  ips    = [{ ip = "10.0.0.1/30" }]
+  device = "core"
+}
+`
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				// The colon of a channelized interface does not name a
+				// device.
+				Config:             config,
+				ResourceName:       "ios_ethernet_interface.local",
+				ImportState:        true,
+				ImportStateId:      "Serial0/0/0:0",
+				ImportStatePersist: true,
+			},
+			{
+				Config:             config,
+				ResourceName:       "ios_ethernet_interface.core",
+				ImportState:        true,
+				ImportStateId:      "core:Serial0/0/0:0",
+				ImportStatePersist: true,
+			},
+			{
+				Config: config,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectEmptyPlan(),
+					},
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_ethernet_interface.local", "id", "Serial0/0/0:0"),
+					resource.TestCheckNoResourceAttr("ios_ethernet_interface.local", "device"),
+					resource.TestCheckResourceAttr("ios_ethernet_interface.local", "ips.0.ip", "10.0.0.1/30"),
+					resource.TestCheckResourceAttr("ios_ethernet_interface.core", "id", "Serial0/0/0:0"),
+					resource.TestCheckResourceAttr("ios_ethernet_interface.core", "device", "core"),
+					resource.TestCheckResourceAttr("ios_ethernet_interface.core", "ips.0.ip", "10.0.0.1/30"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDevicesWithoutHost(t *testing.T) {
+	edge := newAccDevice(t, "")
+	provider := providerBlock("devices = {\nedge = {\n" + edge.connection() + "}\n}")
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: provider + `
+resource "ios_vlan" "test" {
+  id   = 10
+  name = "users"
+}
+`,
+				ExpectError: regexp.MustCompile(`(?s)Missing Cisco IOS Device.*'edge'`),
+			},
+			{
+				Config: provider + `
+resource "ios_vlan" "test" {
+  id     = 10
+  name   = "users"
+  device = "core"
+}
+`,
+				ExpectError: regexp.MustCompile(`(?s)Unknown Cisco IOS Device.*'edge'`),
+			},
+			{
+				Config: provider + `
+resource "ios_vlan" "test" {
+  id     = 10
+  name   = "users"
+  device = "edge"
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_vlan.test", "name", "users"),
+					edge.contains("vlan 10\n name users\n"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccUnverifiedHostKey(t *testing.T) {
+	vlan := `
+resource "ios_vlan" "test" {
+  id   = 10
+  name = "users"
+}
+`
+
+	for name, want := range map[string]string{
+		"device":  `(?s)Unverified Cisco IOS Host Key.*known_hosts_file.*host_key_fingerprint.*insecure_ignore_host_key`,
+		"bastion": `(?s)Unverified Cisco IOS Bastion Host Key.*host_key_fingerprint.*insecure_ignore_host_key`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			d := newAccDevice(t, "")
+			provider := providerBlock(fmt.Sprintf("host = %q\nport = %s\n", d.server.Host(), d.server.Port()))
+			if name == "bastion" {
+				provider = d.provider("bastion {\nhost = \"127.0.0.1\"\npassword = \"cisco\"\n}")
+			}
+			resource.Test(t, resource.TestCase{
+				ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+				Steps: []resource.TestStep{
+					{
+						Config:      provider + vlan,
+						ExpectError: regexp.MustCompile(want),
+					},
+				},
+			})
+			if len(d.History()) > 0 {
+				t.Errorf("the device ran %q", d.History())
+			}
+		})
+	}
+
+	// The opt-out accepts any host key, with a warning.
+	var diags diag.Diagnostics
+	warnTarget(target{transport: "ssh", ignoreHostKey: true}, false, &diags)
+	if len(diags) != 1 || diags[0].Severity() != diag.SeverityWarning || diags[0].Summary() != "Cisco IOS Host Key Not Verified" {
+		t.Errorf("ignoring the host key diagnostics = %+v", diags)
+	}
+
+	t.Setenv("IOS_INSECURE_IGNORE_HOST_KEY", "true")
+	d := newAccDevice(t, "")
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: providerBlock(fmt.Sprintf("host = %q\nport = %s\n", d.server.Host(), d.server.Port())) + vlan,
+				Check:  d.contains("vlan 10\n name users\n"),
+			},
+		},
+	})
+}
@@ -6,26 +6,30 @@ package provider
 import (
 	"context"
 	"fmt"
-	"github.com/CorentinPtrl/cgnet"
 	"github.com/CorentinPtrl/cisconf"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listdefault"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"strconv"
 	"terraform-provider-ios/internal/provider/models"
+	"terraform-provider-ios/internal/session"
 	"terraform-provider-ios/internal/utils"
 )
 
 var _ resource.Resource = &EigrpResource{}
+var _ resource.ResourceWithModifyPlan = &EigrpResource{}
+var _ resource.ResourceWithImportState = &EigrpResource{}
 
 func NewEigrpResource() resource.Resource {
 	return &EigrpResource{}
 }
 
 type EigrpResource struct {
-	client *cgnet.Device
+	devices *Devices
 }
 
 func (r *EigrpResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -37,6 +41,12 @@ func (r *EigrpResource) Schema(ctx context.Context, req resource.SchemaRequest,
 		MarkdownDescription: "Static Route resource",
 
 		Attributes: map[string]schema.Attribute{
+			"device": deviceResourceAttribute(),
+			"planned_commands": schema.ListAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "IOS commands the next apply pushes to the device, computed during plan from the running-config. Empty once the device matches the configuration, an apply leaves the commands it pushed until the next refresh. Null with the netconf and restconf transports, which send YANG payloads rather than commands.",
+			},
 			"networks": schema.ListAttribute{
 				Optional:    true,
 				Computed:    true,
@@ -60,69 +70,84 @@ func (r *EigrpResource) Configure(ctx context.Context, req resource.ConfigureReq
 		return
 	}
 
-	client, ok := req.ProviderData.(*cgnet.Device)
+	devices, ok := req.ProviderData.(*Devices)
 
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Resource Configure Type",
-			fmt.Sprintf("Expected *http.Client, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *provider.Devices, got: %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 
 		return
 	}
 
-	r.client = client
+	r.devices = devices
 }
 
-func (r *EigrpResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
-	var data models.EigrpModel
+func (r *EigrpResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	// Nothing is planned when the resource is destroyed or the provider is
+	// not configured yet.
+	if req.Plan.Raw.IsNull() || r.devices == nil {
+		return
+	}
 
-	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	// The commands can only be computed once every planned value is known
+	// and the device can be reached.
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("planned_commands"), types.ListNull(types.StringType))...)
+	if !resp.Plan.Raw.IsFullyKnown() || r.devices.IsUnknown() {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("planned_commands"), types.ListUnknown(types.StringType))...)
+		return
+	}
+
+	var data models.EigrpResourceModel
+
+	resp.Diagnostics.Append(resp.Plan.Get(ctx, &data)...)
 
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	eigrp, err := models.GetEigrpProcess(ctx, r.client, data.As.ValueInt64())
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to get EIGRP process",
-			fmt.Sprintf("An error occurred while retrieving EIGRP process: %s", err),
-		)
+	client, diags := r.devices.With(ctx, data.Device, "ios_eigrp", data.As.String())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	var marshal string
-	datacisco, err := models.EigrpToCisconf(ctx, data)
+	marshal, err := utils.Commands(func() (any, any, error) {
+		return r.change(ctx, client, data.EigrpModel)
+	})
 	if err != nil {
 		resp.Diagnostics.AddError(
-			"Failed to convert EIGRP model to cisconf",
-			fmt.Sprintf("Unable to convert EIGRP model: %s", err),
+			"Failed to plan EIGRP process",
+			fmt.Sprintf("Unable to compute the commands configuring the EIGRP process: %s", err),
 		)
 		return
 	}
-	if eigrp == nil {
-		marshal, err = cisconf.Marshal(datacisco)
-	} else {
-		var eigrpcisco cisconf.Eigrp
-		eigrpcisco, err = models.EigrpToCisconf(ctx, data)
-		if err != nil {
-			resp.Diagnostics.AddError(
-				"Failed to convert EIGRP model to cisconf",
-				fmt.Sprintf("Unable to convert EIGRP model: %s", err),
-			)
-			return
-		}
-		marshal, err = cisconf.Diff(eigrpcisco, datacisco)
+
+	setPlannedCommands(ctx, client, req, resp, marshal)
+}
+
+func (r *EigrpResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data models.EigrpResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
 	}
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to marshal EIGRP configuration",
-			fmt.Sprintf("Unable to marshal EIGRP configuration: %s", err),
-		)
+
+	client, diags := r.devices.With(ctx, data.Device, "ios_eigrp", data.As.String())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
-	err = utils.ConfigDevice(marshal, r.client)
+
+	client.Lock()
+	defer unlock(client, &resp.Diagnostics)
+
+	err := utils.ConfigDevice(client, func() (any, any, error) {
+		return r.change(ctx, client, data.EigrpModel)
+	})
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to configure interface",
@@ -131,7 +156,7 @@ func (r *EigrpResource) Create(ctx context.Context, req resource.CreateRequest,
 		return
 	}
 
-	eigrp, err = models.GetEigrpProcess(ctx, r.client, data.As.ValueInt64())
+	eigrp, err := models.GetEigrpProcess(ctx, client, data.As.ValueInt64())
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to get EIGRP process",
@@ -140,11 +165,23 @@ func (r *EigrpResource) Create(ctx context.Context, req resource.CreateRequest,
 		return
 	}
 
-	resp.Diagnostics.Append(resp.State.Set(ctx, eigrp)...)
+	if eigrp == nil {
+		resp.Diagnostics.AddError(
+			"Failed to get EIGRP process",
+			"The EIGRP process is missing from the running-config once configured.",
+		)
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, models.EigrpResourceModel{
+		EigrpModel:      *eigrp,
+		Device:          data.Device,
+		PlannedCommands: appliedCommands(client, data.PlannedCommands),
+	})...)
 }
 
 func (r *EigrpResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
-	var data models.EigrpModel
+	var data models.EigrpResourceModel
 
 	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
 
@@ -152,7 +189,19 @@ func (r *EigrpResource) Read(ctx context.Context, req resource.ReadRequest, resp
 		return
 	}
 
-	eigrp, err := models.GetEigrpProcess(ctx, r.client, data.As.ValueInt64())
+	client, diags := r.devices.With(ctx, data.Device, "ios_eigrp", data.As.String())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// The device cannot be reached until the provider configuration is known,
+	// keep the prior state until then.
+	if client.IsUnknown() {
+		return
+	}
+
+	eigrp, err := models.GetEigrpProcess(ctx, client, data.As.ValueInt64())
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to get EIGRP process",
@@ -166,11 +215,15 @@ func (r *EigrpResource) Read(ctx context.Context, req resource.ReadRequest, resp
 		return
 	}
 
-	resp.Diagnostics.Append(resp.State.Set(ctx, &eigrp)...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, models.EigrpResourceModel{
+		EigrpModel:      *eigrp,
+		Device:          data.Device,
+		PlannedCommands: refreshedCommands(client),
+	})...)
 }
 
 func (r *EigrpResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
-	var data models.EigrpModel
+	var data models.EigrpResourceModel
 
 	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
 
@@ -178,68 +231,52 @@ func (r *EigrpResource) Update(ctx context.Context, req resource.UpdateRequest,
 		return
 	}
 
-	eigrp, err := models.GetEigrpProcess(ctx, r.client, data.As.ValueInt64())
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to get EIGRP process",
-			fmt.Sprintf("An error occurred while retrieving EIGRP process: %s", err),
-		)
+	client, diags := r.devices.With(ctx, data.Device, "ios_eigrp", data.As.String())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	var marshal string
-	datacisco, err := models.EigrpToCisconf(ctx, data)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to convert EIGRP model to cisconf",
-			fmt.Sprintf("Unable to convert EIGRP model: %s", err),
-		)
-		return
-	}
-	if eigrp == nil {
-		marshal, err = cisconf.Marshal(datacisco)
-	} else {
-		var eigrpcisco cisconf.Eigrp
-		eigrpcisco, err = models.EigrpToCisconf(ctx, data)
-		if err != nil {
-			resp.Diagnostics.AddError(
-				"Failed to convert EIGRP model to cisconf",
-				fmt.Sprintf("Unable to convert EIGRP model: %s", err),
-			)
-			return
-		}
-		marshal, err = cisconf.Diff(eigrpcisco, datacisco)
-	}
+	client.Lock()
+	defer unlock(client, &resp.Diagnostics)
+
+	err := utils.ConfigDevice(client, func() (any, any, error) {
+		return r.change(ctx, client, data.EigrpModel)
+	})
 	if err != nil {
 		resp.Diagnostics.AddError(
-			"Failed to marshal EIGRP configuration",
-			fmt.Sprintf("Unable to marshal EIGRP configuration: %s", err),
+			"Failed to configure interface",
+			fmt.Sprintf("Unable to configure interface: %s", err),
 		)
 		return
 	}
-	err = utils.ConfigDevice(marshal, r.client)
+
+	eigrp, err := models.GetEigrpProcess(ctx, client, data.As.ValueInt64())
 	if err != nil {
 		resp.Diagnostics.AddError(
-			"Failed to configure interface",
-			fmt.Sprintf("Unable to configure interface: %s", err),
+			"Failed to get EIGRP process",
+			fmt.Sprintf("An error occurred while retrieving EIGRP process: %s", err),
 		)
 		return
 	}
 
-	eigrp, err = models.GetEigrpProcess(ctx, r.client, data.As.ValueInt64())
-	if err != nil {
+	if eigrp == nil {
 		resp.Diagnostics.AddError(
 			"Failed to get EIGRP process",
-			fmt.Sprintf("An error occurred while retrieving EIGRP process: %s", err),
+			"The EIGRP process is missing from the running-config once configured.",
 		)
 		return
 	}
 
-	resp.Diagnostics.Append(resp.State.Set(ctx, &eigrp)...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, models.EigrpResourceModel{
+		EigrpModel:      *eigrp,
+		Device:          data.Device,
+		PlannedCommands: appliedCommands(client, data.PlannedCommands),
+	})...)
 }
 
 func (r *EigrpResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
-	var data models.EigrpModel
+	var data models.EigrpResourceModel
 
 	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
 
@@ -247,7 +284,16 @@ func (r *EigrpResource) Delete(ctx context.Context, req resource.DeleteRequest,
 		return
 	}
 
-	err := r.client.Configure([]string{"no router eigrp " + data.As.String()})
+	client, diags := r.devices.With(ctx, data.Device, "ios_eigrp", data.As.String())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client.Lock()
+	defer unlock(client, &resp.Diagnostics)
+
+	err := client.Remove(cisconf.Eigrp{Asn: int(data.As.ValueInt64())}, []string{"no router eigrp " + data.As.String()})
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to configure interface",
@@ -256,3 +302,40 @@ func (r *EigrpResource) Delete(ctx context.Context, req resource.DeleteRequest,
 		return
 	}
 }
+
+// ImportState adopts an EIGRP process from its AS number, the following read
+// fills the networks from the running-config.
+func (r *EigrpResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	id := r.devices.importDevice(ctx, req.ID, resp)
+	as, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || as < 1 || as > 65535 {
+		resp.Diagnostics.AddError(
+			"Unexpected Import Identifier",
+			fmt.Sprintf("Expected the EIGRP AS number, a number between 1 and 65535, optionally prefixed with the device and a colon. Got: %q", req.ID),
+		)
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("as_number"), as)...)
+}
+
+// change returns the EIGRP process read from the running-config, nil when it
+// does not exist, and the process as planned in data.
+func (r *EigrpResource) change(ctx context.Context, client *session.Session, data models.EigrpModel) (any, any, error) {
+	datacisco, err := models.EigrpToCisconf(ctx, data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to convert EIGRP model: %w", err)
+	}
+	eigrp, err := models.GetEigrpProcess(ctx, client, data.As.ValueInt64())
+	if err != nil {
+		return nil, nil, fmt.Errorf("an error occurred while retrieving EIGRP process: %w", err)
+	}
+	if eigrp == nil {
+		return nil, datacisco, nil
+	}
+	eigrpcisco, err := models.EigrpToCisconf(ctx, *eigrp)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to convert EIGRP model: %w", err)
+	}
+	return eigrpcisco, datacisco, nil
+}
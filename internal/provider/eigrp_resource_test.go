@@ -0,0 +1,115 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
+	"terraform-provider-ios/internal/fakeios"
+)
+
+func TestAccEigrpResource(t *testing.T) {
+	d := newAccDevice(t, "")
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: d.provider() + `
+resource "ios_eigrp" "test" {
+  as_number = 100
+  networks  = ["10.0.0.0/8", "192.168.0.0/24"]
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_eigrp.test", "as_number", "100"),
+					resource.TestCheckResourceAttr("ios_eigrp.test", "networks.0", "10.0.0.0/8"),
+					resource.TestCheckResourceAttr("ios_eigrp.test", "networks.1", "192.168.0.0/24"),
+					d.contains("router eigrp 100\n network 10.0.0.0 0.255.255.255\n"),
+				),
+			},
+			{
+				Config: d.provider() + `
+resource "ios_eigrp" "test" {
+  as_number = 100
+  networks  = ["10.0.0.0/8"]
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_eigrp.test", "networks.#", "1"),
+					d.lacks("network 192.168.0.0"),
+				),
+			},
+			{
+				ResourceName:                         "ios_eigrp.test",
+				ImportState:                          true,
+				ImportStateId:                        "100",
+				ImportStateVerify:                    true,
+				ImportStateVerifyIgnore:              []string{"planned_commands"},
+				ImportStateVerifyIdentifierAttribute: "as_number",
+			},
+		},
+		CheckDestroy: d.lacks("router eigrp 100\n"),
+	})
+}
+
+func TestAccEigrpResourceImport(t *testing.T) {
+	d := newAccDevice(t, fakeios.DefaultConfig+"router eigrp 200\n network 10.0.0.0\n network 172.16.0.0 0.0.255.255\n!\n")
+	config := d.provider() + `
+resource "ios_eigrp" "test" {
+  as_number = 200
+  networks  = ["10.0.0.0/24", "172.16.0.0/16"]
+}
+`
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:             config,
+				ResourceName:       "ios_eigrp.test",
+				ImportState:        true,
+				ImportStateId:      "200",
+				ImportStatePersist: true,
+			},
+			{
+				Config: config,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectEmptyPlan(),
+					},
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_eigrp.test", "as_number", "200"),
+					resource.TestCheckResourceAttr("ios_eigrp.test", "networks.0", "10.0.0.0/24"),
+					resource.TestCheckResourceAttr("ios_eigrp.test", "networks.1", "172.16.0.0/16"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccEigrpResourceImportMissing(t *testing.T) {
+	d := newAccDevice(t, "")
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: d.provider() + `
+resource "ios_eigrp" "test" {
+  as_number = 300
+}
+`,
+				ResourceName:  "ios_eigrp.test",
+				ImportState:   true,
+				ImportStateId: "300",
+				ExpectError:   regexp.MustCompile("Cannot import non-existent remote object"),
+			},
+		},
+	})
+}
@@ -6,8 +6,8 @@ package provider
 import (
 	"context"
 	"fmt"
-	"github.com/CorentinPtrl/cgnet"
 	"github.com/CorentinPtrl/cisconf"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
@@ -15,17 +15,20 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"terraform-provider-ios/internal/provider/models"
+	"terraform-provider-ios/internal/session"
 	"terraform-provider-ios/internal/utils"
 )
 
 var _ resource.Resource = &InterfaceEthernetResource{}
+var _ resource.ResourceWithModifyPlan = &InterfaceEthernetResource{}
+var _ resource.ResourceWithImportState = &InterfaceEthernetResource{}
 
 func NewInterfaceEthernetResource() resource.Resource {
 	return &InterfaceEthernetResource{}
 }
 
 type InterfaceEthernetResource struct {
-	client *cgnet.Device
+	devices *Devices
 }
 
 func (r *InterfaceEthernetResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -45,6 +48,12 @@ func (r *InterfaceEthernetResource) Schema(ctx context.Context, req resource.Sch
 		MarkdownDescription: "Switch Interface resource",
 
 		Attributes: map[string]schema.Attribute{
+			"device": deviceResourceAttribute(),
+			"planned_commands": schema.ListAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "IOS commands the next apply pushes to the device, computed during plan from the running-config. Empty once the device matches the configuration, an apply leaves the commands it pushed until the next refresh. Null with the netconf and restconf transports, which send YANG payloads rather than commands.",
+			},
 			"id": schema.StringAttribute{
 				Required:    true,
 				Description: "The ID of the interface, e.g., 'GigabitEthernet0/1'.",
@@ -90,64 +99,84 @@ func (r *InterfaceEthernetResource) Configure(ctx context.Context, req resource.
 		return
 	}
 
-	client, ok := req.ProviderData.(*cgnet.Device)
+	devices, ok := req.ProviderData.(*Devices)
 
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Resource Configure Type",
-			fmt.Sprintf("Expected *http.Client, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *provider.Devices, got: %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 
 		return
 	}
 
-	r.client = client
+	r.devices = devices
 }
 
-func (r *InterfaceEthernetResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
-	var data models.InterfaceEthernetModel
+func (r *InterfaceEthernetResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	// Nothing is planned when the resource is destroyed or the provider is
+	// not configured yet.
+	if req.Plan.Raw.IsNull() || r.devices == nil {
+		return
+	}
 
-	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	// The commands can only be computed once every planned value is known
+	// and the device can be reached.
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("planned_commands"), types.ListNull(types.StringType))...)
+	if !resp.Plan.Raw.IsFullyKnown() || r.devices.IsUnknown() {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("planned_commands"), types.ListUnknown(types.StringType))...)
+		return
+	}
+
+	var data models.InterfaceEthernetResourceModel
+
+	resp.Diagnostics.Append(resp.Plan.Get(ctx, &data)...)
 
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	inter, err := models.GetEthernetInterface(ctx, r.client, data.ID.ValueString())
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to get ethernet interface",
-			fmt.Sprintf("Unable to get ethernet interface: %s", err),
-		)
+	client, diags := r.devices.With(ctx, data.Device, "ios_ethernet_interface", data.ID.ValueString())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	var ethernetConfig *cisconf.CiscoInterface
-	ethernetConfig, err = models.InterfaceEthernetToCisconf(ctx, data)
+	marshal, err := utils.Commands(func() (any, any, error) {
+		return r.change(ctx, client, data.InterfaceEthernetModel)
+	})
 	if err != nil {
 		resp.Diagnostics.AddError(
-			"Failed to convert interface model to CISCONF",
-			fmt.Sprintf("Unable to convert interface model: %s", err),
+			"Failed to plan ethernet interface",
+			fmt.Sprintf("Unable to compute the commands configuring the ethernet interface: %s", err),
 		)
 		return
 	}
-	interCisco, err := models.InterfaceEthernetToCisconf(ctx, inter)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to convert interface model to CISCONF",
-			fmt.Sprintf("Unable to convert interface model: %s", err),
-		)
+
+	setPlannedCommands(ctx, client, req, resp, marshal)
+}
+
+func (r *InterfaceEthernetResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data models.InterfaceEthernetResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
 		return
 	}
-	marshal, err := cisconf.Diff(*interCisco, *ethernetConfig)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to diff interface",
-			fmt.Sprintf("Unable to diff interface: %s", err),
-		)
+
+	client, diags := r.devices.With(ctx, data.Device, "ios_ethernet_interface", data.ID.ValueString())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
-	err = utils.ConfigDevice(marshal, r.client)
+
+	client.Lock()
+	defer unlock(client, &resp.Diagnostics)
+
+	err := utils.ConfigDevice(client, func() (any, any, error) {
+		return r.change(ctx, client, data.InterfaceEthernetModel)
+	})
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to configure interface",
@@ -156,7 +185,7 @@ func (r *InterfaceEthernetResource) Create(ctx context.Context, req resource.Cre
 		return
 	}
 
-	inter, err = models.GetEthernetInterface(ctx, r.client, data.ID.ValueString())
+	inter, err := models.GetEthernetInterface(ctx, client, data.ID.ValueString())
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to get ethernet interface",
@@ -165,11 +194,15 @@ func (r *InterfaceEthernetResource) Create(ctx context.Context, req resource.Cre
 		return
 	}
 
-	resp.Diagnostics.Append(resp.State.Set(ctx, &inter)...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, models.InterfaceEthernetResourceModel{
+		InterfaceEthernetModel: inter,
+		Device:                 data.Device,
+		PlannedCommands:        appliedCommands(client, data.PlannedCommands),
+	})...)
 }
 
 func (r *InterfaceEthernetResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
-	var data models.InterfaceEthernetModel
+	var data models.InterfaceEthernetResourceModel
 
 	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
 
@@ -177,7 +210,19 @@ func (r *InterfaceEthernetResource) Read(ctx context.Context, req resource.ReadR
 		return
 	}
 
-	inter, err := models.GetEthernetInterface(ctx, r.client, data.ID.ValueString())
+	client, diags := r.devices.With(ctx, data.Device, "ios_ethernet_interface", data.ID.ValueString())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// The device cannot be reached until the provider configuration is known,
+	// keep the prior state until then.
+	if client.IsUnknown() {
+		return
+	}
+
+	inter, err := models.GetEthernetInterface(ctx, client, data.ID.ValueString())
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to get ethernet interface",
@@ -186,11 +231,15 @@ func (r *InterfaceEthernetResource) Read(ctx context.Context, req resource.ReadR
 		return
 	}
 
-	resp.Diagnostics.Append(resp.State.Set(ctx, &inter)...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, models.InterfaceEthernetResourceModel{
+		InterfaceEthernetModel: inter,
+		Device:                 data.Device,
+		PlannedCommands:        refreshedCommands(client),
+	})...)
 }
 
 func (r *InterfaceEthernetResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
-	var data models.InterfaceEthernetModel
+	var data models.InterfaceEthernetResourceModel
 
 	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
 
@@ -198,41 +247,18 @@ func (r *InterfaceEthernetResource) Update(ctx context.Context, req resource.Upd
 		return
 	}
 
-	inter, err := models.GetEthernetInterface(ctx, r.client, data.ID.ValueString())
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to get ethernet interface",
-			fmt.Sprintf("Unable to get ethernet interface: %s", err),
-		)
+	client, diags := r.devices.With(ctx, data.Device, "ios_ethernet_interface", data.ID.ValueString())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	var ethernetConfig *cisconf.CiscoInterface
-	ethernetConfig, err = models.InterfaceEthernetToCisconf(ctx, data)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to convert interface model to CISCONF",
-			fmt.Sprintf("Unable to convert interface model: %s", err),
-		)
-		return
-	}
-	interCisco, err := models.InterfaceEthernetToCisconf(ctx, inter)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to convert interface model to CISCONF",
-			fmt.Sprintf("Unable to convert interface model: %s", err),
-		)
-		return
-	}
-	marshal, err := cisconf.Diff(*interCisco, *ethernetConfig)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to diff interface",
-			fmt.Sprintf("Unable to diff interface: %s", err),
-		)
-		return
-	}
-	err = utils.ConfigDevice(marshal, r.client)
+	client.Lock()
+	defer unlock(client, &resp.Diagnostics)
+
+	err := utils.ConfigDevice(client, func() (any, any, error) {
+		return r.change(ctx, client, data.InterfaceEthernetModel)
+	})
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to configure interface",
@@ -241,7 +267,7 @@ func (r *InterfaceEthernetResource) Update(ctx context.Context, req resource.Upd
 		return
 	}
 
-	inter, err = models.GetEthernetInterface(ctx, r.client, data.ID.ValueString())
+	inter, err := models.GetEthernetInterface(ctx, client, data.ID.ValueString())
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to get ethernet interface",
@@ -250,11 +276,15 @@ func (r *InterfaceEthernetResource) Update(ctx context.Context, req resource.Upd
 		return
 	}
 
-	resp.Diagnostics.Append(resp.State.Set(ctx, &inter)...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, models.InterfaceEthernetResourceModel{
+		InterfaceEthernetModel: inter,
+		Device:                 data.Device,
+		PlannedCommands:        appliedCommands(client, data.PlannedCommands),
+	})...)
 }
 
 func (r *InterfaceEthernetResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
-	var data models.InterfaceEthernetModel
+	var data models.InterfaceEthernetResourceModel
 
 	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
 
@@ -262,7 +292,16 @@ func (r *InterfaceEthernetResource) Delete(ctx context.Context, req resource.Del
 		return
 	}
 
-	err := r.client.Configure([]string{"default interface " + data.ID.ValueString()})
+	client, diags := r.devices.With(ctx, data.Device, "ios_ethernet_interface", data.ID.ValueString())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client.Lock()
+	defer unlock(client, &resp.Diagnostics)
+
+	err := client.Remove(cisconf.CiscoInterface{Parent: cisconf.CiscoInterfaceParent{Identifier: data.ID.ValueString()}}, []string{"default interface " + data.ID.ValueString()})
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to configure interface",
@@ -271,3 +310,27 @@ func (r *InterfaceEthernetResource) Delete(ctx context.Context, req resource.Del
 		return
 	}
 }
+
+// ImportState adopts an ethernet interface from its name, e.g.
+// 'GigabitEthernet0/1'.
+func (r *InterfaceEthernetResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), r.devices.importDevice(ctx, req.ID, resp))...)
+}
+
+// change returns the interface read from the running-config and the
+// interface as planned in data.
+func (r *InterfaceEthernetResource) change(ctx context.Context, client *session.Session, data models.InterfaceEthernetModel) (any, any, error) {
+	ethernetConfig, err := models.InterfaceEthernetToCisconf(ctx, data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to convert interface model: %w", err)
+	}
+	inter, err := models.GetEthernetInterface(ctx, client, data.ID.ValueString())
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to get ethernet interface: %w", err)
+	}
+	interCisco, err := models.InterfaceEthernetToCisconf(ctx, inter)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to convert interface model: %w", err)
+	}
+	return *interCisco, *ethernetConfig, nil
+}
@@ -0,0 +1,131 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
+)
+
+func TestAccInterfaceEthernetResource(t *testing.T) {
+	d := newAccDevice(t, "")
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: d.provider() + `
+resource "ios_ethernet_interface" "test" {
+  id               = "GigabitEthernet1/0"
+  description      = "uplink"
+  ips              = [{ ip = "10.0.0.1/24" }]
+  helper_addresses = ["10.0.1.10"]
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_ethernet_interface.test", "description", "uplink"),
+					resource.TestCheckResourceAttr("ios_ethernet_interface.test", "shutdown", "false"),
+					resource.TestCheckResourceAttr("ios_ethernet_interface.test", "ips.0.ip", "10.0.0.1/24"),
+					resource.TestCheckResourceAttr("ios_ethernet_interface.test", "helper_addresses.0", "10.0.1.10"),
+					d.contains(" description uplink\n"),
+					d.contains(" ip address 10.0.0.1 255.255.255.0\n"),
+					d.contains(" ip helper-address 10.0.1.10\n"),
+					d.lacks("interface GigabitEthernet1/0\n no switchport\n no ip address\n shutdown\n"),
+				),
+			},
+			{
+				Config: d.provider() + `
+resource "ios_ethernet_interface" "test" {
+  id               = "GigabitEthernet1/0"
+  description      = "uplink"
+  shutdown         = true
+  ips              = [{ ip = "10.0.2.1/25" }]
+  helper_addresses = ["10.0.1.10"]
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_ethernet_interface.test", "shutdown", "true"),
+					resource.TestCheckResourceAttr("ios_ethernet_interface.test", "ips.0.ip", "10.0.2.1/25"),
+					d.contains(" ip address 10.0.2.1 255.255.255.128\n"),
+					d.lacks("10.0.0.1"),
+				),
+			},
+			{
+				ResourceName:            "ios_ethernet_interface.test",
+				ImportState:             true,
+				ImportStateId:           "GigabitEthernet1/0",
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"planned_commands"},
+			},
+		},
+		CheckDestroy: resource.ComposeAggregateTestCheckFunc(
+			d.lacks(" description uplink\n"),
+			d.contains("interface GigabitEthernet1/0\n!\n"),
+		),
+	})
+}
+
+func TestAccInterfaceEthernetResourceSecondary(t *testing.T) {
+	d := newAccDevice(t, "")
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				// IOS replaces the primary address with any address not
+				// flagged secondary, the addresses after the first one are
+				// secondary.
+				Config: d.provider() + `
+resource "ios_ethernet_interface" "test" {
+  id  = "GigabitEthernet1/0"
+  ips = [{ ip = "10.0.0.1/24" }, { ip = "10.0.1.1/24" }, { ip = "10.0.2.1/24" }]
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_ethernet_interface.test", "ips.0.ip", "10.0.0.1/24"),
+					resource.TestCheckResourceAttr("ios_ethernet_interface.test", "ips.2.ip", "10.0.2.1/24"),
+					d.contains(" ip address 10.0.0.1 255.255.255.0\n ip address 10.0.1.1 255.255.255.0 secondary\n ip address 10.0.2.1 255.255.255.0 secondary\n"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccInterfaceEthernetResourceImport(t *testing.T) {
+	d := newAccDevice(t, "")
+	config := d.provider() + `
+resource "ios_ethernet_interface" "test" {
+  id  = "GigabitEthernet0/0"
+  ips = [{ ip = "192.168.0.2/24" }]
+}
+`
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:             config,
+				ResourceName:       "ios_ethernet_interface.test",
+				ImportState:        true,
+				ImportStateId:      "GigabitEthernet0/0",
+				ImportStatePersist: true,
+			},
+			{
+				Config: config,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectEmptyPlan(),
+					},
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_ethernet_interface.test", "id", "GigabitEthernet0/0"),
+					resource.TestCheckResourceAttr("ios_ethernet_interface.test", "ips.0.ip", "192.168.0.2/24"),
+					resource.TestCheckResourceAttr("ios_ethernet_interface.test", "shutdown", "false"),
+				),
+			},
+		},
+	})
+}
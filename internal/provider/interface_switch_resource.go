@@ -6,8 +6,9 @@ package provider
 import (
 	"context"
 	"fmt"
-	"github.com/CorentinPtrl/cgnet"
 	"github.com/CorentinPtrl/cisconf"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
@@ -15,19 +16,23 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listdefault"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/objectdefault"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"terraform-provider-ios/internal/provider/models"
+	"terraform-provider-ios/internal/session"
 	"terraform-provider-ios/internal/utils"
 )
 
 var _ resource.Resource = &InterfaceSwitchResource{}
+var _ resource.ResourceWithModifyPlan = &InterfaceSwitchResource{}
+var _ resource.ResourceWithImportState = &InterfaceSwitchResource{}
 
 func NewInterfaceSwitchResource() resource.Resource {
 	return &InterfaceSwitchResource{}
 }
 
 type InterfaceSwitchResource struct {
-	client *cgnet.Device
+	devices *Devices
 }
 
 func (r *InterfaceSwitchResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -47,6 +52,12 @@ func (r *InterfaceSwitchResource) Schema(ctx context.Context, req resource.Schem
 		MarkdownDescription: "Switch Interface resource",
 
 		Attributes: map[string]schema.Attribute{
+			"device": deviceResourceAttribute(),
+			"planned_commands": schema.ListAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "IOS commands the next apply pushes to the device, computed during plan from the running-config. Empty once the device matches the configuration, an apply leaves the commands it pushed until the next refresh. Null with the netconf and restconf transports, which send YANG payloads rather than commands.",
+			},
 			"id": schema.StringAttribute{
 				Required:    true,
 				Description: "The ID of the interface, e.g., 'GigabitEthernet0/1'.",
@@ -58,11 +69,11 @@ func (r *InterfaceSwitchResource) Schema(ctx context.Context, req resource.Schem
 			"trunk": schema.SingleNestedAttribute{
 				Attributes: map[string]schema.Attribute{
 					"encapsulation": schema.StringAttribute{
-						MarkdownDescription: "Encapsulation type",
+						MarkdownDescription: "Encapsulation type. The switches only running 802.1Q trunks, such as the Catalyst 9000, 3850 and 2960, refuse other encapsulations and are never sent the encapsulation command.",
 						Optional:            true,
 						Computed:            true,
 						Default:             stringdefault.StaticString("dot1q"),
-						Description:         "Encapsulation type for the trunk interface. Default is 'dot1q'.",
+						Description:         "Encapsulation type for the trunk interface. Default is 'dot1q'. The switches only running 802.1Q trunks, such as the Catalyst 9000, 3850 and 2960, refuse other encapsulations and are never sent the encapsulation command.",
 					},
 					"allowed_vlans": schema.ListAttribute{
 						MarkdownDescription: "Allowed VLANs",
@@ -133,64 +144,116 @@ func (r *InterfaceSwitchResource) Configure(ctx context.Context, req resource.Co
 		return
 	}
 
-	client, ok := req.ProviderData.(*cgnet.Device)
+	devices, ok := req.ProviderData.(*Devices)
 
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Resource Configure Type",
-			fmt.Sprintf("Expected *http.Client, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *provider.Devices, got: %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 
 		return
 	}
 
-	r.client = client
+	r.devices = devices
 }
 
-func (r *InterfaceSwitchResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
-	var data models.InterfaceSwitchModel
+func (r *InterfaceSwitchResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	// Nothing is planned when the resource is destroyed or the provider is
+	// not configured yet.
+	if req.Plan.Raw.IsNull() || r.devices == nil {
+		return
+	}
 
-	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	// The platform is checked before the switchport mode is known, which is
+	// never the case when the interface is created.
+	if !r.devices.IsUnknown() {
+		resp.Diagnostics.Append(r.checkPlatform(ctx, req.Plan)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	// The commands can only be computed once every planned value is known
+	// and the device can be reached.
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("planned_commands"), types.ListNull(types.StringType))...)
+	if !resp.Plan.Raw.IsFullyKnown() || r.devices.IsUnknown() {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("planned_commands"), types.ListUnknown(types.StringType))...)
+		return
+	}
+
+	var data models.InterfaceSwitchResourceModel
+
+	resp.Diagnostics.Append(resp.Plan.Get(ctx, &data)...)
 
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	inter, err := models.GetSwitchInterface(ctx, r.client, data.ID.ValueString())
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to get switch interface",
-			fmt.Sprintf("Unable to get switch interface: %s", err),
-		)
+	client, diags := r.devices.With(ctx, data.Device, "ios_switch_interface", data.ID.ValueString())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	var interfaceSwitch *cisconf.CiscoInterface
-	interfaceSwitch, err = models.InterfaceSwitchToCisconf(ctx, data)
+	marshal, err := utils.Commands(func() (any, any, error) {
+		return r.change(ctx, client, data.InterfaceSwitchModel)
+	})
 	if err != nil {
 		resp.Diagnostics.AddError(
-			"Failed to convert interface switch model",
-			fmt.Sprintf("Unable to convert interface switch model: %s", err),
+			"Failed to plan switch interface",
+			fmt.Sprintf("Unable to compute the commands configuring the switch interface: %s", err),
 		)
 		return
 	}
-	interCisco, err := models.InterfaceSwitchToCisconf(ctx, inter)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to convert interface switch model",
-			fmt.Sprintf("Unable to convert interface switch model: %s", err),
-		)
+
+	setPlannedCommands(ctx, client, req, resp, marshal)
+}
+
+// checkPlatform refuses a trunk encapsulation the platform of the device
+// lacks. The encapsulation defaults to dot1q, which every platform runs.
+func (r *InterfaceSwitchResource) checkPlatform(ctx context.Context, plan tfsdk.Plan) diag.Diagnostics {
+	var id, device, encapsulation types.String
+	diags := plan.GetAttribute(ctx, path.Root("id"), &id)
+	diags.Append(plan.GetAttribute(ctx, path.Root("device"), &device)...)
+	diags.Append(plan.GetAttribute(ctx, path.Root("trunk").AtName("encapsulation"), &encapsulation)...)
+	if diags.HasError() || device.IsUnknown() || encapsulation.IsUnknown() {
+		return diags
+	}
+	if value := encapsulation.ValueString(); value == "" || value == "dot1q" {
+		return diags
+	}
+
+	client, clientDiags := r.devices.With(ctx, device, "ios_switch_interface", id.ValueString())
+	diags.Append(clientDiags...)
+	if diags.HasError() {
+		return diags
+	}
+	diags.Append(checkFeature(ctx, client, models.TrunkEncapsulation, path.Root("trunk").AtName("encapsulation"))...)
+	return diags
+}
+
+func (r *InterfaceSwitchResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data models.InterfaceSwitchResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
 		return
 	}
-	marshal, err := cisconf.Diff(*interCisco, *interfaceSwitch)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to diff interface switch",
-			fmt.Sprintf("Unable to diff interface switch: %s", err),
-		)
+
+	client, diags := r.devices.With(ctx, data.Device, "ios_switch_interface", data.ID.ValueString())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
-	err = utils.ConfigDevice(marshal, r.client)
+
+	client.Lock()
+	defer unlock(client, &resp.Diagnostics)
+
+	err := utils.ConfigDevice(client, func() (any, any, error) {
+		return r.change(ctx, client, data.InterfaceSwitchModel)
+	})
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to configure interface",
@@ -199,7 +262,7 @@ func (r *InterfaceSwitchResource) Create(ctx context.Context, req resource.Creat
 		return
 	}
 
-	inter, err = models.GetSwitchInterface(ctx, r.client, data.ID.ValueString())
+	inter, err := models.GetSwitchInterface(ctx, client, data.ID.ValueString())
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to get switch interface",
@@ -208,11 +271,15 @@ func (r *InterfaceSwitchResource) Create(ctx context.Context, req resource.Creat
 		return
 	}
 
-	resp.Diagnostics.Append(resp.State.Set(ctx, &inter)...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, models.InterfaceSwitchResourceModel{
+		InterfaceSwitchModel: inter,
+		Device:               data.Device,
+		PlannedCommands:      appliedCommands(client, data.PlannedCommands),
+	})...)
 }
 
 func (r *InterfaceSwitchResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
-	var data models.InterfaceSwitchModel
+	var data models.InterfaceSwitchResourceModel
 
 	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
 
@@ -220,7 +287,19 @@ func (r *InterfaceSwitchResource) Read(ctx context.Context, req resource.ReadReq
 		return
 	}
 
-	inter, err := models.GetSwitchInterface(ctx, r.client, data.ID.ValueString())
+	client, diags := r.devices.With(ctx, data.Device, "ios_switch_interface", data.ID.ValueString())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// The device cannot be reached until the provider configuration is known,
+	// keep the prior state until then.
+	if client.IsUnknown() {
+		return
+	}
+
+	inter, err := models.GetSwitchInterface(ctx, client, data.ID.ValueString())
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to get switch interface",
@@ -229,11 +308,15 @@ func (r *InterfaceSwitchResource) Read(ctx context.Context, req resource.ReadReq
 		return
 	}
 
-	resp.Diagnostics.Append(resp.State.Set(ctx, &inter)...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, models.InterfaceSwitchResourceModel{
+		InterfaceSwitchModel: inter,
+		Device:               data.Device,
+		PlannedCommands:      refreshedCommands(client),
+	})...)
 }
 
 func (r *InterfaceSwitchResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
-	var data models.InterfaceSwitchModel
+	var data models.InterfaceSwitchResourceModel
 
 	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
 
@@ -241,40 +324,18 @@ func (r *InterfaceSwitchResource) Update(ctx context.Context, req resource.Updat
 		return
 	}
 
-	inter, err := models.GetSwitchInterface(ctx, r.client, data.ID.ValueString())
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to get switch interface",
-			fmt.Sprintf("Unable to get switch interface: %s", err),
-		)
-		return
-	}
-	var interfaceSwitch *cisconf.CiscoInterface
-	interfaceSwitch, err = models.InterfaceSwitchToCisconf(ctx, data)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to convert interface switch model",
-			fmt.Sprintf("Unable to convert interface switch model: %s", err),
-		)
-		return
-	}
-	interCisco, err := models.InterfaceSwitchToCisconf(ctx, inter)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to convert interface switch model",
-			fmt.Sprintf("Unable to convert interface switch model: %s", err),
-		)
-		return
-	}
-	marshal, err := cisconf.Diff(*interCisco, *interfaceSwitch)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to diff interface",
-			fmt.Sprintf("Unable to diff interface: %s", err),
-		)
+	client, diags := r.devices.With(ctx, data.Device, "ios_switch_interface", data.ID.ValueString())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
-	err = utils.ConfigDevice(marshal, r.client)
+
+	client.Lock()
+	defer unlock(client, &resp.Diagnostics)
+
+	err := utils.ConfigDevice(client, func() (any, any, error) {
+		return r.change(ctx, client, data.InterfaceSwitchModel)
+	})
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to configure interface",
@@ -283,7 +344,7 @@ func (r *InterfaceSwitchResource) Update(ctx context.Context, req resource.Updat
 		return
 	}
 
-	inter, err = models.GetSwitchInterface(ctx, r.client, data.ID.ValueString())
+	inter, err := models.GetSwitchInterface(ctx, client, data.ID.ValueString())
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to get switch interface",
@@ -292,11 +353,15 @@ func (r *InterfaceSwitchResource) Update(ctx context.Context, req resource.Updat
 		return
 	}
 
-	resp.Diagnostics.Append(resp.State.Set(ctx, &inter)...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, models.InterfaceSwitchResourceModel{
+		InterfaceSwitchModel: inter,
+		Device:               data.Device,
+		PlannedCommands:      appliedCommands(client, data.PlannedCommands),
+	})...)
 }
 
 func (r *InterfaceSwitchResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
-	var data models.InterfaceSwitchModel
+	var data models.InterfaceSwitchResourceModel
 
 	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
 
@@ -304,7 +369,16 @@ func (r *InterfaceSwitchResource) Delete(ctx context.Context, req resource.Delet
 		return
 	}
 
-	err := r.client.Configure([]string{"default interface " + data.ID.ValueString()})
+	client, diags := r.devices.With(ctx, data.Device, "ios_switch_interface", data.ID.ValueString())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client.Lock()
+	defer unlock(client, &resp.Diagnostics)
+
+	err := client.Remove(cisconf.CiscoInterface{Parent: cisconf.CiscoInterfaceParent{Identifier: data.ID.ValueString()}}, []string{"default interface " + data.ID.ValueString()})
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to configure interface",
@@ -313,3 +387,33 @@ func (r *InterfaceSwitchResource) Delete(ctx context.Context, req resource.Delet
 		return
 	}
 }
+
+// ImportState adopts a switch interface from its name, e.g.
+// 'GigabitEthernet0/1'.
+func (r *InterfaceSwitchResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), r.devices.importDevice(ctx, req.ID, resp))...)
+}
+
+// change returns the interface read from the running-config and the
+// interface as planned in data.
+func (r *InterfaceSwitchResource) change(ctx context.Context, client *session.Session, data models.InterfaceSwitchModel) (any, any, error) {
+	interfaceSwitch, err := models.InterfaceSwitchToCisconf(ctx, data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to convert interface switch model: %w", err)
+	}
+	inter, err := models.GetSwitchInterface(ctx, client, data.ID.ValueString())
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to get switch interface: %w", err)
+	}
+	interCisco, err := models.InterfaceSwitchToCisconf(ctx, inter)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to convert interface switch model: %w", err)
+	}
+	facts, err := models.GetFacts(ctx, client)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read the platform of the device: %w", err)
+	}
+	facts.PushTrunk(interCisco)
+	facts.PushTrunk(interfaceSwitch)
+	return *interCisco, *interfaceSwitch, nil
+}
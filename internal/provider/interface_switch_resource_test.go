@@ -0,0 +1,141 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
+	"terraform-provider-ios/internal/fakeios"
+)
+
+func TestAccInterfaceSwitchResource(t *testing.T) {
+	d := newAccDevice(t, "")
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: d.provider() + `
+resource "ios_switch_interface" "test" {
+  id          = "GigabitEthernet0/1"
+  description = "office"
+  access      = { access_vlan = 20 }
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_switch_interface.test", "switchport", "access"),
+					resource.TestCheckResourceAttr("ios_switch_interface.test", "access.access_vlan", "20"),
+					resource.TestCheckResourceAttr("ios_switch_interface.test", "description", "office"),
+					d.contains(" switchport access vlan 20\n"),
+					d.contains(" switchport mode access\n"),
+				),
+			},
+			{
+				Config: d.provider() + `
+resource "ios_switch_interface" "test" {
+  id          = "GigabitEthernet0/1"
+  description = "office"
+  trunk       = { allowed_vlans = [10, 20] }
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_switch_interface.test", "switchport", "trunk"),
+					resource.TestCheckResourceAttr("ios_switch_interface.test", "trunk.encapsulation", "dot1q"),
+					resource.TestCheckResourceAttr("ios_switch_interface.test", "trunk.allowed_vlans.1", "20"),
+					d.contains(" switchport mode trunk\n"),
+					d.lacks(" switchport mode access\n"),
+				),
+			},
+			{
+				ResourceName:            "ios_switch_interface.test",
+				ImportState:             true,
+				ImportStateId:           "GigabitEthernet0/1",
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"planned_commands"},
+			},
+		},
+		CheckDestroy: d.contains("interface GigabitEthernet0/1\n!\n"),
+	})
+}
+
+func TestAccInterfaceSwitchResourceImport(t *testing.T) {
+	d := newAccDevice(t, strings.Replace(fakeios.DefaultConfig, "interface GigabitEthernet0/2\n!",
+		"interface GigabitEthernet0/2\n description printers\n switchport trunk encapsulation dot1q\n switchport trunk allowed vlan 10,30\n switchport mode trunk\n!", 1))
+	config := d.provider() + `
+resource "ios_switch_interface" "test" {
+  id          = "GigabitEthernet0/2"
+  description = "printers"
+  trunk       = { allowed_vlans = [10, 30] }
+}
+`
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:             config,
+				ResourceName:       "ios_switch_interface.test",
+				ImportState:        true,
+				ImportStateId:      "GigabitEthernet0/2",
+				ImportStatePersist: true,
+			},
+			{
+				Config: config,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectEmptyPlan(),
+					},
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_switch_interface.test", "id", "GigabitEthernet0/2"),
+					resource.TestCheckResourceAttr("ios_switch_interface.test", "description", "printers"),
+					resource.TestCheckResourceAttr("ios_switch_interface.test", "switchport", "trunk"),
+					resource.TestCheckResourceAttr("ios_switch_interface.test", "trunk.encapsulation", "dot1q"),
+					resource.TestCheckResourceAttr("ios_switch_interface.test", "trunk.allowed_vlans.1", "30"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccInterfaceSwitchResourceCatalyst9000(t *testing.T) {
+	d := newAccDevice(t, "")
+	d.runs(t, "iosxe17_c9300")
+	d.Reject("switchport trunk encapsulation")
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				// The switch only runs 802.1Q trunks, the encapsulation
+				// command is left out of the commands and dot1q read back
+				// from the running-config.
+				Config: d.provider() + `
+resource "ios_switch_interface" "test" {
+  id    = "GigabitEthernet0/1"
+  trunk = { allowed_vlans = [10, 20] }
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_switch_interface.test", "trunk.encapsulation", "dot1q"),
+					d.contains(" switchport mode trunk\n"),
+					d.lacks(" switchport trunk encapsulation"),
+				),
+			},
+			{
+				Config: d.provider() + `
+resource "ios_switch_interface" "test" {
+  id    = "GigabitEthernet0/2"
+  trunk = { encapsulation = "isl" }
+}
+`,
+				ExpectError: regexp.MustCompile(`(?s)Unsupported Cisco IOS Feature.*Catalyst 9000\s+switch\s+C9300-48P`),
+			},
+		},
+	})
+}
@@ -6,7 +6,6 @@ package provider
 import (
 	"context"
 	"fmt"
-	"github.com/CorentinPtrl/cgnet"
 	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
@@ -21,7 +20,7 @@ func NewInterfacesDataSource() datasource.DataSource {
 }
 
 type InterfacesDataSource struct {
-	client *cgnet.Device
+	devices *Devices
 }
 
 func (d *InterfacesDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
@@ -33,6 +32,7 @@ func (d *InterfacesDataSource) Schema(ctx context.Context, req datasource.Schema
 		MarkdownDescription: "Interfaces data source",
 
 		Attributes: map[string]schema.Attribute{
+			"device": deviceDataSourceAttribute(),
 			"interfaces": schema.ListNestedAttribute{
 				Computed: true,
 				NestedObject: schema.NestedAttributeObject{
@@ -90,18 +90,18 @@ func (d *InterfacesDataSource) Configure(ctx context.Context, req datasource.Con
 		return
 	}
 
-	client, ok := req.ProviderData.(*cgnet.Device)
+	devices, ok := req.ProviderData.(*Devices)
 
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Data Source Configure Type",
-			fmt.Sprintf("Expected *http.Client, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *provider.Devices, got: %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 
 		return
 	}
 
-	d.client = client
+	d.devices = devices
 }
 
 func (d *InterfacesDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
@@ -113,7 +113,22 @@ func (d *InterfacesDataSource) Read(ctx context.Context, req datasource.ReadRequ
 		return
 	}
 
-	interfaces, err := models.GetSwitchInterfaces(ctx, d.client)
+	client, diags := d.devices.With(ctx, data.Device, "ios_interfaces", "")
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if client.IsUnknown() {
+		resp.Diagnostics.AddError(
+			"Unknown Cisco IOS Provider Configuration",
+			"The data source cannot be read as the provider configuration depends on values not known until apply. "+
+				"Add a depends_on on the resources the provider configuration refers to so the read happens during apply.",
+		)
+		return
+	}
+
+	interfaces, err := models.GetSwitchInterfaces(ctx, client)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to get interfaces",
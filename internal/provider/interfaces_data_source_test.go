@@ -0,0 +1,38 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"terraform-provider-ios/internal/fakeios"
+)
+
+func TestAccInterfacesDataSource(t *testing.T) {
+	d := newAccDevice(t, strings.Replace(fakeios.DefaultConfig, "interface GigabitEthernet0/2\n",
+		"interface GigabitEthernet0/2\n description printer\n switchport access vlan 30\n switchport mode access\n", 1))
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: d.provider() + `
+data "ios_interfaces" "test" {}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ios_interfaces.test", "interfaces.#", "6"),
+					resource.TestCheckResourceAttr("data.ios_interfaces.test", "interfaces.0.id", "GigabitEthernet0/0"),
+					resource.TestCheckNoResourceAttr("data.ios_interfaces.test", "interfaces.0.switchport"),
+					resource.TestCheckResourceAttr("data.ios_interfaces.test", "interfaces.2.id", "GigabitEthernet0/2"),
+					resource.TestCheckResourceAttr("data.ios_interfaces.test", "interfaces.2.description", "printer"),
+					resource.TestCheckResourceAttr("data.ios_interfaces.test", "interfaces.2.switchport", "access"),
+					resource.TestCheckResourceAttr("data.ios_interfaces.test", "interfaces.2.access.access_vlan", "30"),
+					resource.TestCheckResourceAttr("data.ios_interfaces.test", "interfaces.4.shutdown", "true"),
+				),
+			},
+		},
+	})
+}
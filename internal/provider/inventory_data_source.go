@@ -0,0 +1,198 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"slices"
+	"strconv"
+	"terraform-provider-ios/internal/provider/models"
+)
+
+var _ datasource.DataSource = &InventoryDataSource{}
+
+func NewInventoryDataSource() datasource.DataSource {
+	return &InventoryDataSource{}
+}
+
+type InventoryDataSource struct {
+	devices *Devices
+}
+
+func (d *InventoryDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_inventory"
+}
+
+func (d *InventoryDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Hosts and groups of the Ansible inventory of the provider, read from its inventory_file without connecting to the devices.",
+
+		Attributes: map[string]schema.Attribute{
+			"hosts": schema.MapNestedAttribute{
+				Computed:    true,
+				Description: "Hosts of the inventory by name, the value of the device attribute selecting them.",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"host": schema.StringAttribute{
+							Computed:    true,
+							Description: "Address of the host, its ansible_host or else its name.",
+						},
+						"port": schema.Int32Attribute{
+							Computed:    true,
+							Description: "Port of the host, its ansible_port.",
+						},
+						"username": schema.StringAttribute{
+							Computed:    true,
+							Description: "Username of the host, its ansible_user.",
+						},
+						"network_os": schema.StringAttribute{
+							Computed:    true,
+							Description: "Network OS of the host, its ansible_network_os.",
+						},
+						"groups": schema.ListAttribute{
+							ElementType: types.StringType,
+							Computed:    true,
+							Description: "Groups of the host, with their ancestors.",
+						},
+						"vars": schema.MapAttribute{
+							ElementType: types.StringType,
+							Computed:    true,
+							Description: "Variables of the host merged with those of its groups, without the passwords. The lists and maps are encoded in JSON.",
+						},
+						"managed": schema.BoolAttribute{
+							Computed:    true,
+							Description: "Whether the resources can select the host, false for the hosts the provider refuses such as those of another network OS.",
+						},
+					},
+				},
+			},
+			"groups": schema.MapNestedAttribute{
+				Computed:    true,
+				Description: "Groups of the inventory by name, including all and ungrouped.",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"hosts": schema.ListAttribute{
+							ElementType: types.StringType,
+							Computed:    true,
+							Description: "Hosts of the group and of its children.",
+						},
+						"children": schema.ListAttribute{
+							ElementType: types.StringType,
+							Computed:    true,
+							Description: "Child groups of the group.",
+						},
+						"vars": schema.MapAttribute{
+							ElementType: types.StringType,
+							Computed:    true,
+							Description: "Variables of the group, without the passwords.",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (d *InventoryDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	devices, ok := req.ProviderData.(*Devices)
+
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Configure Type",
+			fmt.Sprintf("Expected *provider.Devices, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	d.devices = devices
+}
+
+func (d *InventoryDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	if d.devices.IsUnknown() {
+		resp.Diagnostics.AddError(
+			"Unknown Cisco IOS Provider Configuration",
+			"The data source cannot be read as the provider configuration depends on values not known until apply. "+
+				"Add a depends_on on the resources the provider configuration refers to so the read happens during apply.",
+		)
+		return
+	}
+
+	if d.devices.inventory == nil {
+		resp.Diagnostics.AddError(
+			"Missing Cisco IOS Inventory",
+			"The data source cannot be read as the provider has no Ansible inventory. "+
+				"Set the inventory_file value in the provider configuration or use the IOS_INVENTORY_FILE environment variable.",
+		)
+		return
+	}
+
+	data := models.InventoryDataSourceModel{
+		Hosts:  map[string]models.InventoryHostModel{},
+		Groups: map[string]models.InventoryGroupModel{},
+	}
+	for name, host := range d.devices.inventory.Hosts {
+		model := models.InventoryHostModel{
+			Host:      types.StringValue(name),
+			Port:      types.Int32Null(),
+			Username:  types.StringNull(),
+			NetworkOS: types.StringNull(),
+			Groups:    stringValues(host.Groups),
+			Vars:      map[string]types.String{},
+			Managed:   types.BoolValue(d.devices.rejected[name] == nil),
+		}
+		for key, value := range host.Vars {
+			if slices.Contains(secretVars, key) {
+				continue
+			}
+			model.Vars[key] = types.StringValue(value)
+		}
+		if value, ok := host.Vars["ansible_host"]; ok {
+			model.Host = types.StringValue(value)
+		}
+		if port, err := strconv.ParseInt(host.Vars["ansible_port"], 10, 32); err == nil {
+			model.Port = types.Int32Value(int32(port))
+		}
+		if value, ok := host.Vars["ansible_user"]; ok {
+			model.Username = types.StringValue(value)
+		}
+		if value, ok := host.Vars["ansible_network_os"]; ok {
+			model.NetworkOS = types.StringValue(value)
+		}
+		data.Hosts[name] = model
+	}
+	for name, group := range d.devices.inventory.Groups {
+		model := models.InventoryGroupModel{
+			Hosts:    stringValues(group.Hosts),
+			Children: stringValues(group.Children),
+			Vars:     map[string]types.String{},
+		}
+		for key, value := range group.Vars {
+			if slices.Contains(secretVars, key) {
+				continue
+			}
+			model.Vars[key] = types.StringValue(value)
+		}
+		data.Groups[name] = model
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// stringValues returns the values of a list attribute, never null.
+func stringValues(values []string) []types.String {
+	result := make([]types.String, 0, len(values))
+	for _, value := range values {
+		result = append(result, types.StringValue(value))
+	}
+	return result
+}
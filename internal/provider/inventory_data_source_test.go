@@ -0,0 +1,165 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/provider"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestAccInventory(t *testing.T) {
+	core := newAccDevice(t, "")
+	inventory := filepath.Join(t.TempDir(), "hosts")
+	content := fmt.Sprintf(`[core]
+core1 ansible_host=%s ansible_port=%s
+
+[core:vars]
+ansible_network_os=cisco.ios.ios
+ansible_user=admin
+ansible_password=cisco
+
+[nexus]
+dc1 ansible_host=10.0.2.1 ansible_network_os=cisco.nxos.nxos
+`, core.server.Host(), core.server.Port())
+	if err := os.WriteFile(inventory, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write the inventory: %s", err)
+	}
+	provider := fmt.Sprintf(`
+provider "ios" {
+  username             = "nobody"
+  inventory_file       = %q
+  host_key_fingerprint = %q
+}
+`, inventory, gossh.FingerprintSHA256(core.server.HostKey()))
+	vlan := `
+resource "ios_vlan" "test" {
+  id     = 10
+  name   = "users"
+  device = "core1"
+}
+`
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: provider + vlan + `
+data "ios_inventory" "test" {}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_vlan.test", "device", "core1"),
+					core.contains("vlan 10\n name users\n"),
+					resource.TestCheckResourceAttr("data.ios_inventory.test", "hosts.core1.host", core.server.Host()),
+					resource.TestCheckResourceAttr("data.ios_inventory.test", "hosts.core1.port", core.server.Port()),
+					resource.TestCheckResourceAttr("data.ios_inventory.test", "hosts.core1.username", "admin"),
+					resource.TestCheckResourceAttr("data.ios_inventory.test", "hosts.core1.managed", "true"),
+					resource.TestCheckNoResourceAttr("data.ios_inventory.test", "hosts.core1.vars.ansible_password"),
+					resource.TestCheckResourceAttr("data.ios_inventory.test", "hosts.dc1.network_os", "cisco.nxos.nxos"),
+					resource.TestCheckResourceAttr("data.ios_inventory.test", "hosts.dc1.managed", "false"),
+					resource.TestCheckResourceAttr("data.ios_inventory.test", "groups.core.hosts.0", "core1"),
+					resource.TestCheckResourceAttr("data.ios_inventory.test", "groups.all.hosts.#", "2"),
+				),
+			},
+			{
+				// The hosts of another network OS are only refused once
+				// selected.
+				Config: provider + vlan + `
+resource "ios_vlan" "staff" {
+  id     = 20
+  name   = "staff"
+  device = "dc1"
+}
+`,
+				ExpectError: regexp.MustCompile(`(?s)Unsupported Cisco IOS Inventory Host.*cisco\.nxos\.nxos`),
+			},
+		},
+		CheckDestroy: core.lacks("vlan 10\n"),
+	})
+}
+
+func TestAccInventoryVault(t *testing.T) {
+	core := newAccDevice(t, "")
+	inventory := filepath.Join(t.TempDir(), "hosts.yml")
+	content := fmt.Sprintf(`all:
+  hosts:
+    core1:
+      ansible_host: %s
+      ansible_port: %s
+  vars:
+    ansible_network_os: cisco.ios.ios
+    ansible_user: admin
+    ansible_password: !vault |
+      $ANSIBLE_VAULT;1.1;AES256
+      33363965326261303234626463623963633531343539616138316433353830356566396130353436
+      3562643163366231316662386565383735653432386435610a306664636137376132313236653231
+`, core.server.Host(), core.server.Port())
+	if err := os.WriteFile(inventory, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write the inventory: %s", err)
+	}
+
+	// The encrypted password is left out with a warning, the password of the
+	// provider block logs in instead.
+	t.Setenv("IOS_INVENTORY_FILE", inventory)
+	t.Setenv("IOS_PASSWORD", "cisco")
+	t.Setenv("IOS_HOST_KEY_FINGERPRINT", gossh.FingerprintSHA256(core.server.HostKey()))
+	var schemaResp provider.SchemaResponse
+	p := New("test")()
+	p.Schema(context.Background(), provider.SchemaRequest{}, &schemaResp)
+	configType := schemaResp.Schema.Type().TerraformType(context.Background()).(tftypes.Object)
+	unset := map[string]tftypes.Value{}
+	for name, attributeType := range configType.AttributeTypes {
+		unset[name] = tftypes.NewValue(attributeType, nil)
+	}
+	req := provider.ConfigureRequest{Config: tfsdk.Config{Schema: schemaResp.Schema, Raw: tftypes.NewValue(configType, unset)}}
+	var resp provider.ConfigureResponse
+	p.Configure(context.Background(), req, &resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("Configure diagnostics = %+v", resp.Diagnostics)
+	}
+	warnings := resp.Diagnostics.Warnings()
+	if len(warnings) != 1 || warnings[0].Summary() != "Cisco IOS Inventory Secrets Not Decrypted" || !strings.HasSuffix(warnings[0].Detail(), "used instead for: core1 ansible_password.") {
+		t.Errorf("Configure warnings = %+v", warnings)
+	}
+	devices, ok := resp.ResourceData.(*Devices)
+	if !ok {
+		t.Fatalf("Configure resource data = %T", resp.ResourceData)
+	}
+	if password := devices.targets["core1"].password; password != "cisco" {
+		t.Errorf("core1 password = %q, want the password of the provider block", password)
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+provider "ios" {
+  password             = "cisco"
+  inventory_file       = %q
+  host_key_fingerprint = %q
+}
+
+resource "ios_vlan" "test" {
+  id     = 10
+  name   = "users"
+  device = "core1"
+}
+`, inventory, gossh.FingerprintSHA256(core.server.HostKey())),
+				Check: core.contains("vlan 10\n name users\n"),
+			},
+		},
+		CheckDestroy: core.lacks("vlan 10\n"),
+	})
+}
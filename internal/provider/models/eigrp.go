@@ -6,10 +6,10 @@ package models
 import (
 	"context"
 	"fmt"
-	"github.com/CorentinPtrl/cgnet"
 	"github.com/CorentinPtrl/cisconf"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"net"
+	"terraform-provider-ios/internal/session"
 	"terraform-provider-ios/internal/utils"
 )
 
@@ -18,6 +18,12 @@ type EigrpModel struct {
 	Networks types.List  `tfsdk:"networks"`
 }
 
+type EigrpResourceModel struct {
+	EigrpModel
+	Device          types.String `tfsdk:"device"`
+	PlannedCommands types.List   `tfsdk:"planned_commands"`
+}
+
 func EigrpToCisconf(ctx context.Context, data EigrpModel) (cisconf.Eigrp, error) {
 	var networks []cisconf.EigrpNetwork
 	var networkList []string
@@ -64,15 +70,10 @@ func EigrpFromCisconf(ctx context.Context, vlan cisconf.Eigrp) (EigrpModel, erro
 	}, nil
 }
 
-func GetEigrpProcesses(ctx context.Context, device *cgnet.Device) ([]EigrpModel, error) {
-	config, err := device.Exec("sh running-config")
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute running config: %w", err)
-	}
-	runningConfig := cisconf.Config{}
-	err = cisconf.Unmarshal(config, &runningConfig)
+func GetEigrpProcesses(ctx context.Context, device *session.Session) ([]EigrpModel, error) {
+	runningConfig, err := device.RunningConfig()
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal running config: %w", err)
+		return nil, err
 	}
 	result := []EigrpModel{}
 	for _, eigrp := range runningConfig.EIGRPProcess {
@@ -86,7 +87,9 @@ func GetEigrpProcesses(ctx context.Context, device *cgnet.Device) ([]EigrpModel,
 	return result, nil
 }
 
-func GetEigrpProcess(ctx context.Context, device *cgnet.Device, asn int64) (*EigrpModel, error) {
+// GetEigrpProcess returns the EIGRP process asn of the running-config, nil
+// without an error when the device has no such process.
+func GetEigrpProcess(ctx context.Context, device *session.Session, asn int64) (*EigrpModel, error) {
 	eigrpProcesses, err := GetEigrpProcesses(ctx, device)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get EIGRP processes: %w", err)
@@ -96,5 +99,5 @@ func GetEigrpProcess(ctx context.Context, device *cgnet.Device, asn int64) (*Eig
 			return &eigrp, nil
 		}
 	}
-	return nil, fmt.Errorf("EIGRP process with ASN %d not found", asn)
+	return nil, nil
 }
@@ -0,0 +1,93 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"terraform-provider-ios/internal/golden"
+)
+
+func TestEigrpRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	for name, config := range captures(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, eigrp := range config.EIGRPProcess {
+				model, err := EigrpFromCisconf(ctx, eigrp)
+				if err != nil {
+					t.Fatalf("EigrpFromCisconf(%d) error = %s", eigrp.Asn, err)
+				}
+				process, err := EigrpToCisconf(ctx, model)
+				if err != nil {
+					t.Fatalf("EigrpToCisconf(%s) error = %s", describe(model), err)
+				}
+				pushed := reparse(t, process)
+				if len(pushed.EIGRPProcess) != 1 {
+					t.Fatalf("router eigrp %d reads back as %d processes", eigrp.Asn, len(pushed.EIGRPProcess))
+				}
+				got, err := EigrpFromCisconf(ctx, pushed.EIGRPProcess[0])
+				if err != nil {
+					t.Fatalf("EigrpFromCisconf(%d) error = %s", eigrp.Asn, err)
+				}
+				if describe(got) != describe(model) {
+					t.Errorf("router eigrp %d reads back as\n%s\nwant\n%s", eigrp.Asn, describe(got), describe(model))
+				}
+			}
+		})
+	}
+}
+
+func TestEigrpToCisconfInvalidNetwork(t *testing.T) {
+	ctx := context.Background()
+	model := EigrpModel{
+		As:       types.Int64Value(100),
+		Networks: types.ListValueMust(types.StringType, []attr.Value{types.StringValue("10.0.0.0")}),
+	}
+	_, err := EigrpToCisconf(ctx, model)
+	if err == nil {
+		t.Errorf("EigrpToCisconf() accepted a network without prefix length")
+	}
+}
+
+func TestEigrpDiff(t *testing.T) {
+	ctx := context.Background()
+	config := captures(t)["ios15_c1921"]
+	current, err := EigrpFromCisconf(ctx, config.EIGRPProcess[0])
+	if err != nil {
+		t.Fatalf("EigrpFromCisconf() error = %s", err)
+	}
+	networks := func(cidrs ...string) types.List {
+		var values []attr.Value
+		for _, cidr := range cidrs {
+			values = append(values, types.StringValue(cidr))
+		}
+		return types.ListValueMust(types.StringType, values)
+	}
+
+	tests := []struct {
+		name    string
+		planned EigrpModel
+	}{
+		{"unchanged", current},
+		{"network added", EigrpModel{As: current.As, Networks: networks("192.168.10.0/24", "192.168.11.0/24", "203.0.113.0/30", "10.1.0.0/16")}},
+		{"network removed", EigrpModel{As: current.As, Networks: networks("192.168.10.0/24", "203.0.113.0/30")}},
+		{"network resized", EigrpModel{As: current.As, Networks: networks("192.168.10.0/23", "203.0.113.0/30")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := EigrpToCisconf(ctx, current)
+			if err != nil {
+				t.Fatalf("EigrpToCisconf() error = %s", err)
+			}
+			dest, err := EigrpToCisconf(ctx, tt.planned)
+			if err != nil {
+				t.Fatalf("EigrpToCisconf() error = %s", err)
+			}
+			golden.Check(t, diff(t, src, dest))
+		})
+	}
+}
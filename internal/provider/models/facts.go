@@ -0,0 +1,252 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/CorentinPtrl/cisconf"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/sirikothe/gotextfsm"
+	"strings"
+	"terraform-provider-ios/internal/provider/ntc"
+	"terraform-provider-ios/internal/session"
+)
+
+// Facts describes a device from its show version and show inventory, and the
+// platform the commands sent to it are adapted to.
+type Facts struct {
+	Hostname string
+	Version  string
+	Image    string
+	// Hardware lists the model of the device, of each member for a stack.
+	Hardware  []string
+	Serials   []string
+	Inventory []InventoryItem
+	Platform  Platform
+}
+
+// InventoryItem is an entry of show inventory, such as the chassis, a
+// supervisor or a power supply.
+type InventoryItem struct {
+	Name        string
+	Description string
+	PID         string
+	VID         string
+	Serial      string
+}
+
+// Platform is a family of devices sharing the same limits. The zero Platform
+// is an unknown device, sent every command as is.
+type Platform struct {
+	Family string
+	Router bool
+	// NoTrunkEncapsulation is set on the switches only running 802.1Q
+	// trunks, which neither show nor accept switchport trunk encapsulation.
+	NoTrunkEncapsulation bool
+	// VlanDatabase is set on the routers whose switch ports keep their vlans
+	// in the vlan database, edited with the vlan database exec command
+	// rather than in configuration mode.
+	VlanDatabase bool
+}
+
+// platforms maps the prefixes of the models to their platform, the first
+// matching prefix wins.
+var platforms = []struct {
+	prefixes []string
+	platform Platform
+}{
+	{[]string{"C9200", "C9300", "C9400", "C9500", "C9600"}, Platform{Family: "Catalyst 9000", NoTrunkEncapsulation: true}},
+	{[]string{"WS-C3650", "WS-C3850"}, Platform{Family: "Catalyst 3650/3850", NoTrunkEncapsulation: true}},
+	{[]string{"WS-C2960"}, Platform{Family: "Catalyst 2960", NoTrunkEncapsulation: true}},
+	{[]string{"C1000-"}, Platform{Family: "Catalyst 1000", NoTrunkEncapsulation: true}},
+	{[]string{"WS-C3560", "WS-C3750"}, Platform{Family: "Catalyst 3560/3750"}},
+	{[]string{"WS-C45"}, Platform{Family: "Catalyst 4500"}},
+	{[]string{"WS-C65"}, Platform{Family: "Catalyst 6500"}},
+	{[]string{"C8200", "C8300", "C8500", "C8000V"}, Platform{Family: "Catalyst 8000", Router: true}},
+	{[]string{"ISR4"}, Platform{Family: "ISR 4000", Router: true}},
+	{[]string{"C11"}, Platform{Family: "ISR 1000", Router: true}},
+	{[]string{"CISCO19", "CISCO29", "CISCO39"}, Platform{Family: "ISR G2", Router: true, VlanDatabase: true}},
+	{[]string{"C8"}, Platform{Family: "ISR 800", Router: true, VlanDatabase: true}},
+	{[]string{"CSR1000V"}, Platform{Family: "CSR 1000v", Router: true}},
+	{[]string{"ASR1"}, Platform{Family: "ASR 1000", Router: true}},
+}
+
+// PlatformOf returns the platform of the model, the zero Platform when the
+// model is unknown.
+func PlatformOf(model string) Platform {
+	model = strings.ToUpper(model)
+	for _, p := range platforms {
+		for _, prefix := range p.prefixes {
+			if strings.HasPrefix(model, prefix) {
+				return p.platform
+			}
+		}
+	}
+	return Platform{}
+}
+
+// Model returns the model of the device, from show version or else from the
+// chassis of show inventory.
+func (f *Facts) Model() string {
+	if len(f.Hardware) > 0 {
+		return f.Hardware[0]
+	}
+	for _, item := range f.Inventory {
+		if strings.Contains(strings.ToLower(item.Name), "chassis") && item.PID != "" {
+			return item.PID
+		}
+	}
+	if len(f.Inventory) > 0 {
+		return f.Inventory[0].PID
+	}
+	return ""
+}
+
+// Feature is a part of the configuration some platforms lack.
+type Feature int
+
+const (
+	// TrunkEncapsulation is an encapsulation other than dot1q on a trunk.
+	TrunkEncapsulation Feature = iota
+	// VlanConfiguration is the configuration of vlans in configuration mode.
+	VlanConfiguration
+)
+
+// Check returns an error explaining why the platform of the device lacks
+// feature, nil when it has it or the platform is unknown.
+func (f *Facts) Check(feature Feature) error {
+	switch {
+	case feature == TrunkEncapsulation && f.Platform.NoTrunkEncapsulation:
+		return fmt.Errorf("the %s switch %s only runs 802.1Q trunks and rejects switchport trunk encapsulation, set the encapsulation to dot1q", f.Platform.Family, f.Model())
+	case feature == VlanConfiguration && f.Platform.VlanDatabase:
+		return fmt.Errorf("the %s router %s keeps the vlans of its switch ports in the vlan database, which is edited with the vlan database exec command instead of the configuration mode the provider uses", f.Platform.Family, f.Model())
+	}
+	return nil
+}
+
+// ReadTrunk sets the encapsulation the running-config of the platform leaves
+// out of the trunk iface.
+func (f *Facts) ReadTrunk(iface *cisconf.CiscoInterface) {
+	if f.Platform.NoTrunkEncapsulation && iface.Trunk && iface.Encapsulation == "" {
+		iface.Encapsulation = "dot1q"
+	}
+}
+
+// PushTrunk leaves out the encapsulation of the trunk iface the platform
+// rejects, dot1q being its only encapsulation.
+func (f *Facts) PushTrunk(iface *cisconf.CiscoInterface) {
+	if f.Platform.NoTrunkEncapsulation && iface.Encapsulation == "dot1q" {
+		iface.Encapsulation = ""
+	}
+}
+
+// GetFacts returns the facts of the device, gathered by the first call and
+// kept for the life of the session, rather than when the session opens: only
+// the objects whose commands depend on the platform pay for show version and
+// show inventory. A device rejecting show version, or a transport without the
+// CLI, has the facts of an unknown platform.
+func GetFacts(ctx context.Context, device *session.Session) (*Facts, error) {
+	facts, err := device.Facts(func() (any, error) {
+		return gatherFacts(ctx, device)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return facts.(*Facts), nil
+}
+
+func gatherFacts(ctx context.Context, device *session.Session) (*Facts, error) {
+	facts := &Facts{}
+	if device.Structured() {
+		return facts, nil
+	}
+
+	version, err := device.Exec("show version")
+	var cmdErr *session.CommandError
+	if errors.As(err, &cmdErr) {
+		tflog.Warn(ctx, "The device rejects show version, its platform is unknown", map[string]any{"error": err.Error()})
+		return facts, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the version of the device: %w", err)
+	}
+	// Some virtual platforms have no inventory, show version is enough to
+	// find the platform.
+	inventory, err := device.Exec("show inventory")
+	if err != nil && !errors.As(err, &cmdErr) {
+		return nil, fmt.Errorf("failed to read the inventory of the device: %w", err)
+	}
+
+	parsed, err := ParseFacts(version, inventory)
+	if err != nil {
+		tflog.Warn(ctx, "The facts of the device cannot be parsed, its platform is unknown", map[string]any{"error": err.Error()})
+		return facts, nil
+	}
+	return parsed, nil
+}
+
+// ParseFacts parses the output of show version and show inventory with the
+// templates of ntc-templates.
+func ParseFacts(version string, inventory string) (*Facts, error) {
+	rows, err := parseTemplate("cisco_ios_show_version.textfsm", version)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("show version holds no version")
+	}
+	facts := &Facts{
+		Hostname: field(rows[0], "HOSTNAME"),
+		Version:  field(rows[0], "VERSION"),
+		Image:    field(rows[0], "SOFTWARE_IMAGE"),
+		Hardware: list(rows[0], "HARDWARE"),
+		Serials:  list(rows[0], "SERIAL"),
+	}
+
+	if strings.TrimSpace(inventory) != "" {
+		rows, err = parseTemplate("cisco_ios_show_inventory.textfsm", inventory)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			facts.Inventory = append(facts.Inventory, InventoryItem{
+				Name:        field(row, "NAME"),
+				Description: field(row, "DESCR"),
+				PID:         field(row, "PID"),
+				VID:         field(row, "VID"),
+				Serial:      field(row, "SN"),
+			})
+		}
+	}
+	facts.Platform = PlatformOf(facts.Model())
+	return facts, nil
+}
+
+func parseTemplate(template string, output string) ([]map[string]interface{}, error) {
+	fsm, err := ntc.GetTextFSM(template)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get textfsm %s: %w", template, err)
+	}
+	parser := gotextfsm.ParserOutput{}
+	err = parser.ParseTextString(output, fsm, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse result with %s: %w", template, err)
+	}
+	return parser.Dict, nil
+}
+
+// field returns the value of a column of a row, empty when the template has
+// no such column.
+func field(row map[string]interface{}, name string) string {
+	value, _ := row[name].(string)
+	return strings.TrimSpace(value)
+}
+
+// list returns the values of a List column of a row.
+func list(row map[string]interface{}, name string) []string {
+	values, _ := row[name].([]string)
+	return values
+}
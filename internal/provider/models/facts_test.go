@@ -0,0 +1,140 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CorentinPtrl/cisconf"
+	"terraform-provider-ios/internal/provider/ntc"
+)
+
+func TestPlatformOf(t *testing.T) {
+	tests := []struct {
+		model  string
+		family string
+		router bool
+	}{
+		{"C9300-48P", "Catalyst 9000", false},
+		{"C9200L-24T-4G", "Catalyst 9000", false},
+		{"WS-C3850-24T", "Catalyst 3650/3850", false},
+		{"WS-C2960X-48FPD-L", "Catalyst 2960", false},
+		{"WS-C3560-24PS", "Catalyst 3560/3750", false},
+		{"C8300-1N1S-4T2X", "Catalyst 8000", true},
+		{"C891F-K9", "ISR 800", true},
+		{"CISCO1921/K9", "ISR G2", true},
+		{"ISR4331/K9", "ISR 4000", true},
+		{"C1111-8P", "ISR 1000", true},
+		{"C9800-40-K9", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		platform := PlatformOf(tt.model)
+		if platform.Family != tt.family || platform.Router != tt.router {
+			t.Errorf("PlatformOf(%q) = %+v, want family %q, router %t", tt.model, platform, tt.family, tt.router)
+		}
+	}
+}
+
+func TestFactsCheck(t *testing.T) {
+	tests := []struct {
+		model   string
+		feature Feature
+		err     string
+	}{
+		{"C9300-48P", TrunkEncapsulation, "the Catalyst 9000 switch C9300-48P only runs 802.1Q trunks"},
+		{"WS-C3560-24PS", TrunkEncapsulation, ""},
+		{"C9300-48P", VlanConfiguration, ""},
+		{"CISCO1921/K9", VlanConfiguration, "the ISR G2 router CISCO1921/K9 keeps the vlans of its switch ports in the vlan database"},
+		{"ISR4331/K9", VlanConfiguration, ""},
+		{"", TrunkEncapsulation, ""},
+	}
+	for _, tt := range tests {
+		facts := &Facts{Hardware: []string{tt.model}, Platform: PlatformOf(tt.model)}
+		err := facts.Check(tt.feature)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("Check(%d) on %q error = %v, want %q", tt.feature, tt.model, err, tt.err)
+		}
+	}
+}
+
+func TestFactsTrunk(t *testing.T) {
+	tests := []struct {
+		model string
+		read  string
+		push  string
+	}{
+		{"C9300-48P", "dot1q", ""},
+		{"WS-C3560-24PS", "", "dot1q"},
+	}
+	for _, tt := range tests {
+		facts := &Facts{Hardware: []string{tt.model}, Platform: PlatformOf(tt.model)}
+		iface := cisconf.CiscoInterface{Switchport: true, Trunk: true}
+		facts.ReadTrunk(&iface)
+		if iface.Encapsulation != tt.read {
+			t.Errorf("ReadTrunk on %q encapsulation = %q, want %q", tt.model, iface.Encapsulation, tt.read)
+		}
+		iface.Encapsulation = "dot1q"
+		facts.PushTrunk(&iface)
+		if iface.Encapsulation != tt.push {
+			t.Errorf("PushTrunk on %q encapsulation = %q, want %q", tt.model, iface.Encapsulation, tt.push)
+		}
+	}
+}
+
+// skipWithoutTemplates skips the test when the ntc-templates submodule is
+// not checked out.
+func skipWithoutTemplates(t *testing.T) {
+	t.Helper()
+	for _, template := range []string{"cisco_ios_show_version.textfsm", "cisco_ios_show_inventory.textfsm"} {
+		if _, err := ntc.GetTemplate(template); err != nil {
+			t.Skipf("the ntc-templates submodule is not checked out: %s", err)
+		}
+	}
+}
+
+func TestParseFacts(t *testing.T) {
+	skipWithoutTemplates(t)
+	tests := []struct {
+		capture  string
+		hostname string
+		model    string
+		family   string
+		items    int
+	}{
+		{"iosxe17_c9300", "access-sw1", "C9300-48P", "Catalyst 9000", 3},
+		{"ios15_c1921", "branch-rtr1", "CISCO1921/K9", "ISR G2", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.capture, func(t *testing.T) {
+			version, err := os.ReadFile(filepath.Join("testdata", "facts", tt.capture+"_version.txt"))
+			if err != nil {
+				t.Fatalf("failed to read show version: %s", err)
+			}
+			inventory, err := os.ReadFile(filepath.Join("testdata", "facts", tt.capture+"_inventory.txt"))
+			if err != nil {
+				t.Fatalf("failed to read show inventory: %s", err)
+			}
+			facts, err := ParseFacts(string(version), string(inventory))
+			if err != nil {
+				t.Fatalf("ParseFacts() error = %s", err)
+			}
+			if facts.Hostname != tt.hostname {
+				t.Errorf("hostname = %q, want %q", facts.Hostname, tt.hostname)
+			}
+			if facts.Model() != tt.model {
+				t.Errorf("model = %q, want %q", facts.Model(), tt.model)
+			}
+			if facts.Platform.Family != tt.family {
+				t.Errorf("family = %q, want %q", facts.Platform.Family, tt.family)
+			}
+			if len(facts.Inventory) != tt.items {
+				t.Errorf("inventory holds %d items, want %d: %+v", len(facts.Inventory), tt.items, facts.Inventory)
+			}
+		})
+	}
+}
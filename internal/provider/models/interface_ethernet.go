@@ -6,11 +6,11 @@ package models
 import (
 	"context"
 	"fmt"
-	"github.com/CorentinPtrl/cgnet"
 	"github.com/CorentinPtrl/cisconf"
 	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"net"
+	"terraform-provider-ios/internal/session"
 	"terraform-provider-ios/internal/utils"
 )
 
@@ -20,6 +20,12 @@ type InterfaceEthernetModel struct {
 	InterfaceModel
 }
 
+type InterfaceEthernetResourceModel struct {
+	InterfaceEthernetModel
+	Device          types.String `tfsdk:"device"`
+	PlannedCommands types.List   `tfsdk:"planned_commands"`
+}
+
 type IpInterfaceModel struct {
 	Ip types.String `tfsdk:"ip"`
 }
@@ -80,7 +86,7 @@ func InterfaceEthernetToCisconf(ctx context.Context, iface InterfaceEthernetMode
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert IP list to slice: %v", err)
 	}
-	for _, ip := range ips {
+	for i, ip := range ips {
 		if ip.Ip.IsNull() || ip.Ip.ValueString() == "" {
 			return nil, fmt.Errorf("IP address is null or empty")
 		}
@@ -89,9 +95,12 @@ func InterfaceEthernetToCisconf(ctx context.Context, iface InterfaceEthernetMode
 			return nil, fmt.Errorf("failed to parse CIDR %s: %v", ip.Ip.ValueString(), err)
 		}
 		mask := net.IP(ipNet.Mask).String()
+		// The first address is the primary one, IOS replaces it with any
+		// other address not flagged secondary.
 		cisIface.Ips = append(cisIface.Ips, cisconf.Ip{
-			Ip:     host.String(),
-			Subnet: mask,
+			Ip:        host.String(),
+			Subnet:    mask,
+			Secondary: i > 0,
 		})
 	}
 	var helperAddresses []string
@@ -103,15 +112,10 @@ func InterfaceEthernetToCisconf(ctx context.Context, iface InterfaceEthernetMode
 	return cisIface, nil
 }
 
-func GetEthernetInterfaces(ctx context.Context, device *cgnet.Device) ([]InterfaceEthernetModel, error) {
-	config, err := device.Exec("sh running-config")
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute running config: %w", err)
-	}
-	var runningConfig cisconf.Config
-	err = cisconf.Unmarshal(config, &runningConfig)
+func GetEthernetInterfaces(ctx context.Context, device *session.Session) ([]InterfaceEthernetModel, error) {
+	runningConfig, err := device.RunningConfig()
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal running config: %w", err)
+		return nil, err
 	}
 	result := []InterfaceEthernetModel{}
 	for _, inter := range runningConfig.Interfaces {
@@ -125,7 +129,7 @@ func GetEthernetInterfaces(ctx context.Context, device *cgnet.Device) ([]Interfa
 	return result, nil
 }
 
-func GetEthernetInterface(ctx context.Context, device *cgnet.Device, interfaceID string) (InterfaceEthernetModel, error) {
+func GetEthernetInterface(ctx context.Context, device *session.Session, interfaceID string) (InterfaceEthernetModel, error) {
 	interfaces, err := GetEthernetInterfaces(ctx, device)
 	if err != nil {
 		return InterfaceEthernetModel{}, fmt.Errorf("failed to get Ethernet interfaces: %w", err)
@@ -0,0 +1,133 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"terraform-provider-ios/internal/golden"
+)
+
+// ethernetInterface returns the interface id of the capture as seen by the
+// ethernet interface resource.
+func ethernetInterface(t *testing.T, capture string, id string) InterfaceEthernetModel {
+	t.Helper()
+	for _, iface := range captures(t)[capture].Interfaces {
+		if iface.Parent.Identifier == id {
+			model, err := InterfaceEthernetFromCisconf(context.Background(), &iface)
+			if err != nil {
+				t.Fatalf("InterfaceEthernetFromCisconf(%s) error = %s", id, err)
+			}
+			return model
+		}
+	}
+	t.Fatalf("no interface %s in %s", id, capture)
+	return InterfaceEthernetModel{}
+}
+
+func ipList(cidrs ...string) types.List {
+	elementType := types.ObjectType{AttrTypes: IpInterfaceModel{}.AttributeTypes()}
+	values := []attr.Value{}
+	for _, cidr := range cidrs {
+		values = append(values, types.ObjectValueMust(elementType.AttrTypes, IpInterfaceModel{Ip: types.StringValue(cidr)}.AttributeValues()))
+	}
+	return types.ListValueMust(elementType, values)
+}
+
+func stringList(values ...string) types.List {
+	elements := []attr.Value{}
+	for _, value := range values {
+		elements = append(elements, types.StringValue(value))
+	}
+	return types.ListValueMust(types.StringType, elements)
+}
+
+func TestInterfaceEthernetRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	for name, config := range captures(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, iface := range config.Interfaces {
+				model, err := InterfaceEthernetFromCisconf(ctx, &iface)
+				if err != nil {
+					t.Fatalf("InterfaceEthernetFromCisconf(%s) error = %s", iface.Parent.Identifier, err)
+				}
+				cisIface, err := InterfaceEthernetToCisconf(ctx, model)
+				if err != nil {
+					t.Fatalf("InterfaceEthernetToCisconf(%s) error = %s", describe(model), err)
+				}
+				pushed := reparse(t, cisIface)
+				if len(pushed.Interfaces) != 1 {
+					t.Fatalf("interface %s reads back as %d interfaces", iface.Parent.Identifier, len(pushed.Interfaces))
+				}
+				got, err := InterfaceEthernetFromCisconf(ctx, &pushed.Interfaces[0])
+				if err != nil {
+					t.Fatalf("InterfaceEthernetFromCisconf(%s) error = %s", iface.Parent.Identifier, err)
+				}
+				if describe(got) != describe(model) {
+					t.Errorf("interface %s reads back as\n%s\nwant\n%s", iface.Parent.Identifier, describe(got), describe(model))
+				}
+			}
+		})
+	}
+}
+
+func TestInterfaceEthernetToCisconfInvalidIp(t *testing.T) {
+	ctx := context.Background()
+	for _, ip := range []string{"", "10.0.0.1", "10.0.0.1/33"} {
+		model := ethernetInterface(t, "ios15_c1921", "GigabitEthernet0/0")
+		model.Ips = ipList(ip)
+		_, err := InterfaceEthernetToCisconf(ctx, model)
+		if err == nil {
+			t.Errorf("InterfaceEthernetToCisconf() accepted the address %q", ip)
+		}
+	}
+}
+
+func TestInterfaceEthernetDiff(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		current InterfaceEthernetModel
+		modify  func(m *InterfaceEthernetModel)
+	}{
+		{"unchanged", ethernetInterface(t, "ios15_c1921", "GigabitEthernet0/1"), func(m *InterfaceEthernetModel) {}},
+		{"address", ethernetInterface(t, "ios15_c1921", "GigabitEthernet0/0"), func(m *InterfaceEthernetModel) {
+			m.Ips = ipList("203.0.113.6/30")
+		}},
+		{"secondary added", ethernetInterface(t, "iosxe16_c3850", "Vlan110"), func(m *InterfaceEthernetModel) {
+			m.Ips = ipList("10.110.0.1/24", "10.111.0.1/24")
+		}},
+		{"secondary removed", ethernetInterface(t, "ios15_c1921", "GigabitEthernet0/1"), func(m *InterfaceEthernetModel) {
+			m.Ips = ipList("192.168.10.1/24")
+		}},
+		{"helper addresses", ethernetInterface(t, "iosxe17_c9300", "Vlan10"), func(m *InterfaceEthernetModel) {
+			m.HelperAddresses = stringList("10.0.0.12")
+		}},
+		{"description", ethernetInterface(t, "ios15_c1921", "GigabitEthernet0/0"), func(m *InterfaceEthernetModel) {
+			m.Description = types.StringValue("WAN (backup)")
+		}},
+		{"shutdown", ethernetInterface(t, "ios15_c1921", "GigabitEthernet0/0"), func(m *InterfaceEthernetModel) {
+			m.Shutdown = types.BoolValue(true)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planned := tt.current
+			tt.modify(&planned)
+			src, err := InterfaceEthernetToCisconf(ctx, tt.current)
+			if err != nil {
+				t.Fatalf("InterfaceEthernetToCisconf() error = %s", err)
+			}
+			dest, err := InterfaceEthernetToCisconf(ctx, planned)
+			if err != nil {
+				t.Fatalf("InterfaceEthernetToCisconf() error = %s", err)
+			}
+			golden.Check(t, diff(t, src, dest))
+		})
+	}
+}
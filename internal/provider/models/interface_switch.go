@@ -6,16 +6,17 @@ package models
 import (
 	"context"
 	"fmt"
-	"github.com/CorentinPtrl/cgnet"
 	"github.com/CorentinPtrl/cisconf"
 	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"terraform-provider-ios/internal/session"
 )
 
 type InterfacesSwitchesDataSourceModel struct {
+	Device     types.String           `tfsdk:"device"`
 	Interfaces []InterfaceSwitchModel `tfsdk:"interfaces"`
 }
 
@@ -27,6 +28,12 @@ type InterfaceSwitchModel struct {
 	InterfaceModel
 }
 
+type InterfaceSwitchResourceModel struct {
+	InterfaceSwitchModel
+	Device          types.String `tfsdk:"device"`
+	PlannedCommands types.List   `tfsdk:"planned_commands"`
+}
+
 type Access struct {
 	AccessVlan types.Int32 `tfsdk:"access_vlan"`
 }
@@ -210,18 +217,18 @@ func InterfaceSwitchToCisconf(ctx context.Context, iface InterfaceSwitchModel) (
 	return cisIface, nil
 }
 
-func GetSwitchInterfaces(ctx context.Context, device *cgnet.Device) ([]InterfaceSwitchModel, error) {
-	config, err := device.Exec("sh running-config")
+func GetSwitchInterfaces(ctx context.Context, device *session.Session) ([]InterfaceSwitchModel, error) {
+	runningConfig, err := device.RunningConfig()
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute running config: %w", err)
+		return nil, err
 	}
-	var runningConfig cisconf.Config
-	err = cisconf.Unmarshal(config, &runningConfig)
+	facts, err := GetFacts(ctx, device)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal running config: %w", err)
+		return nil, err
 	}
 	result := []InterfaceSwitchModel{}
 	for _, inter := range runningConfig.Interfaces {
+		facts.ReadTrunk(&inter)
 		var interfaceSwitch InterfaceSwitchModel
 		interfaceSwitch, err = InterfaceSwitchFromCisconf(ctx, &inter)
 		if err != nil {
@@ -232,7 +239,7 @@ func GetSwitchInterfaces(ctx context.Context, device *cgnet.Device) ([]Interface
 	return result, nil
 }
 
-func GetSwitchInterface(ctx context.Context, device *cgnet.Device, interfaceID string) (InterfaceSwitchModel, error) {
+func GetSwitchInterface(ctx context.Context, device *session.Session, interfaceID string) (InterfaceSwitchModel, error) {
 	interfaces, err := GetSwitchInterfaces(ctx, device)
 	if err != nil {
 		return InterfaceSwitchModel{}, fmt.Errorf("failed to get switch interfaces: %w", err)
@@ -0,0 +1,223 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/CorentinPtrl/cisconf"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"terraform-provider-ios/internal/golden"
+)
+
+// switchInterface returns the interface id of the capture as seen by the
+// switch interface resource.
+func switchInterface(t *testing.T, capture string, id string) InterfaceSwitchModel {
+	t.Helper()
+	for _, iface := range captures(t)[capture].Interfaces {
+		if iface.Parent.Identifier == id {
+			model, err := InterfaceSwitchFromCisconf(context.Background(), &iface)
+			if err != nil {
+				t.Fatalf("InterfaceSwitchFromCisconf(%s) error = %s", id, err)
+			}
+			return model
+		}
+	}
+	t.Fatalf("no interface %s in %s", id, capture)
+	return InterfaceSwitchModel{}
+}
+
+func accessObject(vlan int32) types.Object {
+	return types.ObjectValueMust(Access{}.AttributeTypes(), Access{AccessVlan: types.Int32Value(vlan)}.AttributeValues())
+}
+
+func trunkObject(encapsulation string, vlans ...int32) types.Object {
+	allowed := types.ListNull(types.Int32Type)
+	if vlans != nil {
+		var values []attr.Value
+		for _, vlan := range vlans {
+			values = append(values, types.Int32Value(vlan))
+		}
+		allowed = types.ListValueMust(types.Int32Type, values)
+	}
+	trunk := Trunk{Encapsulation: types.StringValue(encapsulation), AllowedVlans: allowed}
+	return types.ObjectValueMust(trunk.AttributeTypes(), trunk.AttributeValues())
+}
+
+func spanningTreeObject(portfast string, bpduGuard types.Bool) types.Object {
+	st := SpanningTree{Portfast: types.StringValue(portfast), BpduGuard: bpduGuard}
+	return types.ObjectValueMust(st.AttributeTypes(), st.AttributeValues())
+}
+
+func TestInterfaceSwitchRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	for name, config := range captures(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, iface := range config.Interfaces {
+				model, err := InterfaceSwitchFromCisconf(ctx, &iface)
+				if err != nil {
+					t.Fatalf("InterfaceSwitchFromCisconf(%s) error = %s", iface.Parent.Identifier, err)
+				}
+				cisIface, err := InterfaceSwitchToCisconf(ctx, model)
+				if err != nil {
+					t.Fatalf("InterfaceSwitchToCisconf(%s) error = %s", describe(model), err)
+				}
+				pushed := reparse(t, cisIface)
+				if len(pushed.Interfaces) != 1 {
+					t.Fatalf("interface %s reads back as %d interfaces", iface.Parent.Identifier, len(pushed.Interfaces))
+				}
+				got, err := InterfaceSwitchFromCisconf(ctx, &pushed.Interfaces[0])
+				if err != nil {
+					t.Fatalf("InterfaceSwitchFromCisconf(%s) error = %s", iface.Parent.Identifier, err)
+				}
+				want := model
+				if model.Access.IsNull() && model.Trunk.IsNull() {
+					// Without an access or trunk block the interface is pushed
+					// as a routed port.
+					want.Switchport = types.StringNull()
+				}
+				if describe(got) != describe(want) {
+					t.Errorf("interface %s reads back as\n%s\nwant\n%s", iface.Parent.Identifier, describe(got), describe(want))
+				}
+			}
+		})
+	}
+}
+
+func TestInterfaceSwitchToCisconf(t *testing.T) {
+	ctx := context.Background()
+	base := InterfaceSwitchModel{
+		Switchport:   types.StringUnknown(),
+		Access:       types.ObjectNull(Access{}.AttributeTypes()),
+		Trunk:        types.ObjectNull(Trunk{}.AttributeTypes()),
+		SpanningTree: spanningTreeObject("", types.BoolNull()),
+		InterfaceModel: InterfaceModel{
+			ID:          types.StringValue("GigabitEthernet1/0/1"),
+			Description: types.StringValue(""),
+			Shutdown:    types.BoolValue(false),
+		},
+	}
+
+	tests := []struct {
+		name   string
+		modify func(m *InterfaceSwitchModel)
+	}{
+		{"routed", func(m *InterfaceSwitchModel) {}},
+		{"access", func(m *InterfaceSwitchModel) {
+			m.Access = accessObject(10)
+		}},
+		{"trunk", func(m *InterfaceSwitchModel) {
+			m.Trunk = trunkObject("dot1q", 10, 20)
+		}},
+		{"trunk all vlans", func(m *InterfaceSwitchModel) {
+			m.Trunk = trunkObject("dot1q")
+		}},
+		{"trunk over access", func(m *InterfaceSwitchModel) {
+			m.Access = accessObject(10)
+			m.Trunk = trunkObject("isl", 10)
+		}},
+		{"access with unknown trunk", func(m *InterfaceSwitchModel) {
+			m.Access = accessObject(10)
+			m.Trunk = types.ObjectUnknown(Trunk{}.AttributeTypes())
+		}},
+		{"trunk with unknown access", func(m *InterfaceSwitchModel) {
+			m.Access = types.ObjectUnknown(Access{}.AttributeTypes())
+			m.Trunk = trunkObject("dot1q", 30)
+		}},
+		{"unknown", func(m *InterfaceSwitchModel) {
+			m.Access = types.ObjectUnknown(Access{}.AttributeTypes())
+			m.Trunk = types.ObjectUnknown(Trunk{}.AttributeTypes())
+		}},
+		{"spanning tree", func(m *InterfaceSwitchModel) {
+			m.Access = accessObject(10)
+			m.SpanningTree = spanningTreeObject("edge", types.BoolValue(true))
+		}},
+		{"bpdu guard disabled", func(m *InterfaceSwitchModel) {
+			m.Access = accessObject(10)
+			m.SpanningTree = spanningTreeObject("disable", types.BoolValue(false))
+		}},
+		{"unknown spanning tree", func(m *InterfaceSwitchModel) {
+			m.Access = accessObject(10)
+			m.SpanningTree = types.ObjectUnknown(SpanningTree{}.AttributeTypes())
+		}},
+		{"description and shutdown", func(m *InterfaceSwitchModel) {
+			m.Access = accessObject(10)
+			m.Description = types.StringValue("desk 3.01")
+			m.Shutdown = types.BoolValue(true)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := base
+			tt.modify(&model)
+			cisIface, err := InterfaceSwitchToCisconf(ctx, model)
+			if err != nil {
+				t.Fatalf("InterfaceSwitchToCisconf() error = %s", err)
+			}
+			marshal, err := cisconf.Marshal(cisIface)
+			if err != nil {
+				t.Fatalf("failed to marshal %+v: %s", cisIface, err)
+			}
+			golden.Check(t, marshal)
+		})
+	}
+}
+
+func TestInterfaceSwitchDiff(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		current InterfaceSwitchModel
+		modify  func(m *InterfaceSwitchModel)
+	}{
+		{"unchanged", switchInterface(t, "ios15_c2960x", "GigabitEthernet1/0/1"), func(m *InterfaceSwitchModel) {}},
+		{"access vlan", switchInterface(t, "ios15_c2960x", "GigabitEthernet1/0/1"), func(m *InterfaceSwitchModel) {
+			m.Access = accessObject(30)
+		}},
+		{"access to trunk", switchInterface(t, "ios15_c2960x", "GigabitEthernet1/0/3"), func(m *InterfaceSwitchModel) {
+			m.Access = types.ObjectNull(Access{}.AttributeTypes())
+			m.Trunk = trunkObject("dot1q", 10, 30)
+		}},
+		{"trunk to access", switchInterface(t, "iosxe17_c9300", "GigabitEthernet1/0/2"), func(m *InterfaceSwitchModel) {
+			m.Trunk = types.ObjectNull(Trunk{}.AttributeTypes())
+			m.Access = accessObject(10)
+		}},
+		{"allowed vlans", switchInterface(t, "iosxe16_c3850", "TenGigabitEthernet1/0/3"), func(m *InterfaceSwitchModel) {
+			m.Trunk = trunkObject("", 100, 110, 120, 130)
+		}},
+		{"access to routed", switchInterface(t, "iosxe17_c9300", "GigabitEthernet1/0/3"), func(m *InterfaceSwitchModel) {
+			m.Access = types.ObjectNull(Access{}.AttributeTypes())
+		}},
+		{"description", switchInterface(t, "ios15_c2960x", "GigabitEthernet1/0/1"), func(m *InterfaceSwitchModel) {
+			m.Description = types.StringValue("desk 1.01 (moved)")
+		}},
+		{"shutdown", switchInterface(t, "ios15_c2960x", "GigabitEthernet1/0/1"), func(m *InterfaceSwitchModel) {
+			m.Shutdown = types.BoolValue(true)
+		}},
+		{"no shutdown", switchInterface(t, "iosxe17_c9300", "GigabitEthernet1/0/3"), func(m *InterfaceSwitchModel) {
+			m.Shutdown = types.BoolValue(false)
+		}},
+		{"portfast", switchInterface(t, "ios15_c2960x", "GigabitEthernet1/0/3"), func(m *InterfaceSwitchModel) {
+			m.SpanningTree = spanningTreeObject("edge", types.BoolValue(true))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planned := tt.current
+			tt.modify(&planned)
+			src, err := InterfaceSwitchToCisconf(ctx, tt.current)
+			if err != nil {
+				t.Fatalf("InterfaceSwitchToCisconf() error = %s", err)
+			}
+			dest, err := InterfaceSwitchToCisconf(ctx, planned)
+			if err != nil {
+				t.Fatalf("InterfaceSwitchToCisconf() error = %s", err)
+			}
+			golden.Check(t, diff(t, src, dest))
+		})
+	}
+}
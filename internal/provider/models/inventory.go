@@ -0,0 +1,35 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package models
+
+import (
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// InventoryDataSourceModel is the inventory data source, the hosts and groups
+// of the Ansible inventory of the provider by name.
+type InventoryDataSourceModel struct {
+	Hosts  map[string]InventoryHostModel  `tfsdk:"hosts"`
+	Groups map[string]InventoryGroupModel `tfsdk:"groups"`
+}
+
+// InventoryHostModel is a host of the inventory, with its variables once
+// merged with those of its groups.
+type InventoryHostModel struct {
+	Host      types.String            `tfsdk:"host"`
+	Port      types.Int32             `tfsdk:"port"`
+	Username  types.String            `tfsdk:"username"`
+	NetworkOS types.String            `tfsdk:"network_os"`
+	Groups    []types.String          `tfsdk:"groups"`
+	Vars      map[string]types.String `tfsdk:"vars"`
+	Managed   types.Bool              `tfsdk:"managed"`
+}
+
+// InventoryGroupModel is a group of the inventory, Hosts holding the hosts of
+// its children too.
+type InventoryGroupModel struct {
+	Hosts    []types.String          `tfsdk:"hosts"`
+	Children []types.String          `tfsdk:"children"`
+	Vars     map[string]types.String `tfsdk:"vars"`
+}
@@ -0,0 +1,140 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package models
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/CorentinPtrl/cisconf"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"terraform-provider-ios/internal/golden"
+	"terraform-provider-ios/internal/utils"
+)
+
+// captures returns the running-configs captured from devices in testdata,
+// keyed by the name of the file without its extension.
+func captures(t *testing.T) map[string]cisconf.Config {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join("testdata", "*.txt"))
+	if err != nil {
+		t.Fatalf("failed to list the captures: %s", err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no capture in testdata")
+	}
+	result := map[string]cisconf.Config{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("failed to read %s: %s", file, err)
+		}
+		config := cisconf.Config{}
+		err = cisconf.Unmarshal(string(data), &config)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %s", file, err)
+		}
+		result[strings.TrimSuffix(filepath.Base(file), ".txt")] = config
+	}
+	return result
+}
+
+// reparse marshals v the way the commands are pushed to the device and
+// parses them back as the device would show them in its running-config.
+func reparse(t *testing.T, v any) cisconf.Config {
+	t.Helper()
+	marshal, err := cisconf.Marshal(v)
+	if err != nil {
+		t.Fatalf("failed to marshal %+v: %s", v, err)
+	}
+	config := cisconf.Config{}
+	err = cisconf.Unmarshal(marshal, &config)
+	if err != nil {
+		t.Fatalf("failed to parse\n%s\n%s", marshal, err)
+	}
+	return config
+}
+
+// diff returns the commands the resources plan to turn src into dest.
+func diff(t *testing.T, src any, dest any) string {
+	t.Helper()
+	commands, err := utils.Diff(src, dest)
+	if err != nil {
+		t.Fatalf("failed to diff %+v and %+v: %s", src, dest, err)
+	}
+	return commands
+}
+
+// describe prints the attributes of a model on one line, by their tfsdk name,
+// the values as Terraform shows them.
+func describe(model any) string {
+	var fields []string
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.Anonymous {
+				walk(v.Field(i))
+				continue
+			}
+			if value, ok := v.Field(i).Interface().(attr.Value); ok {
+				fields = append(fields, fmt.Sprintf("%s=%s", field.Tag.Get("tfsdk"), value))
+			}
+		}
+	}
+	walk(reflect.ValueOf(model))
+	return strings.Join(fields, " ")
+}
+
+// TestFromCisconf converts everything the provider reads from the captured
+// running-configs.
+func TestFromCisconf(t *testing.T) {
+	ctx := context.Background()
+	for name, config := range captures(t) {
+		t.Run(name, func(t *testing.T) {
+			var out strings.Builder
+			fmt.Fprintln(&out, "# vlans")
+			for _, vlan := range config.Vlans {
+				fmt.Fprintln(&out, describe(VlanFromCisconf(ctx, vlan)))
+			}
+			fmt.Fprintln(&out, "# static routes")
+			for _, route := range config.Routes {
+				fmt.Fprintln(&out, describe(RouteFromCisconf(route)))
+			}
+			fmt.Fprintln(&out, "# eigrp")
+			for _, eigrp := range config.EIGRPProcess {
+				model, err := EigrpFromCisconf(ctx, eigrp)
+				if err != nil {
+					fmt.Fprintf(&out, "router eigrp %d: %s\n", eigrp.Asn, err)
+					continue
+				}
+				fmt.Fprintln(&out, describe(model))
+			}
+			fmt.Fprintln(&out, "# switch interfaces")
+			for _, iface := range config.Interfaces {
+				model, err := InterfaceSwitchFromCisconf(ctx, &iface)
+				if err != nil {
+					fmt.Fprintf(&out, "interface %s: %s\n", iface.Parent.Identifier, err)
+					continue
+				}
+				fmt.Fprintln(&out, describe(model))
+			}
+			fmt.Fprintln(&out, "# ethernet interfaces")
+			for _, iface := range config.Interfaces {
+				model, err := InterfaceEthernetFromCisconf(ctx, &iface)
+				if err != nil {
+					fmt.Fprintf(&out, "interface %s: %s\n", iface.Parent.Identifier, err)
+					continue
+				}
+				fmt.Fprintln(&out, describe(model))
+			}
+			golden.Check(t, out.String())
+		})
+	}
+}
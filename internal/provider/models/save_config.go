@@ -0,0 +1,15 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package models
+
+import (
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+type SaveConfigModel struct {
+	ID       types.String `tfsdk:"id"`
+	Triggers types.Map    `tfsdk:"triggers"`
+	Result   types.String `tfsdk:"result"`
+	Device   types.String `tfsdk:"device"`
+}
@@ -4,13 +4,13 @@
 package models
 
 import (
-	"fmt"
-	"github.com/CorentinPtrl/cgnet"
 	"github.com/CorentinPtrl/cisconf"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"terraform-provider-ios/internal/session"
 )
 
 type RoutesDataSourceModel struct {
+	Device types.String `tfsdk:"device"`
 	Routes []RouteModel `tfsdk:"routes"`
 }
 
@@ -20,6 +20,12 @@ type RouteModel struct {
 	NextHop types.String `tfsdk:"next_hop"`
 }
 
+type RouteResourceModel struct {
+	RouteModel
+	Device          types.String `tfsdk:"device"`
+	PlannedCommands types.List   `tfsdk:"planned_commands"`
+}
+
 func RouteToCisconf(route RouteModel) cisconf.Route {
 	return cisconf.Route{
 		Prefix:    route.Prefix.ValueString(),
@@ -36,15 +42,10 @@ func RouteFromCisconf(route cisconf.Route) RouteModel {
 	}
 }
 
-func GetRoutes(device *cgnet.Device) ([]RouteModel, error) {
-	config, err := device.Exec("sh running-config")
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute running config: %w", err)
-	}
-	runningConfig := cisconf.Config{}
-	err = cisconf.Unmarshal(config, &runningConfig)
+func GetRoutes(device *session.Session) ([]RouteModel, error) {
+	runningConfig, err := device.RunningConfig()
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal running config: %w", err)
+		return nil, err
 	}
 
 	result := []RouteModel{}
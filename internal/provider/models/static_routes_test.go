@@ -0,0 +1,53 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package models
+
+import (
+	"testing"
+
+	"github.com/CorentinPtrl/cisconf"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"terraform-provider-ios/internal/golden"
+)
+
+func TestRouteRoundTrip(t *testing.T) {
+	for name, config := range captures(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, route := range config.Routes {
+				if route.Prefix == "" {
+					// cisconf leaves the routes of a vrf unparsed.
+					continue
+				}
+				model := RouteFromCisconf(route)
+				pushed := reparse(t, cisconf.RoutesType{Routes: []cisconf.Route{RouteToCisconf(model)}})
+				if len(pushed.Routes) != 1 {
+					t.Fatalf("route %s %s reads back as %d routes", route.Prefix, route.Mask, len(pushed.Routes))
+				}
+				if got, want := describe(RouteFromCisconf(pushed.Routes[0])), describe(model); got != want {
+					t.Errorf("route %s %s reads back as\n%s\nwant\n%s", route.Prefix, route.Mask, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRouteDiff(t *testing.T) {
+	config := captures(t)["ios15_c1921"]
+	current := RouteFromCisconf(config.Routes[1])
+
+	tests := []struct {
+		name    string
+		planned RouteModel
+	}{
+		{"unchanged", current},
+		{"next hop", RouteModel{Prefix: current.Prefix, Mask: current.Mask, NextHop: types.StringValue("192.168.10.250")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := cisconf.RoutesType{Routes: []cisconf.Route{RouteToCisconf(current)}}
+			dest := cisconf.RoutesType{Routes: []cisconf.Route{RouteToCisconf(tt.planned)}}
+			golden.Check(t, diff(t, src, dest))
+		})
+	}
+}
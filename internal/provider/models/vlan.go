@@ -6,12 +6,12 @@ package models
 import (
 	"context"
 	"fmt"
-	"github.com/CorentinPtrl/cgnet"
 	"github.com/CorentinPtrl/cisconf"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/sirikothe/gotextfsm"
 	"strconv"
 	"terraform-provider-ios/internal/provider/ntc"
+	"terraform-provider-ios/internal/session"
 )
 
 type VlanModel struct {
@@ -19,8 +19,21 @@ type VlanModel struct {
 	Name types.String `tfsdk:"name"`
 }
 
+type VlanResourceModel struct {
+	VlanModel
+	Device          types.String `tfsdk:"device"`
+	PlannedCommands types.List   `tfsdk:"planned_commands"`
+}
+
+// VlanDataSourceModel is the vlan data source, the vlan read from the device.
+type VlanDataSourceModel struct {
+	VlanModel
+	Device types.String `tfsdk:"device"`
+}
+
 type VlansDataSourceModel struct {
-	Vlans []VlanModel `tfsdk:"vlans"`
+	Device types.String `tfsdk:"device"`
+	Vlans  []VlanModel  `tfsdk:"vlans"`
 }
 
 func VlanToCisconf(ctx context.Context, data VlanModel) cisconf.Vlan {
@@ -36,15 +49,10 @@ func VlanFromCisconf(ctx context.Context, vlan cisconf.Vlan) VlanModel {
 		Name: types.StringValue(vlan.Name),
 	}
 }
-func GetVlans(device *cgnet.Device) (map[int]VlanModel, error) {
-	config, err := device.Exec("sh running-config")
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute running config: %w", err)
-	}
-	runningConfig := cisconf.Config{}
-	err = cisconf.Unmarshal(config, &runningConfig)
+func GetVlans(ctx context.Context, device *session.Session) (map[int]VlanModel, error) {
+	runningConfig, err := device.RunningConfig()
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal running config: %w", err)
+		return nil, err
 	}
 
 	vlans := map[int]VlanModel{}
@@ -55,6 +63,33 @@ func GetVlans(device *cgnet.Device) (map[int]VlanModel, error) {
 		}
 	}
 
+	// The vlans missing from the running-config, like the default ones, are
+	// listed by show vlan, which a datastore cannot run and routers lack.
+	if device.Structured() {
+		return vlans, nil
+	}
+	facts, err := GetFacts(ctx, device)
+	if err != nil {
+		return nil, err
+	}
+	if facts.Platform.Router {
+		return vlans, nil
+	}
+
+	shown, err := device.Vlans(func() (any, error) {
+		return readVlans(device)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for id, vlan := range shown.(map[int]VlanModel) {
+		vlans[id] = vlan
+	}
+	return vlans, nil
+}
+
+// readVlans parses the vlans listed by show vlan.
+func readVlans(device *session.Session) (map[int]VlanModel, error) {
 	fsm, err := ntc.GetTextFSM("cisco_ios_show_vlan.textfsm")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get textfsm: %w", err)
@@ -68,6 +103,7 @@ func GetVlans(device *cgnet.Device) (map[int]VlanModel, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse result: %w", err)
 	}
+	vlans := map[int]VlanModel{}
 	for _, dic := range parser.Dict {
 		id, err := strconv.Atoi(dic["VLAN_ID"].(string))
 		if err != nil {
@@ -81,8 +117,8 @@ func GetVlans(device *cgnet.Device) (map[int]VlanModel, error) {
 	return vlans, nil
 }
 
-func GetVlan(device *cgnet.Device, id int) (*VlanModel, error) {
-	vlans, err := GetVlans(device)
+func GetVlan(ctx context.Context, device *session.Session, id int) (*VlanModel, error) {
+	vlans, err := GetVlans(ctx, device)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get VLANs: %w", err)
 	}
@@ -0,0 +1,50 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"terraform-provider-ios/internal/golden"
+)
+
+func TestVlanRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	for name, config := range captures(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, vlan := range config.Vlans {
+				model := VlanFromCisconf(ctx, vlan)
+				pushed := reparse(t, VlanToCisconf(ctx, model))
+				if len(pushed.Vlans) != 1 {
+					t.Fatalf("vlan %d reads back as %d vlans", vlan.Id, len(pushed.Vlans))
+				}
+				if got, want := describe(VlanFromCisconf(ctx, pushed.Vlans[0])), describe(model); got != want {
+					t.Errorf("vlan %d reads back as\n%s\nwant\n%s", vlan.Id, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestVlanDiff(t *testing.T) {
+	ctx := context.Background()
+	config := captures(t)["ios15_c2960x"]
+	current := VlanFromCisconf(ctx, config.Vlans[0])
+
+	tests := []struct {
+		name    string
+		planned VlanModel
+	}{
+		{"unchanged", current},
+		{"rename", VlanModel{Id: current.Id, Name: types.StringValue("staff")}},
+		{"unname", VlanModel{Id: current.Id, Name: types.StringValue("")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			golden.Check(t, diff(t, VlanToCisconf(ctx, current), VlanToCisconf(ctx, tt.planned)))
+		})
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	gossh "golang.org/x/crypto/ssh"
+	"terraform-provider-ios/internal/fakeios"
+	"terraform-provider-ios/internal/session"
+)
+
+// newNetconfStore serves a store of DefaultConfig over NETCONF, and returns
+// it with the provider block managing it, the host key of the server pinned.
+func newNetconfStore(t *testing.T) (*fakeios.Store, string) {
+	t.Helper()
+	store, err := fakeios.NewStore("")
+	if err != nil {
+		t.Fatalf("failed to create the store: %s", err)
+	}
+	server, err := fakeios.ServeNetconf(store, "admin", "cisco")
+	if err != nil {
+		t.Fatalf("failed to start the netconf server: %s", err)
+	}
+	t.Cleanup(func() {
+		server.Close()
+	})
+	t.Cleanup(session.Shutdown)
+	return store, providerBlock(fmt.Sprintf("host = %q\nport = %s\ntransport = \"netconf\"\nhost_key_fingerprint = %q\nsave_config = \"after_each_change\"\n",
+		server.Host(), server.Port(), gossh.FingerprintSHA256(server.HostKey())))
+}
+
+// unconfigured returns a check failing when the store still holds the vlans,
+// EIGRP processes or interface descriptions of a test.
+func unconfigured(t *testing.T, store *fakeios.Store) resource.TestCheckFunc {
+	return func(*terraform.State) error {
+		config := running(t, store)
+		if len(config.Vlans) != 0 || len(config.EIGRPProcess) != 0 {
+			return fmt.Errorf("the objects are still configured after destroy: %+v", config)
+		}
+		for _, iface := range config.Interfaces {
+			if iface.Description != "" {
+				return fmt.Errorf("%s is not reset to its defaults: %+v", iface.Parent.Identifier, iface)
+			}
+		}
+		return nil
+	}
+}
+
+func TestAccNetconf(t *testing.T) {
+	store, provider := newNetconfStore(t)
+	vlan := `
+resource "ios_vlan" "test" {
+  id   = 10
+  name = "staff"
+}
+`
+	access := `
+resource "ios_switch_interface" "test" {
+  id          = "GigabitEthernet0/1"
+  description = "office"
+  access      = { access_vlan = 10 }
+}
+`
+	trunk := `
+resource "ios_switch_interface" "test" {
+  id          = "GigabitEthernet0/1"
+  description = "office"
+  trunk       = { allowed_vlans = [10, 20] }
+}
+`
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: provider + strings.Replace(vlan, "staff", "users", 1),
+				// The changes are sent as YANG payloads, no command is
+				// planned.
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectKnownValue("ios_vlan.test", tfjsonpath.New("planned_commands"), knownvalue.Null()),
+					},
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_vlan.test", "name", "users"),
+					resource.TestCheckNoResourceAttr("ios_vlan.test", "planned_commands"),
+				),
+			},
+			{
+				Config: provider + vlan,
+				Check: func(*terraform.State) error {
+					if vlans := running(t, store).Vlans; len(vlans) != 1 || vlans[0].Name != "staff" {
+						return fmt.Errorf("vlans = %+v, want vlan 10 staff", vlans)
+					}
+					if !store.Saved() {
+						return fmt.Errorf("the change was not saved")
+					}
+					edits := store.Edits()
+					want := []string{"edit-config candidate", "validate", "commit", "edit-config candidate", "validate", "commit"}
+					if !reflect.DeepEqual(edits, want) {
+						return fmt.Errorf("edits = %q, want %q", edits, want)
+					}
+					return nil
+				},
+			},
+			{
+				Config: provider + vlan + access,
+				Check:  resource.TestCheckResourceAttr("ios_switch_interface.test", "switchport", "access"),
+			},
+			{
+				Config: provider + vlan + trunk,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_switch_interface.test", "switchport", "trunk"),
+					resource.TestCheckResourceAttr("ios_switch_interface.test", "trunk.allowed_vlans.1", "20"),
+				),
+			},
+			{
+				Config: provider + vlan + trunk + `
+resource "ios_eigrp" "test" {
+  as_number = 100
+  networks  = ["10.0.0.0/8", "192.168.0.0/24"]
+}
+`,
+				Check: resource.TestCheckResourceAttr("ios_eigrp.test", "networks.#", "2"),
+			},
+			{
+				Config: provider + vlan + trunk + `
+resource "ios_eigrp" "test" {
+  as_number = 100
+  networks  = ["10.0.0.0/8"]
+}
+`,
+				Check: resource.TestCheckResourceAttr("ios_eigrp.test", "networks.#", "1"),
+			},
+		},
+		CheckDestroy: unconfigured(t, store),
+	})
+}
+
+func TestAccNetconfRejected(t *testing.T) {
+	store, provider := newNetconfStore(t)
+	config := provider + `
+resource "ios_vlan" "test" {
+  id   = 10
+  name = "users"
+}
+`
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				PreConfig: func() {
+					store.Reject("VLAN 10 conflicts with the reserved range")
+				},
+				Config:      config,
+				ExpectError: regexp.MustCompile(`conflicts\s+with\s+the\s+reserved\s+range`),
+			},
+			{
+				PreConfig: func() {
+					if vlans := running(t, store).Vlans; len(vlans) != 0 {
+						t.Errorf("vlans = %+v, want the running configuration unchanged", vlans)
+					}
+					edits := store.Edits()
+					if len(edits) == 0 || edits[len(edits)-1] != "discard-changes" {
+						t.Errorf("edits = %q, want the candidate discarded", edits)
+					}
+					store.Reject("")
+				},
+				Config: config,
+				Check: func(*terraform.State) error {
+					if vlans := running(t, store).Vlans; len(vlans) != 1 {
+						return fmt.Errorf("vlans = %+v, want vlan 10 once the commit is accepted", vlans)
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
@@ -6,7 +6,6 @@ package provider
 import (
 	"context"
 	"fmt"
-	"github.com/CorentinPtrl/cgnet"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/path"
@@ -19,7 +18,8 @@ import (
 var _ datasource.DataSource = &NtcDataSource{}
 
 type NtcDataSourceModel struct {
-	Data []map[string]interface{} `tfsdk:"data"`
+	Device types.String             `tfsdk:"device"`
+	Data   []map[string]interface{} `tfsdk:"data"`
 }
 
 func NewNtcDataSource(name string, fsm gotextfsm.TextFSM) datasource.DataSource {
@@ -30,9 +30,9 @@ func NewNtcDataSource(name string, fsm gotextfsm.TextFSM) datasource.DataSource
 }
 
 type NtcDataSource struct {
-	client *cgnet.Device
-	name   string
-	fsm    gotextfsm.TextFSM
+	devices *Devices
+	name    string
+	fsm     gotextfsm.TextFSM
 }
 
 func (d *NtcDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
@@ -57,6 +57,7 @@ func (d *NtcDataSource) Schema(ctx context.Context, req datasource.SchemaRequest
 		MarkdownDescription: "Data source for " + strings.ReplaceAll(d.name, "_", " "),
 
 		Attributes: map[string]schema.Attribute{
+			"device": deviceDataSourceAttribute(),
 			"data": schema.ListNestedAttribute{
 				Computed:    true,
 				Description: "Data source for " + strings.ReplaceAll(d.name, "_", " "),
@@ -73,26 +74,45 @@ func (d *NtcDataSource) Configure(ctx context.Context, req datasource.ConfigureR
 		return
 	}
 
-	client, ok := req.ProviderData.(*cgnet.Device)
+	devices, ok := req.ProviderData.(*Devices)
 
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Data Source Configure Type",
-			fmt.Sprintf("Expected *http.Client, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *provider.Devices, got: %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 
 		return
 	}
 
-	d.client = client
+	d.devices = devices
 }
 
 func (d *NtcDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var device types.String
+
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("device"), &device)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, diags := d.devices.With(ctx, device, "ios_"+d.name, "")
+	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	result, err := d.client.Exec(strings.ReplaceAll(d.name, "_", " "))
+	if client.IsUnknown() {
+		resp.Diagnostics.AddError(
+			"Unknown Cisco IOS Provider Configuration",
+			"The data source cannot be read as the provider configuration depends on values not known until apply. "+
+				"Add a depends_on on the resources the provider configuration refers to so the read happens during apply.",
+		)
+		return
+	}
+
+	result, err := client.Exec(strings.ReplaceAll(d.name, "_", " "))
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to execute running "+strings.ReplaceAll(d.name, "_", " "),
@@ -0,0 +1,33 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"terraform-provider-ios/internal/fakeios"
+)
+
+func TestAccNtcDataSource(t *testing.T) {
+	d := newAccDevice(t, fakeios.DefaultConfig+"vlan 100\n name mgmt\n!\n")
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: d.provider() + `
+data "ios_show_vlan" "test" {}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ios_show_vlan.test", "data.#", "6"),
+					resource.TestCheckResourceAttr("data.ios_show_vlan.test", "data.0.vlanid", "1"),
+					resource.TestCheckResourceAttr("data.ios_show_vlan.test", "data.0.vlanname", "default"),
+					resource.TestCheckResourceAttr("data.ios_show_vlan.test", "data.1.vlanid", "100"),
+					resource.TestCheckResourceAttr("data.ios_show_vlan.test", "data.1.vlanname", "mgmt"),
+				),
+			},
+		},
+	})
+}
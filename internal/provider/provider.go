@@ -5,8 +5,10 @@ package provider
 
 import (
 	"context"
-	"github.com/CorentinPtrl/cgnet"
+	"crypto/x509"
+	"fmt"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
 	"github.com/hashicorp/terraform-plugin-framework/function"
 	"github.com/hashicorp/terraform-plugin-framework/path"
@@ -14,10 +16,17 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"maps"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"terraform-provider-ios/internal/inventory"
 	"terraform-provider-ios/internal/provider/ntc"
+	"terraform-provider-ios/internal/session"
+	"time"
 )
 
 // Ensure CiscoIosProvider satisfies various provider interfaces.
@@ -35,10 +44,58 @@ type CiscoIosProvider struct {
 
 // CiscoIosProviderModel describes the provider data model.
 type CiscoIosProviderModel struct {
-	Host     types.String `tfsdk:"host"`
-	Port     types.Int32  `tfsdk:"port"`
-	Username types.String `tfsdk:"username"`
-	Password types.String `tfsdk:"password"`
+	Host               types.String `tfsdk:"host"`
+	Port               types.Int32  `tfsdk:"port"`
+	Username           types.String `tfsdk:"username"`
+	Password           types.String `tfsdk:"password"`
+	EnablePassword     types.String `tfsdk:"enable_password"`
+	PrivateKey         types.String `tfsdk:"private_key"`
+	PrivateKeyFile     types.String `tfsdk:"private_key_file"`
+	Passphrase         types.String `tfsdk:"passphrase"`
+	KnownHostsFile     types.String `tfsdk:"known_hosts_file"`
+	HostKeyFingerprint types.String `tfsdk:"host_key_fingerprint"`
+	InsecureHostKey    types.Bool   `tfsdk:"insecure_ignore_host_key"`
+	MaxSessions        types.Int32  `tfsdk:"max_sessions"`
+	RetryMax           types.Int32  `tfsdk:"retry_max"`
+	RetryInterval      types.String `tfsdk:"retry_interval"`
+	UndoOnError        types.Bool   `tfsdk:"undo_on_error"`
+	RollbackOnError    types.Bool   `tfsdk:"rollback_on_error"`
+	CommitConfirm      types.Int32  `tfsdk:"commit_confirm_timeout"`
+	SaveConfig         types.String `tfsdk:"save_config"`
+	Transport          types.String `tfsdk:"transport"`
+	AuditLogPath       types.String `tfsdk:"audit_log_path"`
+	CACertFile         types.String `tfsdk:"ca_cert_file"`
+	InsecureSkipVerify types.Bool   `tfsdk:"insecure_skip_verify"`
+	Bastion            types.Object `tfsdk:"bastion"`
+	Devices            types.Map    `tfsdk:"devices"`
+	InventoryFile      types.String `tfsdk:"inventory_file"`
+}
+
+// CiscoIosDeviceModel describes a device of the devices map, the attributes
+// left out are those of the provider block.
+type CiscoIosDeviceModel struct {
+	Host               types.String `tfsdk:"host"`
+	Port               types.Int32  `tfsdk:"port"`
+	Username           types.String `tfsdk:"username"`
+	Password           types.String `tfsdk:"password"`
+	EnablePassword     types.String `tfsdk:"enable_password"`
+	PrivateKey         types.String `tfsdk:"private_key"`
+	PrivateKeyFile     types.String `tfsdk:"private_key_file"`
+	Passphrase         types.String `tfsdk:"passphrase"`
+	KnownHostsFile     types.String `tfsdk:"known_hosts_file"`
+	HostKeyFingerprint types.String `tfsdk:"host_key_fingerprint"`
+	Transport          types.String `tfsdk:"transport"`
+}
+
+// CiscoIosBastionModel describes the jump host the device is reached through.
+type CiscoIosBastionModel struct {
+	Host               types.String `tfsdk:"host"`
+	Port               types.Int32  `tfsdk:"port"`
+	User               types.String `tfsdk:"user"`
+	Password           types.String `tfsdk:"password"`
+	PrivateKey         types.String `tfsdk:"private_key"`
+	PrivateKeyFile     types.String `tfsdk:"private_key_file"`
+	HostKeyFingerprint types.String `tfsdk:"host_key_fingerprint"`
 }
 
 func (p *CiscoIosProvider) Metadata(ctx context.Context, req provider.MetadataRequest, resp *provider.MetadataResponse) {
@@ -50,10 +107,11 @@ func (p *CiscoIosProvider) Schema(ctx context.Context, req provider.SchemaReques
 	resp.Schema = schema.Schema{
 		Attributes: map[string]schema.Attribute{
 			"host": schema.StringAttribute{
-				Required: true,
+				Optional:    true,
+				Description: "Address of the device the resources without a device attribute manage. Optional when devices or inventory_file is set.",
 			},
 			"port": schema.Int32Attribute{
-				Required: true,
+				Optional: true,
 			},
 			"username": schema.StringAttribute{
 				Optional: true,
@@ -62,6 +120,170 @@ func (p *CiscoIosProvider) Schema(ctx context.Context, req provider.SchemaReques
 				Optional:  true,
 				Sensitive: true,
 			},
+			"enable_password": schema.StringAttribute{
+				Optional:    true,
+				Sensitive:   true,
+				Description: "Enable secret used to escalate to privilege level 15 when the login lands at a lower level.",
+			},
+			"private_key": schema.StringAttribute{
+				Optional:    true,
+				Sensitive:   true,
+				Description: "PEM encoded private key used for SSH public key authentication. Conflicts with private_key_file.",
+			},
+			"private_key_file": schema.StringAttribute{
+				Optional:    true,
+				Description: "Path to the private key used for SSH public key authentication. Conflicts with private_key.",
+			},
+			"passphrase": schema.StringAttribute{
+				Optional:    true,
+				Sensitive:   true,
+				Description: "Passphrase of the encrypted private key.",
+			},
+			"known_hosts_file": schema.StringAttribute{
+				Optional:    true,
+				Description: "Path to an OpenSSH known_hosts file the host key of the device is verified against.",
+			},
+			"host_key_fingerprint": schema.StringAttribute{
+				Optional:    true,
+				Description: "Expected fingerprint of the host key of the device, either 'SHA256:<base64>' or a legacy MD5 colon separated hex string.",
+			},
+			"insecure_ignore_host_key": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Accept the host key presented by the devices and the bastion reached over ssh or netconf without a known_hosts_file or host_key_fingerprint, leaving the connection open to man-in-the-middle attacks. Without it such a device is refused. Defaults to false.",
+			},
+			"max_sessions": schema.Int32Attribute{
+				Optional:    true,
+				Description: "Maximum number of sessions opened to the device. One session is reserved for configuration changes and the others run read-only commands in parallel. Defaults to 1, where reads and writes share a single session.",
+			},
+			"retry_max": schema.Int32Attribute{
+				Optional:    true,
+				Description: "Number of times a command is retried on a new session when the device drops the connection. Configuration changes are only replayed after the running-config is read and diffed again. Defaults to 3, 0 disables retries.",
+			},
+			"retry_interval": schema.StringAttribute{
+				Optional:    true,
+				Description: "Wait before the first retry, as a duration like '2s'. The wait doubles on each following attempt. Defaults to '1s'.",
+			},
+			"undo_on_error": schema.BoolAttribute{
+				Optional:    true,
+				Description: "When the device rejects a line of a change, revert the lines of the change already applied, comparing the running-config read before the change with the one left by the rejected line. Lines the change added are negated, lines it removed or replaced are configured again, and sections it created are removed. Only the lines right under a section are reverted, not those of its sub-modes like address families. Defaults to false.",
+			},
+			"commit_confirm_timeout": schema.Int32Attribute{
+				Optional:    true,
+				Description: "Minutes after which the device restores its previous configuration unless the provider confirms the change. A configure replace timer is armed before each change and only cancelled once a new session can be opened afterwards, so a change locking the provider out reverts itself. Requires the archive feature to be configured on the device. Defaults to 0, disabled.",
+			},
+			"rollback_on_error": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Save the running-config to flash before each change, and restore it with configure replace when a line is rejected or the change does not read back as planned. Requires a device supporting configure replace, with room on flash: for the snapshot. Defaults to false.",
+			},
+			"save_config": schema.StringAttribute{
+				Optional:    true,
+				Description: "When the running-config is copied to the startup-config, either 'never', 'after_each_change' or 'end_of_apply'. With 'end_of_apply' the configuration is saved once by the last of the changes Terraform applies together to a device, changes applied one after the other as they depend on each other are each saved. Defaults to 'never'.",
+			},
+			"audit_log_path": schema.StringAttribute{
+				Optional:    true,
+				Description: "Path of a file the commands sent to the device and their responses are appended to, one JSON object per line with the time, the host, the resource type and id, the command and the response. Terraform does not pass resource addresses to providers, so a resource is identified by its type and the id of the object it manages, such as ios_vlan and 10, rather than by its address in the configuration. Passwords, secrets and SNMP communities are redacted. Disabled by default.",
+			},
+			"transport": schema.StringAttribute{
+				Optional:    true,
+				Description: "Protocol used to reach the device, either 'ssh', 'telnet', 'restconf' or 'netconf'. Defaults to 'ssh'. Telnet sends the credentials in clear text and should only be used for legacy devices. RESTCONF edits the Cisco-IOS-XE-native YANG model of IOS-XE devices over HTTPS instead of sending CLI commands, port is then the HTTPS port of the device, usually 443; the changes of each resource are sent as a single YANG-Patch when the device accepts it, otherwise one request after the other, and a failed request leaves the requests before it applied. NETCONF edits the same model over SSH, port is then the NETCONF port of the device, usually 830; the changes of each resource are committed at once through the candidate datastore when the device has one. Each resource is its own commit: an apply failing on one resource leaves the resources committed before it in place.",
+			},
+			"ca_cert_file": schema.StringAttribute{
+				Optional:    true,
+				Description: "Path to the PEM bundle of the certificate authorities the certificate of the device is verified against with the restconf transport. Defaults to the certificate authorities of the system.",
+			},
+			"insecure_skip_verify": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Accept any certificate presented by the device with the restconf transport, such as the self-signed certificate IOS-XE generates. Defaults to false.",
+			},
+			"inventory_file": schema.StringAttribute{
+				Optional:    true,
+				Description: "Path to an Ansible inventory, in its YAML format when the file ends with .yml, .yaml or .json and in its INI format otherwise. Each host is a device the resources select by its inventory name with their device attribute, reached with its ansible_host, ansible_port, ansible_user, ansible_password, ansible_become_password, ansible_ssh_private_key_file and ansible_connection variables, the settings of the provider block filling the others. Hosts whose ansible_network_os is neither ios nor cisco.ios.ios are refused. A device of the devices map replaces the host of the same name.",
+			},
+			"devices": schema.MapNestedAttribute{
+				Optional:    true,
+				Description: "Devices managed by the provider besides the device of the provider block and the hosts of the inventory, by name. A resource or data source selects one of them with its device attribute. The attributes left out of a device are those of the provider block, and the session to a device is only opened once a resource selects it.",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"host": schema.StringAttribute{
+							Required:    true,
+							Description: "Address of the device.",
+						},
+						"port": schema.Int32Attribute{
+							Optional: true,
+						},
+						"username": schema.StringAttribute{
+							Optional: true,
+						},
+						"password": schema.StringAttribute{
+							Optional:  true,
+							Sensitive: true,
+						},
+						"enable_password": schema.StringAttribute{
+							Optional:  true,
+							Sensitive: true,
+						},
+						"private_key": schema.StringAttribute{
+							Optional:    true,
+							Sensitive:   true,
+							Description: "PEM encoded private key of the device, replacing the key of the provider block. Conflicts with private_key_file.",
+						},
+						"private_key_file": schema.StringAttribute{
+							Optional:    true,
+							Description: "Path to the private key of the device, replacing the key of the provider block. Conflicts with private_key.",
+						},
+						"passphrase": schema.StringAttribute{
+							Optional:  true,
+							Sensitive: true,
+						},
+						"known_hosts_file": schema.StringAttribute{
+							Optional: true,
+						},
+						"host_key_fingerprint": schema.StringAttribute{
+							Optional: true,
+						},
+						"transport": schema.StringAttribute{
+							Optional: true,
+						},
+					},
+				},
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"bastion": schema.SingleNestedBlock{
+				Description: "SSH bastion the device is reached through. The connection to the device is tunnelled through the bastion with direct-tcpip forwarding.",
+				Attributes: map[string]schema.Attribute{
+					"host": schema.StringAttribute{
+						Optional:    true,
+						Description: "Address of the bastion. Required when the block is set.",
+					},
+					"port": schema.Int32Attribute{
+						Optional:    true,
+						Description: "SSH port of the bastion. Defaults to 22.",
+					},
+					"user": schema.StringAttribute{
+						Optional:    true,
+						Description: "User to log in to the bastion with. Defaults to the username of the device.",
+					},
+					"password": schema.StringAttribute{
+						Optional:    true,
+						Sensitive:   true,
+						Description: "Password of the bastion user.",
+					},
+					"private_key": schema.StringAttribute{
+						Optional:    true,
+						Sensitive:   true,
+						Description: "PEM encoded private key of the bastion user. Conflicts with private_key_file.",
+					},
+					"private_key_file": schema.StringAttribute{
+						Optional:    true,
+						Description: "Path to the private key of the bastion user. Conflicts with private_key.",
+					},
+					"host_key_fingerprint": schema.StringAttribute{
+						Optional:    true,
+						Description: "Expected fingerprint of the host key of the bastion, either 'SHA256:<base64>' or a legacy MD5 colon separated hex string.",
+					},
+				},
+			},
 		},
 	}
 }
@@ -74,43 +296,44 @@ func (p *CiscoIosProvider) Configure(ctx context.Context, req provider.Configure
 		return
 	}
 
-	if config.Host.IsUnknown() {
-		resp.Diagnostics.AddAttributeError(
-			path.Root("host"),
-			"Unknown Cisco IOS Host",
-			"The provider cannot create the Cisco IOS ssh client as there is an unknown configuration value for the Cisco IOS host. "+
-				"Either target apply the source of the value first, set the value statically in the configuration, or use the IOS_HOST environment variable.",
-		)
-	}
-
-	if config.Port.IsUnknown() {
-		resp.Diagnostics.AddAttributeError(
-			path.Root("port"),
-			"Unknown Cisco IOS Port",
-			"The provider cannot create the Cisco IOS ssh client as there is an unknown configuration value for the Cisco IOS port. "+
-				"Either target apply the source of the value first, set the value statically in the configuration, or use the IOS_PORT environment variable.",
-		)
-	}
-
-	if config.Username.IsUnknown() {
-		resp.Diagnostics.AddAttributeError(
-			path.Root("username"),
-			"Unknown Cisco IOS Username",
-			"The provider cannot create the Cisco IOS client as there is an unknown configuration value for the Cisco IOS username. "+
-				"Either target apply the source of the value first, set the value statically in the configuration, or use the IOS_USERNAME environment variable.",
-		)
-	}
-
-	if config.Password.IsUnknown() {
-		resp.Diagnostics.AddAttributeError(
-			path.Root("password"),
-			"Unknown Cisco IOS Password",
-			"The provider cannot create the Cisco IOS client as there is an unknown configuration value for the Cisco IOS password. "+
-				"Either target apply the source of the value first, set the value statically in the configuration, or use the IOS_PASSWORD environment variable.",
-		)
-	}
-
-	if resp.Diagnostics.HasError() {
+	// The configuration may depend on resources not applied yet, like the
+	// router being configured. Nothing is connected until a resource needs the
+	// device, so the provider only has to wait for the values to be known.
+	if config.Host.IsUnknown() ||
+		config.Port.IsUnknown() ||
+		config.Username.IsUnknown() ||
+		config.Password.IsUnknown() ||
+		config.EnablePassword.IsUnknown() ||
+		config.PrivateKey.IsUnknown() ||
+		config.PrivateKeyFile.IsUnknown() ||
+		config.Passphrase.IsUnknown() ||
+		config.KnownHostsFile.IsUnknown() ||
+		config.HostKeyFingerprint.IsUnknown() ||
+		config.InsecureHostKey.IsUnknown() ||
+		config.MaxSessions.IsUnknown() ||
+		config.RetryMax.IsUnknown() ||
+		config.RetryInterval.IsUnknown() ||
+		config.UndoOnError.IsUnknown() ||
+		config.RollbackOnError.IsUnknown() ||
+		config.CommitConfirm.IsUnknown() ||
+		config.SaveConfig.IsUnknown() ||
+		config.Transport.IsUnknown() ||
+		config.AuditLogPath.IsUnknown() ||
+		config.InventoryFile.IsUnknown() ||
+		config.CACertFile.IsUnknown() ||
+		config.InsecureSkipVerify.IsUnknown() ||
+		isUnknown(config.Bastion) ||
+		isUnknownMap(config.Devices) {
+		if req.ClientCapabilities.DeferralAllowed {
+			resp.Deferred = &provider.Deferred{
+				Reason: provider.DeferredReasonProviderConfigUnknown,
+			}
+			return
+		}
+		tflog.Debug(ctx, "Cisco IOS provider configuration contains unknown values, deferring the connection")
+		devices := unknownDevices()
+		resp.DataSourceData = devices
+		resp.ResourceData = devices
 		return
 	}
 
@@ -118,6 +341,25 @@ func (p *CiscoIosProvider) Configure(ctx context.Context, req provider.Configure
 	username := os.Getenv("IOS_USERNAME")
 	password := os.Getenv("IOS_PASSWORD")
 	port := os.Getenv("IOS_PORT")
+	enablePassword := os.Getenv("IOS_ENABLE_PASSWORD")
+	privateKey := os.Getenv("IOS_PRIVATE_KEY")
+	privateKeyFile := os.Getenv("IOS_PRIVATE_KEY_FILE")
+	passphrase := os.Getenv("IOS_PASSPHRASE")
+	knownHostsFile := os.Getenv("IOS_KNOWN_HOSTS_FILE")
+	hostKeyFingerprint := os.Getenv("IOS_HOST_KEY_FINGERPRINT")
+	insecureHostKey := os.Getenv("IOS_INSECURE_IGNORE_HOST_KEY")
+	maxSessions := os.Getenv("IOS_MAX_SESSIONS")
+	retryMax := os.Getenv("IOS_RETRY_MAX")
+	retryInterval := os.Getenv("IOS_RETRY_INTERVAL")
+	undoOnError := os.Getenv("IOS_UNDO_ON_ERROR")
+	rollbackOnError := os.Getenv("IOS_ROLLBACK_ON_ERROR")
+	commitConfirm := os.Getenv("IOS_COMMIT_CONFIRM_TIMEOUT")
+	saveConfig := os.Getenv("IOS_SAVE_CONFIG")
+	transport := os.Getenv("IOS_TRANSPORT")
+	auditLogPath := os.Getenv("IOS_AUDIT_LOG_PATH")
+	inventoryFile := os.Getenv("IOS_INVENTORY_FILE")
+	caCertFile := os.Getenv("IOS_CA_CERT_FILE")
+	insecureSkipVerify := os.Getenv("IOS_INSECURE_SKIP_VERIFY")
 
 	if !config.Host.IsNull() {
 		host = config.Host.ValueString()
@@ -135,19 +377,488 @@ func (p *CiscoIosProvider) Configure(ctx context.Context, req provider.Configure
 		port = strconv.Itoa(int(config.Port.ValueInt32()))
 	}
 
-	if host == "" {
+	if !config.EnablePassword.IsNull() {
+		enablePassword = config.EnablePassword.ValueString()
+	}
+
+	if !config.PrivateKey.IsNull() {
+		privateKey = config.PrivateKey.ValueString()
+	}
+
+	if !config.PrivateKeyFile.IsNull() {
+		privateKeyFile = config.PrivateKeyFile.ValueString()
+	}
+
+	if !config.Passphrase.IsNull() {
+		passphrase = config.Passphrase.ValueString()
+	}
+
+	if !config.KnownHostsFile.IsNull() {
+		knownHostsFile = config.KnownHostsFile.ValueString()
+	}
+
+	if !config.HostKeyFingerprint.IsNull() {
+		hostKeyFingerprint = config.HostKeyFingerprint.ValueString()
+	}
+
+	if !config.InsecureHostKey.IsNull() {
+		insecureHostKey = strconv.FormatBool(config.InsecureHostKey.ValueBool())
+	}
+
+	if !config.MaxSessions.IsNull() {
+		maxSessions = strconv.Itoa(int(config.MaxSessions.ValueInt32()))
+	}
+
+	if !config.RetryMax.IsNull() {
+		retryMax = strconv.Itoa(int(config.RetryMax.ValueInt32()))
+	}
+
+	if !config.RetryInterval.IsNull() {
+		retryInterval = config.RetryInterval.ValueString()
+	}
+
+	if !config.UndoOnError.IsNull() {
+		undoOnError = strconv.FormatBool(config.UndoOnError.ValueBool())
+	}
+
+	if !config.RollbackOnError.IsNull() {
+		rollbackOnError = strconv.FormatBool(config.RollbackOnError.ValueBool())
+	}
+
+	if !config.CommitConfirm.IsNull() {
+		commitConfirm = strconv.Itoa(int(config.CommitConfirm.ValueInt32()))
+	}
+
+	if !config.SaveConfig.IsNull() {
+		saveConfig = config.SaveConfig.ValueString()
+	}
+
+	if !config.Transport.IsNull() {
+		transport = config.Transport.ValueString()
+	}
+
+	if !config.AuditLogPath.IsNull() {
+		auditLogPath = config.AuditLogPath.ValueString()
+	}
+
+	if !config.InventoryFile.IsNull() {
+		inventoryFile = config.InventoryFile.ValueString()
+	}
+
+	if !config.CACertFile.IsNull() {
+		caCertFile = config.CACertFile.ValueString()
+	}
+
+	if !config.InsecureSkipVerify.IsNull() {
+		insecureSkipVerify = strconv.FormatBool(config.InsecureSkipVerify.ValueBool())
+	}
+
+	if transport == "" {
+		transport = "ssh"
+	}
+
+	if insecureSkipVerify == "" {
+		insecureSkipVerify = "false"
+	}
+
+	if insecureHostKey == "" {
+		insecureHostKey = "false"
+	}
+
+	if maxSessions == "" {
+		maxSessions = "1"
+	}
+
+	if retryMax == "" {
+		retryMax = "3"
+	}
+
+	if retryInterval == "" {
+		retryInterval = "1s"
+	}
+
+	if undoOnError == "" {
+		undoOnError = "false"
+	}
+
+	if rollbackOnError == "" {
+		rollbackOnError = "false"
+	}
+
+	if commitConfirm == "" {
+		commitConfirm = "0"
+	}
+
+	if saveConfig == "" {
+		saveConfig = string(session.SaveNever)
+	}
+
+	var deviceModels map[string]CiscoIosDeviceModel
+	if !config.Devices.IsNull() {
+		resp.Diagnostics.Append(config.Devices.ElementsAs(ctx, &deviceModels, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	var hosts *inventory.Inventory
+	if inventoryFile != "" {
+		var err error
+		hosts, err = inventory.Load(inventoryFile)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("inventory_file"),
+				"Unreadable Cisco IOS Inventory",
+				"The provider cannot create the Cisco IOS client as the Ansible inventory cannot be read. "+
+					"Set the inventory_file value in the configuration or use the IOS_INVENTORY_FILE environment variable.\n\n"+
+					"Error: "+err.Error(),
+			)
+			return
+		}
+	}
+
+	ignoreHostKey, err := strconv.ParseBool(insecureHostKey)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("insecure_ignore_host_key"),
+			"Invalid Cisco IOS Insecure Ignore Host Key",
+			"The provider cannot create the Cisco IOS client as the Cisco IOS insecure ignore host key must be a boolean. "+
+				"Set the insecure_ignore_host_key value in the configuration or use the IOS_INSECURE_IGNORE_HOST_KEY environment variable.",
+		)
+	}
+
+	device := target{
+		host:               host,
+		port:               port,
+		username:           username,
+		password:           password,
+		enablePassword:     enablePassword,
+		privateKey:         privateKey,
+		privateKeyFile:     privateKeyFile,
+		passphrase:         passphrase,
+		knownHostsFile:     knownHostsFile,
+		hostKeyFingerprint: hostKeyFingerprint,
+		ignoreHostKey:      ignoreHostKey,
+		transport:          transport,
+	}
+
+	// The device of the provider block is optional once the devices map is
+	// set, the resources then select their device.
+	if host == "" && len(deviceModels) == 0 && hosts == nil {
 		resp.Diagnostics.AddAttributeError(
 			path.Root("host"),
 			"Missing Cisco IOS Host",
 			"The provider cannot create the Cisco IOS client as there is a missing or empty value for the Cisco IOS host. "+
-				"Set the host value in the configuration or use the IOS_HOST environment variable. "+
+				"Set the host value in the configuration or use the IOS_HOST environment variable, or configure the devices map or an inventory_file. "+
 				"If either is already set, ensure the value is not empty.",
 		)
 	}
 
-	if username == "" {
+	if host != "" {
+		checkTarget(&device, path.Root, !config.Bastion.IsNull(), undoOnError, rollbackOnError, commitConfirm, &resp.Diagnostics)
+	}
+
+	// The hosts of the inventory the provider cannot manage are only
+	// reported once a resource selects them, the inventory may list other
+	// kinds of devices and servers.
+	targets := map[string]target{}
+	rejected := map[string]diag.Diagnostics{}
+	var vaulted []string
+	if hosts != nil {
+		for name, inventoryHost := range hosts.Hosts {
+			if _, ok := deviceModels[name]; ok {
+				continue
+			}
+			t, secrets, err := device.fromHost(inventoryHost)
+			if err != nil {
+				rejected[name] = diag.Diagnostics{diag.NewAttributeErrorDiagnostic(
+					path.Root("device"),
+					"Unsupported Cisco IOS Inventory Host",
+					fmt.Sprintf("The host %q of the inventory cannot be managed by the provider as %s.", name, err),
+				)}
+				continue
+			}
+			var hostDiags diag.Diagnostics
+			checkTarget(&t, func(string) path.Path { return path.Root("device") }, !config.Bastion.IsNull(), undoOnError, rollbackOnError, commitConfirm, &hostDiags)
+			if hostDiags.HasError() {
+				var hostErrors diag.Diagnostics
+				for _, d := range hostDiags.Errors() {
+					hostErrors.AddAttributeError(path.Root("device"), d.Summary(), fmt.Sprintf("The host %q of the inventory cannot be managed by the provider. %s", name, d.Detail()))
+				}
+				rejected[name] = hostErrors
+				continue
+			}
+			targets[name] = t
+			for _, secret := range secrets {
+				vaulted = append(vaulted, name+" "+secret)
+			}
+		}
+	}
+	if len(vaulted) > 0 {
+		slices.Sort(vaulted)
+		resp.Diagnostics.AddWarning(
+			"Cisco IOS Inventory Secrets Not Decrypted",
+			"The variables encrypted with Ansible Vault cannot be read by the provider, the settings of the provider block are used instead for: "+strings.Join(vaulted, ", ")+".",
+		)
+	}
+
+	for name, model := range deviceModels {
+		t := device.merge(model)
+		checkTarget(&t, path.Root("devices").AtMapKey(name).AtName, !config.Bastion.IsNull(), undoOnError, rollbackOnError, commitConfirm, &resp.Diagnostics)
+		targets[name] = t
+	}
+
+	insecure, err := strconv.ParseBool(insecureSkipVerify)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("insecure_skip_verify"),
+			"Invalid Cisco IOS Insecure Skip Verify",
+			"The provider cannot create the Cisco IOS client as the Cisco IOS insecure skip verify must be a boolean. "+
+				"Set the insecure_skip_verify value in the configuration or use the IOS_INSECURE_SKIP_VERIFY environment variable.",
+		)
+	}
+
+	var caCert []byte
+	if caCertFile != "" {
+		caCert, err = os.ReadFile(caCertFile)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("ca_cert_file"),
+				"Unreadable Cisco IOS CA Certificate File",
+				"The provider cannot create the Cisco IOS client as the CA certificate file cannot be read.\n\n"+
+					"Error: "+err.Error(),
+			)
+		} else if !x509.NewCertPool().AppendCertsFromPEM(caCert) {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("ca_cert_file"),
+				"Invalid Cisco IOS CA Certificate File",
+				"The provider cannot create the Cisco IOS client as the CA certificate file "+caCertFile+" holds no PEM certificate.",
+			)
+		}
+	}
+
+	sessions, err := strconv.Atoi(maxSessions)
+	if err != nil || sessions < 1 {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("max_sessions"),
+			"Invalid Cisco IOS Max Sessions",
+			"The provider cannot create the Cisco IOS client as the Cisco IOS max sessions must be a number greater than or equal to 1. "+
+				"Set the max_sessions value in the configuration or use the IOS_MAX_SESSIONS environment variable.",
+		)
+	}
+
+	retries, err := strconv.Atoi(retryMax)
+	if err != nil || retries < 0 {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("retry_max"),
+			"Invalid Cisco IOS Retry Max",
+			"The provider cannot create the Cisco IOS client as the Cisco IOS retry max must be a number greater than or equal to 0. "+
+				"Set the retry_max value in the configuration or use the IOS_RETRY_MAX environment variable.",
+		)
+	}
+
+	interval, err := time.ParseDuration(retryInterval)
+	if err != nil || interval < 0 {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("retry_interval"),
+			"Invalid Cisco IOS Retry Interval",
+			"The provider cannot create the Cisco IOS client as the Cisco IOS retry interval must be a positive duration like '2s'. "+
+				"Set the retry_interval value in the configuration or use the IOS_RETRY_INTERVAL environment variable.",
+		)
+	}
+
+	undo, err := strconv.ParseBool(undoOnError)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("undo_on_error"),
+			"Invalid Cisco IOS Undo On Error",
+			"The provider cannot create the Cisco IOS client as the Cisco IOS undo on error must be a boolean. "+
+				"Set the undo_on_error value in the configuration or use the IOS_UNDO_ON_ERROR environment variable.",
+		)
+	}
+
+	rollback, err := strconv.ParseBool(rollbackOnError)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("rollback_on_error"),
+			"Invalid Cisco IOS Rollback On Error",
+			"The provider cannot create the Cisco IOS client as the Cisco IOS rollback on error must be a boolean. "+
+				"Set the rollback_on_error value in the configuration or use the IOS_ROLLBACK_ON_ERROR environment variable.",
+		)
+	}
+
+	commitTimeout, err := strconv.Atoi(commitConfirm)
+	if err != nil || commitTimeout < 0 || commitTimeout > 120 {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("commit_confirm_timeout"),
+			"Invalid Cisco IOS Commit Confirm Timeout",
+			"The provider cannot create the Cisco IOS client as the Cisco IOS commit confirm timeout must be a number of minutes between 0 and 120. "+
+				"Set the commit_confirm_timeout value in the configuration or use the IOS_COMMIT_CONFIRM_TIMEOUT environment variable.",
+		)
+	}
+
+	switch session.SaveMode(saveConfig) {
+	case session.SaveNever, session.SaveAfterEachChange, session.SaveEndOfApply:
+	default:
 		resp.Diagnostics.AddAttributeError(
-			path.Root("username"),
+			path.Root("save_config"),
+			"Invalid Cisco IOS Save Config",
+			"The provider cannot create the Cisco IOS client as the Cisco IOS save config mode must be 'never', 'after_each_change' or 'end_of_apply'. "+
+				"Set the save_config value in the configuration or use the IOS_SAVE_CONFIG environment variable.",
+		)
+	}
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var bastion *session.SSHConfig
+	if !config.Bastion.IsNull() {
+		bastion = bastionConfig(ctx, config.Bastion, username, ignoreHostKey, &resp.Diagnostics)
+	}
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	all := slices.Collect(maps.Values(targets))
+	if host != "" {
+		all = append(all, device)
+	}
+	// The warnings are the same for every device, the diagnostics only hold
+	// each of them once.
+	for _, t := range all {
+		warnTarget(t, insecure, &resp.Diagnostics)
+	}
+
+	var auditLog *session.AuditLog
+	if auditLogPath != "" {
+		auditLog, err = session.OpenAuditLog(auditLogPath)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("audit_log_path"),
+				"Unable to Open Cisco IOS Audit Log",
+				fmt.Sprintf("The provider cannot create the Cisco IOS client as the audit log cannot be opened: %s. "+
+					"Set the audit_log_path value in the configuration or use the IOS_AUDIT_LOG_PATH environment variable.", err),
+			)
+			return
+		}
+	}
+
+	conn := connector{
+		options: session.Options{
+			MaxSessions:          sessions,
+			RetryMax:             retries,
+			RetryInterval:        interval,
+			UndoOnError:          undo,
+			RollbackOnError:      rollback,
+			CommitConfirmTimeout: commitTimeout,
+			SaveConfig:           session.SaveMode(saveConfig),
+			AuditLog:             auditLog,
+		},
+		bastion:  bastion,
+		caCert:   caCert,
+		insecure: insecure,
+	}
+	devices := &Devices{targets: targets, connector: conn, inventory: hosts, rejected: rejected}
+	if host == "" {
+		if os.Getenv("IOS_CASSETTE") != "" {
+			resp.Diagnostics.AddWarning(
+				"Cisco IOS Cassette Ignored",
+				"IOS_CASSETTE is set but cassettes only record the session of the device of the provider block, which has no host, the devices of the devices map are reached.",
+			)
+		}
+		resp.DataSourceData = devices
+		resp.ResourceData = devices
+		return
+	}
+
+	dial := conn.dialer(device)
+	// A cassette records the session for a bug report or a regression test,
+	// or replays it without the device.
+	if cassette := os.Getenv("IOS_CASSETTE"); cassette != "" && (transport == "restconf" || transport == "netconf") {
+		resp.Diagnostics.AddWarning(
+			"Cisco IOS Cassette Ignored",
+			"IOS_CASSETTE is set but cassettes only record the CLI sessions of the ssh and telnet transports, the "+transport+" transport reaches the device.",
+		)
+	} else if cassette != "" {
+		dial, err = session.WithCassette(cassette, session.CassetteMode(os.Getenv("IOS_CASSETTE_MODE")), dial)
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Unable to Open Cisco IOS Cassette",
+				fmt.Sprintf("The provider cannot create the Cisco IOS client as the cassette set by IOS_CASSETTE cannot be used: %s. "+
+					"Set IOS_CASSETTE_MODE to record to create it.", err),
+			)
+			if auditLog != nil {
+				auditLog.Close()
+			}
+			return
+		}
+		if len(targets) > 0 {
+			resp.Diagnostics.AddWarning(
+				"Cisco IOS Cassette Ignored for Devices",
+				"IOS_CASSETTE only records the session of the device of the provider block, the devices of the devices map are reached.",
+			)
+		}
+	}
+	devices.fallback, err = conn.open(device, dial)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unable to Create Cisco IOS Client",
+			fmt.Sprintf("The session to the device cannot be created: %s.", err),
+		)
+		if auditLog != nil {
+			auditLog.Close()
+		}
+		return
+	}
+	resp.DataSourceData = devices
+	resp.ResourceData = devices
+}
+
+// merge returns the settings of a device of the devices map, the attributes
+// it leaves out being those of the provider block.
+func (t target) merge(model CiscoIosDeviceModel) target {
+	t.host = model.Host.ValueString()
+	if !model.Port.IsNull() {
+		t.port = strconv.Itoa(int(model.Port.ValueInt32()))
+	}
+	if !model.Username.IsNull() {
+		t.username = model.Username.ValueString()
+	}
+	if !model.Password.IsNull() {
+		t.password = model.Password.ValueString()
+	}
+	if !model.EnablePassword.IsNull() {
+		t.enablePassword = model.EnablePassword.ValueString()
+	}
+	// A key of the device replaces the key of the provider block, whichever
+	// way either is set.
+	if !model.PrivateKey.IsNull() || !model.PrivateKeyFile.IsNull() {
+		t.privateKey = model.PrivateKey.ValueString()
+		t.privateKeyFile = model.PrivateKeyFile.ValueString()
+	}
+	if !model.Passphrase.IsNull() {
+		t.passphrase = model.Passphrase.ValueString()
+	}
+	if !model.KnownHostsFile.IsNull() {
+		t.knownHostsFile = model.KnownHostsFile.ValueString()
+	}
+	if !model.HostKeyFingerprint.IsNull() {
+		t.hostKeyFingerprint = model.HostKeyFingerprint.ValueString()
+	}
+	if !model.Transport.IsNull() {
+		t.transport = model.Transport.ValueString()
+	}
+	return t
+}
+
+// checkTarget validates the connection settings of a device, at giving the
+// path of each attribute, and reads its private key file.
+func checkTarget(t *target, at func(string) path.Path, bastion bool, undoOnError string, rollbackOnError string, commitConfirm string, diags *diag.Diagnostics) {
+	if t.username == "" {
+		diags.AddAttributeError(
+			at("username"),
 			"Missing Cisco IOS Username",
 			"The provider cannot create the Cisco IOS client as there is a missing or empty value for the Cisco IOS username. "+
 				"Set the username value in the configuration or use the IOS_USERNAME environment variable. "+
@@ -155,19 +866,42 @@ func (p *CiscoIosProvider) Configure(ctx context.Context, req provider.Configure
 		)
 	}
 
-	if password == "" {
-		resp.Diagnostics.AddAttributeError(
-			path.Root("password"),
+	if t.privateKey != "" && t.privateKeyFile != "" {
+		diags.AddAttributeError(
+			at("private_key"),
+			"Conflicting Cisco IOS Private Key",
+			"The provider cannot create the Cisco IOS client as both the Cisco IOS private key and private key file are set. "+
+				"Set only one of private_key or private_key_file, or of the IOS_PRIVATE_KEY and IOS_PRIVATE_KEY_FILE environment variables.",
+		)
+	}
+
+	if t.privateKeyFile != "" {
+		content, err := os.ReadFile(t.privateKeyFile)
+		if err != nil {
+			diags.AddAttributeError(
+				at("private_key_file"),
+				"Unreadable Cisco IOS Private Key File",
+				"The provider cannot create the Cisco IOS client as the Cisco IOS private key file cannot be read.\n\n"+
+					"Error: "+err.Error(),
+			)
+		}
+		t.privateKey = string(content)
+	}
+
+	if t.password == "" && t.privateKey == "" && t.privateKeyFile == "" {
+		diags.AddAttributeError(
+			at("password"),
 			"Missing Cisco IOS Password",
 			"The provider cannot create the Cisco IOS client as there is a missing or empty value for the Cisco IOS password. "+
-				"Set the password value in the configuration or use the IOS_PASSWORD environment variable. "+
+				"Set the password value in the configuration or use the IOS_PASSWORD environment variable, "+
+				"or authenticate with a private key through private_key, private_key_file, IOS_PRIVATE_KEY or IOS_PRIVATE_KEY_FILE. "+
 				"If either is already set, ensure the value is not empty.",
 		)
 	}
 
-	if port == "" {
-		resp.Diagnostics.AddAttributeError(
-			path.Root("port"),
+	if t.port == "" {
+		diags.AddAttributeError(
+			at("port"),
 			"Missing Cisco IOS Port",
 			"The provider cannot create the Cisco IOS client as there is a missing or empty value for the Cisco IOS port. "+
 				"Set the port value in the configuration or use the IOS_PORT environment variable. "+
@@ -175,30 +909,202 @@ func (p *CiscoIosProvider) Configure(ctx context.Context, req provider.Configure
 		)
 	}
 
-	if resp.Diagnostics.HasError() {
-		return
+	if t.transport != "ssh" && t.transport != "telnet" && t.transport != "restconf" && t.transport != "netconf" {
+		diags.AddAttributeError(
+			at("transport"),
+			"Invalid Cisco IOS Transport",
+			"The provider cannot create the Cisco IOS client as the Cisco IOS transport must be either 'ssh', 'telnet', 'restconf' or 'netconf', got '"+t.transport+"'. "+
+				"Set the transport value in the configuration or use the IOS_TRANSPORT environment variable.",
+		)
 	}
 
-	session := cgnet.Device{
-		Ip:       host,
-		Port:     port,
-		Username: username,
-		Password: password,
-		ConnType: cgnet.SSH,
+	if t.transport == "telnet" {
+		if t.password == "" {
+			diags.AddAttributeError(
+				at("password"),
+				"Missing Cisco IOS Password",
+				"The provider cannot create the Cisco IOS client as the telnet transport only supports password authentication and the Cisco IOS password is empty. "+
+					"Set the password value in the configuration or use the IOS_PASSWORD environment variable.",
+			)
+		}
+		if t.privateKey != "" || bastion {
+			diags.AddAttributeError(
+				at("transport"),
+				"Unsupported Cisco IOS Telnet Options",
+				"The provider cannot create the Cisco IOS client as private keys and bastions are only supported by the ssh transport. "+
+					"Remove them from the configuration or set transport to 'ssh'.",
+			)
+		}
 	}
-	err := session.Open()
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Unable to Create Cisco IOS Client",
-			"An unexpected error occurred when creating the Cisco IOS client. "+
-				"If the error is not clear, please contact the provider developers.\n\n"+
-				"Cisco IOS Error: "+err.Error(),
+
+	if t.transport == "restconf" {
+		if t.password == "" {
+			diags.AddAttributeError(
+				at("password"),
+				"Missing Cisco IOS Password",
+				"The provider cannot create the Cisco IOS client as the restconf transport authenticates with HTTP basic authentication and the Cisco IOS password is empty. "+
+					"Set the password value in the configuration or use the IOS_PASSWORD environment variable.",
+			)
+		}
+		if t.privateKey != "" || bastion {
+			diags.AddAttributeError(
+				at("transport"),
+				"Unsupported Cisco IOS RESTCONF Options",
+				"The provider cannot create the Cisco IOS client as private keys and bastions are only supported by the ssh transport. "+
+					"Remove them from the configuration or set transport to 'ssh'.",
+			)
+		}
+		if undoOnError == "true" || rollbackOnError == "true" || (commitConfirm != "" && commitConfirm != "0") {
+			diags.AddAttributeError(
+				at("transport"),
+				"Unsupported Cisco IOS RESTCONF Options",
+				"The provider cannot create the Cisco IOS client as undo_on_error, rollback_on_error and commit_confirm_timeout drive the CLI of the device and are not supported by the restconf transport. "+
+					"Remove them from the configuration or set transport to 'ssh'.",
+			)
+		}
+	}
+
+	// Both ssh and netconf run over SSH, the host key of the device is
+	// pinned or explicitly ignored.
+	if (t.transport == "ssh" || t.transport == "netconf") && t.knownHostsFile == "" && t.hostKeyFingerprint == "" && !t.ignoreHostKey {
+		diags.AddAttributeError(
+			at("host_key_fingerprint"),
+			"Unverified Cisco IOS Host Key",
+			"The provider cannot create the Cisco IOS client as the host key of the Cisco IOS device cannot be verified. "+
+				"Set known_hosts_file or host_key_fingerprint, or the IOS_KNOWN_HOSTS_FILE or IOS_HOST_KEY_FINGERPRINT environment variables. "+
+				"To accept any host key instead, set insecure_ignore_host_key or the IOS_INSECURE_IGNORE_HOST_KEY environment variable to true.",
+		)
+	}
+
+	if t.transport == "netconf" && (undoOnError == "true" || rollbackOnError == "true" || (commitConfirm != "" && commitConfirm != "0")) {
+		diags.AddAttributeError(
+			at("transport"),
+			"Unsupported Cisco IOS NETCONF Options",
+			"The provider cannot create the Cisco IOS client as undo_on_error, rollback_on_error and commit_confirm_timeout drive the CLI of the device and are not supported by the netconf transport, "+
+				"which commits the changes of each resource at once or not at all. "+
+				"Remove them from the configuration or set transport to 'ssh'.",
 		)
-		return
 	}
+}
 
-	resp.DataSourceData = &session
-	resp.ResourceData = &session
+// warnTarget warns about the connections to a device that can be intercepted.
+func warnTarget(t target, insecure bool, diags *diag.Diagnostics) {
+	if t.transport == "restconf" {
+		if insecure {
+			diags.AddWarning(
+				"Cisco IOS Certificate Not Verified",
+				"insecure_skip_verify is set, the certificate presented by the Cisco IOS device is accepted without verification. "+
+					"Set ca_cert_file, or the IOS_CA_CERT_FILE environment variable, to the authority signing the certificate of the device to protect the connection against man-in-the-middle attacks.",
+			)
+		}
+	} else if t.transport == "telnet" {
+		diags.AddWarning(
+			"Cisco IOS Credentials Sent in Clear Text",
+			"The telnet transport does not encrypt the session, the username, password, enable password and the whole configuration of the Cisco IOS device travel in clear text. "+
+				"Only use it for legacy devices that cannot run SSH, over a trusted network.",
+		)
+	} else if t.knownHostsFile == "" && t.hostKeyFingerprint == "" {
+		diags.AddWarning(
+			"Cisco IOS Host Key Not Verified",
+			"insecure_ignore_host_key is set and neither known_hosts_file nor host_key_fingerprint is, the host key presented by the Cisco IOS device is accepted without verification. "+
+				"Set one of them, or the IOS_KNOWN_HOSTS_FILE or IOS_HOST_KEY_FINGERPRINT environment variables, to protect the connection against man-in-the-middle attacks.",
+		)
+	}
+}
+
+// bastionConfig reads the bastion block, the bastion user defaults to the
+// username of the device. Its host key is only left unverified when
+// ignoreHostKey is set.
+func bastionConfig(ctx context.Context, obj types.Object, username string, ignoreHostKey bool, diags *diag.Diagnostics) *session.SSHConfig {
+	var bastion CiscoIosBastionModel
+	diags.Append(obj.As(ctx, &bastion, basetypes.ObjectAsOptions{})...)
+	if diags.HasError() {
+		return nil
+	}
+
+	if bastion.Host.ValueString() == "" {
+		diags.AddAttributeError(
+			path.Root("bastion").AtName("host"),
+			"Missing Cisco IOS Bastion Host",
+			"The provider cannot create the Cisco IOS client as there is a missing or empty value for the bastion host. "+
+				"Set the host value in the bastion block or remove the block to connect directly.",
+		)
+	}
+
+	if bastion.PrivateKey.ValueString() != "" && bastion.PrivateKeyFile.ValueString() != "" {
+		diags.AddAttributeError(
+			path.Root("bastion").AtName("private_key"),
+			"Conflicting Cisco IOS Bastion Private Key",
+			"The provider cannot create the Cisco IOS client as both the bastion private key and private key file are set. "+
+				"Set only one of private_key or private_key_file in the bastion block.",
+		)
+	}
+
+	privateKey := bastion.PrivateKey.ValueString()
+	if bastion.PrivateKeyFile.ValueString() != "" {
+		content, err := os.ReadFile(bastion.PrivateKeyFile.ValueString())
+		if err != nil {
+			diags.AddAttributeError(
+				path.Root("bastion").AtName("private_key_file"),
+				"Unreadable Cisco IOS Bastion Private Key File",
+				"The provider cannot create the Cisco IOS client as the bastion private key file cannot be read.\n\n"+
+					"Error: "+err.Error(),
+			)
+		}
+		privateKey = string(content)
+	}
+
+	fingerprint := bastion.HostKeyFingerprint.ValueString()
+	if fingerprint == "" && !ignoreHostKey {
+		diags.AddAttributeError(
+			path.Root("bastion").AtName("host_key_fingerprint"),
+			"Unverified Cisco IOS Bastion Host Key",
+			"The provider cannot create the Cisco IOS client as the host key of the bastion cannot be verified. "+
+				"Set host_key_fingerprint in the bastion block. "+
+				"To accept any host key instead, set insecure_ignore_host_key or the IOS_INSECURE_IGNORE_HOST_KEY environment variable to true.",
+		)
+	}
+
+	if bastion.Password.ValueString() == "" && privateKey == "" {
+		diags.AddAttributeError(
+			path.Root("bastion").AtName("password"),
+			"Missing Cisco IOS Bastion Credentials",
+			"The provider cannot create the Cisco IOS client as neither a password nor a private key is set for the bastion. "+
+				"Set password, private_key or private_key_file in the bastion block.",
+		)
+	}
+
+	if diags.HasError() {
+		return nil
+	}
+
+	user := username
+	if !bastion.User.IsNull() {
+		user = bastion.User.ValueString()
+	}
+	port := "22"
+	if !bastion.Port.IsNull() {
+		port = strconv.Itoa(int(bastion.Port.ValueInt32()))
+	}
+
+	if fingerprint == "" {
+		diags.AddAttributeWarning(
+			path.Root("bastion").AtName("host_key_fingerprint"),
+			"Cisco IOS Bastion Host Key Not Verified",
+			"insecure_ignore_host_key is set and host_key_fingerprint is not set in the bastion block, the host key presented by the bastion is accepted without verification. "+
+				"Set it to protect the connection to the bastion against man-in-the-middle attacks.",
+		)
+	}
+
+	return &session.SSHConfig{
+		Host:                  bastion.Host.ValueString(),
+		Port:                  port,
+		Username:              user,
+		Password:              bastion.Password.ValueString(),
+		PrivateKey:            []byte(privateKey),
+		HostKeyFingerprint:    fingerprint,
+		InsecureIgnoreHostKey: ignoreHostKey,
+	}
 }
 
 func (p *CiscoIosProvider) Resources(ctx context.Context) []func() resource.Resource {
@@ -208,6 +1114,7 @@ func (p *CiscoIosProvider) Resources(ctx context.Context) []func() resource.Reso
 		NewInterfaceEthernetResource,
 		NewStaticRouteResource,
 		NewEigrpResource,
+		NewSaveConfigResource,
 	}
 }
 
@@ -221,6 +1128,7 @@ func (p *CiscoIosProvider) DataSources(ctx context.Context) []func() datasource.
 		NewVlansDataSource,
 		NewInterfacesDataSource,
 		NewStaticRoutesDataSource,
+		NewInventoryDataSource,
 	}
 	temps, err := ntc.GetTemplateNames()
 	if err != nil {
@@ -249,3 +1157,30 @@ func New(version string) func() provider.Provider {
 		}
 	}
 }
+
+// isUnknownMap reports whether the map of objects, or any of their
+// attributes, is unknown.
+func isUnknownMap(m types.Map) bool {
+	if m.IsUnknown() {
+		return true
+	}
+	for _, element := range m.Elements() {
+		if obj, ok := element.(types.Object); ok && isUnknown(obj) {
+			return true
+		}
+	}
+	return false
+}
+
+// isUnknown reports whether the object or any of its attributes is unknown.
+func isUnknown(obj types.Object) bool {
+	if obj.IsUnknown() {
+		return true
+	}
+	for _, value := range obj.Attributes() {
+		if value.IsUnknown() {
+			return true
+		}
+	}
+	return false
+}
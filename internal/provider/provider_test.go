@@ -0,0 +1,116 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	gossh "golang.org/x/crypto/ssh"
+	"terraform-provider-ios/internal/fakeios"
+	"terraform-provider-ios/internal/provider/ntc"
+	"terraform-provider-ios/internal/session"
+)
+
+// testAccProtoV6ProviderFactories serve the provider in-process to the
+// Terraform CLI the acceptance tests run. The acceptance tests run against
+// simulated devices, they need Terraform but no Cisco IOS device.
+var testAccProtoV6ProviderFactories = map[string]func() (tfprotov6.ProviderServer, error){
+	"ios": func() (tfprotov6.ProviderServer, error) {
+		// Terraform starts a provider process for each command, which
+		// closes its sessions when it exits.
+		session.Shutdown()
+		return providerserver.NewProtocol6WithError(New("test")())()
+	},
+}
+
+// accDevice is a simulated device served over SSH to an acceptance test.
+type accDevice struct {
+	*fakeios.Device
+	server *fakeios.Server
+}
+
+// newAccDevice starts a device running config, DefaultConfig when empty.
+func newAccDevice(t *testing.T, config string) *accDevice {
+	t.Helper()
+	device := fakeios.New(config)
+	server, err := fakeios.Serve(device, "admin", "cisco")
+	if err != nil {
+		t.Fatalf("failed to start the device: %s", err)
+	}
+	t.Cleanup(func() {
+		server.Close()
+	})
+	t.Cleanup(session.Shutdown)
+	return &accDevice{Device: device, server: server}
+}
+
+// connection returns the arguments reaching the device, its host key
+// pinned.
+func (d *accDevice) connection() string {
+	return fmt.Sprintf("host = %q\nport = %s\nhost_key_fingerprint = %q\n", d.server.Host(), d.server.Port(), gossh.FingerprintSHA256(d.server.HostKey()))
+}
+
+// provider returns the provider block managing the device, followed by the
+// arguments of settings.
+func (d *accDevice) provider(settings ...string) string {
+	return providerBlock(d.connection() + strings.Join(settings, "\n"))
+}
+
+// providerBlock returns the provider block logging in as admin with the
+// arguments of body.
+func providerBlock(body string) string {
+	return fmt.Sprintf("provider \"ios\" {\nusername = \"admin\"\npassword = \"cisco\"\n%s\n}\n", body)
+}
+
+// contains returns a check failing when the running-config of the device
+// does not hold the lines of section, in order.
+func (d *accDevice) contains(section string) resource.TestCheckFunc {
+	return func(*terraform.State) error {
+		if config := d.RunningConfig(); !strings.Contains(config, section) {
+			return fmt.Errorf("the running-config misses %q:\n%s", section, config)
+		}
+		return nil
+	}
+}
+
+// lacks returns a check failing when the running-config of the device holds
+// section.
+func (d *accDevice) lacks(section string) resource.TestCheckFunc {
+	return func(*terraform.State) error {
+		if config := d.RunningConfig(); strings.Contains(config, section) {
+			return fmt.Errorf("the running-config still holds %q:\n%s", section, config)
+		}
+		return nil
+	}
+}
+
+// runs makes the device answer show version and show inventory like the
+// device captured in models/testdata/facts. The test is skipped when the
+// ntc-templates submodule parsing them is not checked out.
+func (d *accDevice) runs(t *testing.T, capture string) {
+	t.Helper()
+	for _, template := range []string{"cisco_ios_show_version.textfsm", "cisco_ios_show_inventory.textfsm"} {
+		if _, err := ntc.GetTemplate(template); err != nil {
+			t.Skipf("the ntc-templates submodule is not checked out: %s", err)
+		}
+	}
+	version, err := os.ReadFile(filepath.Join("models", "testdata", "facts", capture+"_version.txt"))
+	if err != nil {
+		t.Fatalf("failed to read show version: %s", err)
+	}
+	inventory, err := os.ReadFile(filepath.Join("models", "testdata", "facts", capture+"_inventory.txt"))
+	if err != nil {
+		t.Fatalf("failed to read show inventory: %s", err)
+	}
+	d.Version = string(version)
+	d.Inventory = string(inventory)
+}
@@ -0,0 +1,267 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/CorentinPtrl/cisconf"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"terraform-provider-ios/internal/fakeios"
+	"terraform-provider-ios/internal/session"
+)
+
+// newRestconfServer serves a store of DefaultConfig over RESTCONF, and
+// returns it with the provider block managing it, the certificate of the
+// server verified.
+func newRestconfServer(t *testing.T) (*fakeios.RestconfServer, string) {
+	t.Helper()
+	store, err := fakeios.NewStore("")
+	if err != nil {
+		t.Fatalf("failed to create the store: %s", err)
+	}
+	server := fakeios.ServeRestconf(store, "admin", "cisco")
+	t.Cleanup(server.Close)
+	t.Cleanup(session.Shutdown)
+
+	caCertFile := filepath.Join(t.TempDir(), "ca.pem")
+	err = os.WriteFile(caCertFile, server.Certificate(), 0o600)
+	if err != nil {
+		t.Fatalf("failed to write the certificate: %s", err)
+	}
+	return server, providerBlock(fmt.Sprintf("host = %q\nport = %s\ntransport = \"restconf\"\nca_cert_file = %q\nsave_config = \"after_each_change\"\n",
+		server.Host(), server.Port(), caCertFile))
+}
+
+// running returns the running configuration of the store.
+func running(t *testing.T, store *fakeios.Store) *cisconf.Config {
+	t.Helper()
+	config, err := store.Config()
+	if err != nil {
+		t.Fatalf("failed to read the store: %s", err)
+	}
+	return config
+}
+
+func TestAccRestconf(t *testing.T) {
+	server, provider := newRestconfServer(t)
+	store := server.Store
+	vlan := `
+resource "ios_vlan" "test" {
+  id   = 10
+  name = "staff"
+}
+`
+	route := `
+resource "ios_static_route" "test" {
+  prefix   = "10.1.0.0"
+  mask     = "255.255.0.0"
+  next_hop = "192.168.0.253"
+}
+`
+	eigrp := `
+resource "ios_eigrp" "test" {
+  as_number = 100
+  networks  = ["10.0.0.0/8"]
+}
+`
+	trunk := `
+resource "ios_switch_interface" "test" {
+  id          = "GigabitEthernet0/1"
+  description = "office"
+  trunk       = { allowed_vlans = [10, 20] }
+}
+`
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: provider + strings.Replace(vlan, "staff", "users", 1),
+				// The changes are sent as YANG payloads, no command is
+				// planned.
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectKnownValue("ios_vlan.test", tfjsonpath.New("planned_commands"), knownvalue.Null()),
+					},
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_vlan.test", "name", "users"),
+					resource.TestCheckNoResourceAttr("ios_vlan.test", "planned_commands"),
+				),
+			},
+			{
+				Config: provider + vlan,
+				Check: func(*terraform.State) error {
+					if vlans := running(t, store).Vlans; len(vlans) != 1 || vlans[0].Name != "staff" {
+						return fmt.Errorf("vlans = %+v, want vlan 10 staff", vlans)
+					}
+					if !store.Saved() {
+						return fmt.Errorf("the change was not saved")
+					}
+					return nil
+				},
+			},
+			{
+				Config: provider + vlan + strings.Replace(route, "253", "254", 1),
+				Check:  resource.TestCheckResourceAttr("ios_static_route.test", "next_hop", "192.168.0.254"),
+			},
+			{
+				Config: provider + vlan + route,
+				Check:  resource.TestCheckResourceAttr("ios_static_route.test", "next_hop", "192.168.0.253"),
+			},
+			{
+				Config: provider + vlan + route + strings.Replace(eigrp, `"10.0.0.0/8"`, `"10.0.0.0/8", "192.168.0.0/24"`, 1),
+				Check:  resource.TestCheckResourceAttr("ios_eigrp.test", "networks.#", "2"),
+			},
+			{
+				Config: provider + vlan + route + eigrp,
+				Check:  resource.TestCheckResourceAttr("ios_eigrp.test", "networks.#", "1"),
+			},
+			{
+				Config: provider + vlan + route + eigrp + `
+resource "ios_switch_interface" "test" {
+  id          = "GigabitEthernet0/1"
+  description = "office"
+  access      = { access_vlan = 10 }
+}
+`,
+				Check: resource.TestCheckResourceAttr("ios_switch_interface.test", "access.access_vlan", "10"),
+			},
+			{
+				Config: provider + vlan + route + eigrp + trunk,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_switch_interface.test", "switchport", "trunk"),
+					resource.TestCheckResourceAttr("ios_switch_interface.test", "trunk.allowed_vlans.1", "20"),
+				),
+			},
+			{
+				Config: provider + vlan + route + eigrp + trunk + `
+resource "ios_ethernet_interface" "test" {
+  id               = "GigabitEthernet1/0"
+  description      = "uplink"
+  ips              = [{ ip = "10.0.0.1/24" }, { ip = "10.0.3.1/24" }]
+  helper_addresses = ["10.0.1.10"]
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_ethernet_interface.test", "ips.1.ip", "10.0.3.1/24"),
+					resource.TestCheckResourceAttr("ios_ethernet_interface.test", "shutdown", "false"),
+				),
+			},
+		},
+		CheckDestroy: resource.ComposeAggregateTestCheckFunc(
+			unconfigured(t, store),
+			func(*terraform.State) error {
+				if routes := running(t, store).Routes; len(routes) != 1 {
+					return fmt.Errorf("routes = %+v, want the default route only", routes)
+				}
+				return nil
+			},
+		),
+	})
+}
+
+func TestAccRestconfUnsupported(t *testing.T) {
+	_, provider := newRestconfServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: provider + `
+data "ios_show_vlan" "test" {}
+`,
+				ExpectError: regexp.MustCompile(`restconf\s+transport\s+cannot\s+run`),
+			},
+		},
+	})
+}
+
+func TestAccRestconfRejected(t *testing.T) {
+	server, provider := newRestconfServer(t)
+	store := server.Store
+	config := provider + `
+resource "ios_eigrp" "test" {
+  as_number = 100
+  networks  = ["10.0.0.0/8", "192.168.0.0/24"]
+}
+`
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+			},
+			{
+				// The new network is merged before the old ones are
+				// removed, the removal failing leaves the merge undone.
+				PreConfig: func() {
+					store.Reject("the EIGRP process is in use")
+				},
+				Config:      strings.Replace(config, `"10.0.0.0/8", "192.168.0.0/24"`, `"172.16.0.0/16"`, 1),
+				ExpectError: regexp.MustCompile(`the\s+EIGRP\s+process\s+is\s+in\s+use`),
+			},
+			{
+				PreConfig: func() {
+					processes := running(t, store).EIGRPProcess
+					if len(processes) != 1 || len(processes[0].Network) != 2 {
+						t.Errorf("EIGRP processes = %+v, want the running configuration unchanged", processes)
+					}
+					edits := store.Edits()
+					if len(edits) < 3 || edits[len(edits)-3] != "yang-patch merge /" || !strings.HasPrefix(edits[len(edits)-1], "yang-patch remove /router/") {
+						t.Errorf("edits = %q, want a single YANG-Patch merging then removing", edits)
+					}
+					store.Reject("")
+				},
+				Config: config,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectEmptyPlan(),
+					},
+				},
+			},
+		},
+	})
+}
+
+func TestAccRestconfWithoutYangPatch(t *testing.T) {
+	server, provider := newRestconfServer(t)
+	server.NoYangPatch = true
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: provider + `
+resource "ios_vlan" "test" {
+  id   = 10
+  name = "users"
+}
+`,
+			},
+		},
+		CheckDestroy: func(*terraform.State) error {
+			if vlans := running(t, server.Store).Vlans; len(vlans) != 0 {
+				return fmt.Errorf("vlans = %+v, want vlan 10 removed", vlans)
+			}
+			for _, edit := range server.Store.Edits() {
+				if strings.HasPrefix(edit, "yang-patch") {
+					return fmt.Errorf("edits = %q, want one request per resource", server.Store.Edits())
+				}
+			}
+			return nil
+		},
+	})
+}
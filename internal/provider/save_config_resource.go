@@ -0,0 +1,141 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"strings"
+	"terraform-provider-ios/internal/provider/models"
+	"time"
+)
+
+var _ resource.Resource = &SaveConfigResource{}
+
+func NewSaveConfigResource() resource.Resource {
+	return &SaveConfigResource{}
+}
+
+type SaveConfigResource struct {
+	devices *Devices
+}
+
+func (r *SaveConfigResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_save_config"
+}
+
+func (r *SaveConfigResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Copies the running-config to the startup-config when created, and again whenever `triggers` change. Destroying the resource leaves the device untouched.",
+
+		Attributes: map[string]schema.Attribute{
+			"device": deviceResourceAttribute(),
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "Time the configuration was saved, in RFC 3339 format.",
+			},
+			"triggers": schema.MapAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				PlanModifiers: []planmodifier.Map{
+					mapplanmodifier.RequiresReplace(),
+				},
+				Description: "Arbitrary values that save the configuration again when they change, like the ids of the resources the save depends on.",
+			},
+			"result": schema.StringAttribute{
+				Computed:    true,
+				Description: "Output of the device for the copy.",
+			},
+		},
+	}
+}
+
+func (r *SaveConfigResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	devices, ok := req.ProviderData.(*Devices)
+
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *provider.Devices, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	r.devices = devices
+}
+
+func (r *SaveConfigResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data models.SaveConfigModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, diags := r.devices.With(ctx, data.Device, "ios_save_config", "")
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client.Lock()
+	defer unlock(client, &resp.Diagnostics)
+
+	output, err := client.Save()
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Failed to save configuration",
+			fmt.Sprintf("Unable to copy the running-config to the startup-config: %s", err),
+		)
+		return
+	}
+
+	data.ID = types.StringValue(time.Now().UTC().Format(time.RFC3339))
+	data.Result = types.StringValue(strings.TrimSpace(output))
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *SaveConfigResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data models.SaveConfigModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *SaveConfigResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data, state models.SaveConfigModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Any change of the triggers replaces the resource, the last save is kept.
+	data.ID = state.ID
+	data.Result = state.Result
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *SaveConfigResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+}
@@ -0,0 +1,122 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+// saved returns a check failing when the startup-config of the device does
+// not hold section.
+func (d *accDevice) saved(section string) resource.TestCheckFunc {
+	return func(*terraform.State) error {
+		if config := d.StartupConfig(); !strings.Contains(config, section) {
+			return fmt.Errorf("the startup-config misses %q:\n%s", section, config)
+		}
+		return nil
+	}
+}
+
+func TestAccSaveConfigResource(t *testing.T) {
+	d := newAccDevice(t, "")
+	vlan := `
+resource "ios_vlan" "test" {
+  id   = 30
+  name = "printers"
+}
+`
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: d.provider() + vlan,
+				Check: func(*terraform.State) error {
+					if config := d.StartupConfig(); config != "" {
+						return fmt.Errorf("startup-config saved before ios_save_config:\n%s", config)
+					}
+					return nil
+				},
+			},
+			{
+				Config: d.provider() + vlan + `
+resource "ios_save_config" "test" {
+  triggers = {
+    vlan = ios_vlan.test.id
+  }
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("ios_save_config.test", "id"),
+					d.saved("vlan 30\n name printers\n"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccSaveConfigEndOfApply(t *testing.T) {
+	d := newAccDevice(t, "")
+	provider := d.provider(`save_config = "end_of_apply"`)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: provider + `
+resource "ios_vlan" "printers" {
+  id   = 30
+  name = "printers"
+}
+`,
+				Check: d.saved("vlan 30\n name printers\n"),
+			},
+			{
+				PreConfig: func() {
+					d.Reject("copy running-config startup-config")
+				},
+				Config: provider + `
+resource "ios_vlan" "printers" {
+  id   = 30
+  name = "printers"
+}
+
+resource "ios_vlan" "voice" {
+  id   = 40
+  name = "voice"
+}
+`,
+				ExpectError: regexp.MustCompile(`saving them to\s+the\s+startup-config\s+failed`),
+			},
+			{
+				// The vlan was configured before the save failed, it is
+				// saved once the device accepts the copy again.
+				PreConfig: func() {
+					if config := d.RunningConfig(); !strings.Contains(config, "vlan 40\n name voice\n") {
+						t.Errorf("the running-config misses vlan 40:\n%s", config)
+					}
+					d.Accept("copy running-config startup-config")
+				},
+				Config: provider + `
+resource "ios_vlan" "printers" {
+  id   = 30
+  name = "printers"
+}
+
+resource "ios_vlan" "voice" {
+  id   = 40
+  name = "voice"
+}
+`,
+				Check: d.saved("vlan 40\n name voice\n"),
+			},
+		},
+	})
+}
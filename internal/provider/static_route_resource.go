@@ -6,24 +6,30 @@ package provider
 import (
 	"context"
 	"fmt"
-	"github.com/CorentinPtrl/cgnet"
 	"github.com/CorentinPtrl/cisconf"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"slices"
+	"strings"
 	"terraform-provider-ios/internal/provider/models"
+	"terraform-provider-ios/internal/session"
 	"terraform-provider-ios/internal/utils"
 )
 
 var _ resource.Resource = &StaticRouteResource{}
+var _ resource.ResourceWithModifyPlan = &StaticRouteResource{}
+var _ resource.ResourceWithImportState = &StaticRouteResource{}
 
 func NewStaticRouteResource() resource.Resource {
 	return &StaticRouteResource{}
 }
 
 type StaticRouteResource struct {
-	client *cgnet.Device
+	devices *Devices
 }
 
 func (r *StaticRouteResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -35,6 +41,12 @@ func (r *StaticRouteResource) Schema(ctx context.Context, req resource.SchemaReq
 		MarkdownDescription: "Static Route resource",
 
 		Attributes: map[string]schema.Attribute{
+			"device": deviceResourceAttribute(),
+			"planned_commands": schema.ListAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "IOS commands the next apply pushes to the device, computed during plan from the running-config. Empty once the device matches the configuration, an apply leaves the commands it pushed until the next refresh. Null with the netconf and restconf transports, which send YANG payloads rather than commands.",
+			},
 			"prefix": schema.StringAttribute{
 				Required: true,
 				PlanModifiers: []planmodifier.String{
@@ -62,66 +74,84 @@ func (r *StaticRouteResource) Configure(ctx context.Context, req resource.Config
 		return
 	}
 
-	client, ok := req.ProviderData.(*cgnet.Device)
+	devices, ok := req.ProviderData.(*Devices)
 
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Resource Configure Type",
-			fmt.Sprintf("Expected *http.Client, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *provider.Devices, got: %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 
 		return
 	}
 
-	r.client = client
+	r.devices = devices
 }
 
-func (r *StaticRouteResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
-	var data models.RouteModel
+func (r *StaticRouteResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	// Nothing is planned when the resource is destroyed or the provider is
+	// not configured yet.
+	if req.Plan.Raw.IsNull() || r.devices == nil {
+		return
+	}
 
-	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	// The commands can only be computed once every planned value is known
+	// and the device can be reached.
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("planned_commands"), types.ListNull(types.StringType))...)
+	if !resp.Plan.Raw.IsFullyKnown() || r.devices.IsUnknown() {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("planned_commands"), types.ListUnknown(types.StringType))...)
+		return
+	}
+
+	var data models.RouteResourceModel
+
+	resp.Diagnostics.Append(resp.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
+	client, diags := r.devices.With(ctx, data.Device, "ios_static_route", data.Prefix.ValueString()+" "+data.Mask.ValueString())
+	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	routes, err := models.GetRoutes(r.client)
+	marshal, err := utils.Commands(func() (any, any, error) {
+		return r.change(ctx, client, data.RouteModel)
+	})
 	if err != nil {
 		resp.Diagnostics.AddError(
-			"Failed to get static routes",
-			fmt.Sprintf("An error occurred while retrieving static routes: %s", err),
+			"Failed to plan static route",
+			fmt.Sprintf("Unable to compute the commands configuring the static route: %s", err),
 		)
 		return
 	}
 
-	var route *models.RouteModel
-	route = nil
-	for _, v := range routes {
-		if v.Prefix == data.Prefix && v.Mask == data.Mask {
-			route = &v
-			break
-		}
-	}
+	setPlannedCommands(ctx, client, req, resp, marshal)
+}
 
-	var marshal string
-	if route == nil {
-		dest := cisconf.RoutesType{Routes: []cisconf.Route{
-			models.RouteToCisconf(data),
-		}}
-		marshal, err = cisconf.Marshal(dest)
-	} else {
-		src := cisconf.RoutesType{Routes: []cisconf.Route{
-			models.RouteToCisconf(*route),
-		}}
-		dest := cisconf.RoutesType{Routes: []cisconf.Route{
-			models.RouteToCisconf(data),
-		}}
-		marshal, err = cisconf.Diff(src, dest)
+func (r *StaticRouteResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data models.RouteResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
 	}
-	if err != nil {
+
+	client, diags := r.devices.With(ctx, data.Device, "ios_static_route", data.Prefix.ValueString()+" "+data.Mask.ValueString())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
-	err = utils.ConfigDevice(marshal, r.client)
+
+	client.Lock()
+	defer unlock(client, &resp.Diagnostics)
+
+	err := utils.ConfigDevice(client, func() (any, any, error) {
+		return r.change(ctx, client, data.RouteModel)
+	})
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to configure interface",
@@ -130,7 +160,7 @@ func (r *StaticRouteResource) Create(ctx context.Context, req resource.CreateReq
 		return
 	}
 
-	routes, err = models.GetRoutes(r.client)
+	routes, err := models.GetRoutes(client)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to get static routes",
@@ -138,7 +168,7 @@ func (r *StaticRouteResource) Create(ctx context.Context, req resource.CreateReq
 		)
 		return
 	}
-	route = nil
+	var route *models.RouteModel
 	for _, v := range routes {
 		if v.Prefix == data.Prefix && v.Mask == data.Mask {
 			route = &v
@@ -151,11 +181,15 @@ func (r *StaticRouteResource) Create(ctx context.Context, req resource.CreateReq
 		return
 	}
 
-	resp.Diagnostics.Append(resp.State.Set(ctx, route)...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, models.RouteResourceModel{
+		RouteModel:      *route,
+		Device:          data.Device,
+		PlannedCommands: appliedCommands(client, data.PlannedCommands),
+	})...)
 }
 
 func (r *StaticRouteResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
-	var data models.RouteModel
+	var data models.RouteResourceModel
 
 	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
 
@@ -163,7 +197,19 @@ func (r *StaticRouteResource) Read(ctx context.Context, req resource.ReadRequest
 		return
 	}
 
-	routes, err := models.GetRoutes(r.client)
+	client, diags := r.devices.With(ctx, data.Device, "ios_static_route", data.Prefix.ValueString()+" "+data.Mask.ValueString())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// The device cannot be reached until the provider configuration is known,
+	// keep the prior state until then.
+	if client.IsUnknown() {
+		return
+	}
+
+	routes, err := models.GetRoutes(client)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to get static routes",
@@ -185,11 +231,15 @@ func (r *StaticRouteResource) Read(ctx context.Context, req resource.ReadRequest
 		return
 	}
 
-	resp.Diagnostics.Append(resp.State.Set(ctx, &route)...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, models.RouteResourceModel{
+		RouteModel:      *route,
+		Device:          data.Device,
+		PlannedCommands: refreshedCommands(client),
+	})...)
 }
 
 func (r *StaticRouteResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
-	var data models.RouteModel
+	var data models.RouteResourceModel
 
 	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
 
@@ -197,42 +247,18 @@ func (r *StaticRouteResource) Update(ctx context.Context, req resource.UpdateReq
 		return
 	}
 
-	routes, err := models.GetRoutes(r.client)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to get static routes",
-			fmt.Sprintf("An error occurred while retrieving static routes: %s", err),
-		)
+	client, diags := r.devices.With(ctx, data.Device, "ios_static_route", data.Prefix.ValueString()+" "+data.Mask.ValueString())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
-	var route *models.RouteModel
-	route = nil
-	for _, v := range routes {
-		if v.Prefix == data.Prefix && v.Mask == data.Mask {
-			route = &v
-			break
-		}
-	}
 
-	var marshal string
-	if route == nil {
-		dest := cisconf.RoutesType{Routes: []cisconf.Route{
-			models.RouteToCisconf(data),
-		}}
-		marshal, err = cisconf.Marshal(dest)
-	} else {
-		src := cisconf.RoutesType{Routes: []cisconf.Route{
-			models.RouteToCisconf(*route),
-		}}
-		dest := cisconf.RoutesType{Routes: []cisconf.Route{
-			models.RouteToCisconf(data),
-		}}
-		marshal, err = cisconf.Diff(src, dest)
-	}
-	if err != nil {
-		return
-	}
-	err = utils.ConfigDevice(marshal, r.client)
+	client.Lock()
+	defer unlock(client, &resp.Diagnostics)
+
+	err := utils.ConfigDevice(client, func() (any, any, error) {
+		return r.change(ctx, client, data.RouteModel)
+	})
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to configure interface",
@@ -240,7 +266,7 @@ func (r *StaticRouteResource) Update(ctx context.Context, req resource.UpdateReq
 		)
 		return
 	}
-	routes, err = models.GetRoutes(r.client)
+	routes, err := models.GetRoutes(client)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to get static routes",
@@ -248,7 +274,7 @@ func (r *StaticRouteResource) Update(ctx context.Context, req resource.UpdateReq
 		)
 		return
 	}
-	route = nil
+	var route *models.RouteModel
 	for _, v := range routes {
 		if v.Prefix == data.Prefix && v.Mask == data.Mask {
 			route = &v
@@ -256,11 +282,23 @@ func (r *StaticRouteResource) Update(ctx context.Context, req resource.UpdateReq
 		}
 	}
 
-	resp.Diagnostics.Append(resp.State.Set(ctx, route)...)
+	if route == nil {
+		resp.Diagnostics.AddError(
+			"Failed to get static routes",
+			"The static route is missing from the running-config once configured.",
+		)
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, models.RouteResourceModel{
+		RouteModel:      *route,
+		Device:          data.Device,
+		PlannedCommands: appliedCommands(client, data.PlannedCommands),
+	})...)
 }
 
 func (r *StaticRouteResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
-	var data models.RouteModel
+	var data models.RouteResourceModel
 
 	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
 
@@ -268,7 +306,16 @@ func (r *StaticRouteResource) Delete(ctx context.Context, req resource.DeleteReq
 		return
 	}
 
-	err := r.client.Configure([]string{"no ip route " + data.Prefix.ValueString() + " " + data.Mask.ValueString() + " " + data.NextHop.ValueString()})
+	client, diags := r.devices.With(ctx, data.Device, "ios_static_route", data.Prefix.ValueString()+" "+data.Mask.ValueString())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client.Lock()
+	defer unlock(client, &resp.Diagnostics)
+
+	err := client.Remove(cisconf.RoutesType{Routes: []cisconf.Route{models.RouteToCisconf(data.RouteModel)}}, []string{"no ip route " + data.Prefix.ValueString() + " " + data.Mask.ValueString() + " " + data.NextHop.ValueString()})
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to configure interface",
@@ -277,3 +324,41 @@ func (r *StaticRouteResource) Delete(ctx context.Context, req resource.DeleteReq
 		return
 	}
 }
+
+// ImportState adopts a static route from an ID of the form
+// prefix/mask/next_hop, e.g. '10.1.0.0/255.255.0.0/192.168.0.254'.
+func (r *StaticRouteResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	parts := strings.Split(r.devices.importDevice(ctx, req.ID, resp), "/")
+	if len(parts) != 3 || slices.Contains(parts, "") {
+		resp.Diagnostics.AddError(
+			"Unexpected Import Identifier",
+			fmt.Sprintf("Expected an import identifier of the form [device:]prefix/mask/next_hop, e.g. '10.1.0.0/255.255.0.0/192.168.0.254'. Got: %q", req.ID),
+		)
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("prefix"), parts[0])...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("mask"), parts[1])...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("next_hop"), parts[2])...)
+}
+
+// change returns the route read from the running-config, nil when it does not
+// exist, and the route as planned in data.
+func (r *StaticRouteResource) change(ctx context.Context, client *session.Session, data models.RouteModel) (any, any, error) {
+	routes, err := models.GetRoutes(client)
+	if err != nil {
+		return nil, nil, fmt.Errorf("an error occurred while retrieving static routes: %w", err)
+	}
+	dest := cisconf.RoutesType{Routes: []cisconf.Route{
+		models.RouteToCisconf(data),
+	}}
+	for _, v := range routes {
+		if v.Prefix == data.Prefix && v.Mask == data.Mask {
+			src := cisconf.RoutesType{Routes: []cisconf.Route{
+				models.RouteToCisconf(v),
+			}}
+			return src, dest, nil
+		}
+	}
+	return nil, dest, nil
+}
@@ -0,0 +1,113 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
+)
+
+func TestAccStaticRouteResource(t *testing.T) {
+	d := newAccDevice(t, "")
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: d.provider() + `
+resource "ios_static_route" "test" {
+  prefix   = "10.1.0.0"
+  mask     = "255.255.0.0"
+  next_hop = "192.168.0.254"
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_static_route.test", "prefix", "10.1.0.0"),
+					resource.TestCheckResourceAttr("ios_static_route.test", "mask", "255.255.0.0"),
+					resource.TestCheckResourceAttr("ios_static_route.test", "next_hop", "192.168.0.254"),
+					d.contains("ip route 10.1.0.0 255.255.0.0 192.168.0.254\n"),
+				),
+			},
+			{
+				Config: d.provider() + `
+resource "ios_static_route" "test" {
+  prefix   = "10.1.0.0"
+  mask     = "255.255.0.0"
+  next_hop = "192.168.0.253"
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_static_route.test", "next_hop", "192.168.0.253"),
+					d.contains("ip route 10.1.0.0 255.255.0.0 192.168.0.253\n"),
+					d.lacks("ip route 10.1.0.0 255.255.0.0 192.168.0.254\n"),
+				),
+			},
+			{
+				ResourceName:                         "ios_static_route.test",
+				ImportState:                          true,
+				ImportStateId:                        "10.1.0.0/255.255.0.0/192.168.0.253",
+				ImportStateVerify:                    true,
+				ImportStateVerifyIgnore:              []string{"planned_commands"},
+				ImportStateVerifyIdentifierAttribute: "prefix",
+			},
+		},
+		CheckDestroy: resource.ComposeAggregateTestCheckFunc(
+			d.lacks("ip route 10.1.0.0 255.255.0.0"),
+			d.contains("ip route 0.0.0.0 0.0.0.0 192.168.0.1\n"),
+		),
+	})
+}
+
+func TestAccStaticRouteResourceImport(t *testing.T) {
+	d := newAccDevice(t, "")
+	config := d.provider() + `
+resource "ios_static_route" "test" {
+  prefix   = "0.0.0.0"
+  mask     = "0.0.0.0"
+  next_hop = "192.168.0.1"
+}
+`
+
+	steps := []resource.TestStep{
+		{
+			Config:             config,
+			ResourceName:       "ios_static_route.test",
+			ImportState:        true,
+			ImportStateId:      "0.0.0.0/0.0.0.0/192.168.0.1",
+			ImportStatePersist: true,
+		},
+		{
+			Config: config,
+			ConfigPlanChecks: resource.ConfigPlanChecks{
+				PreApply: []plancheck.PlanCheck{
+					plancheck.ExpectEmptyPlan(),
+				},
+			},
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckResourceAttr("ios_static_route.test", "prefix", "0.0.0.0"),
+				resource.TestCheckResourceAttr("ios_static_route.test", "mask", "0.0.0.0"),
+				resource.TestCheckResourceAttr("ios_static_route.test", "next_hop", "192.168.0.1"),
+			),
+		},
+	}
+	for _, id := range []string{"0.0.0.0/0.0.0.0", "0.0.0.0 0.0.0.0 192.168.0.1", "0.0.0.0//192.168.0.1"} {
+		steps = append(steps, resource.TestStep{
+			Config:        config,
+			ResourceName:  "ios_static_route.test",
+			ImportState:   true,
+			ImportStateId: id,
+			ExpectError:   regexp.MustCompile("Unexpected Import Identifier"),
+		})
+	}
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps:                    steps,
+		// The default route was there before the test, destroying the
+		// imported resource removes it.
+		CheckDestroy: d.lacks("ip route 0.0.0.0 0.0.0.0 192.168.0.1\n"),
+	})
+}
@@ -6,7 +6,6 @@ package provider
 import (
 	"context"
 	"fmt"
-	"github.com/CorentinPtrl/cgnet"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
 	"terraform-provider-ios/internal/provider/models"
@@ -19,7 +18,7 @@ func NewStaticRoutesDataSource() datasource.DataSource {
 }
 
 type StaticRoutesDataSource struct {
-	client *cgnet.Device
+	devices *Devices
 }
 
 func (d *StaticRoutesDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
@@ -31,6 +30,7 @@ func (d *StaticRoutesDataSource) Schema(ctx context.Context, req datasource.Sche
 		MarkdownDescription: "Static Routes data source",
 
 		Attributes: map[string]schema.Attribute{
+			"device": deviceDataSourceAttribute(),
 			"routes": schema.ListNestedAttribute{
 				Computed: true,
 				NestedObject: schema.NestedAttributeObject{
@@ -59,18 +59,18 @@ func (d *StaticRoutesDataSource) Configure(ctx context.Context, req datasource.C
 		return
 	}
 
-	client, ok := req.ProviderData.(*cgnet.Device)
+	devices, ok := req.ProviderData.(*Devices)
 
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Data Source Configure Type",
-			fmt.Sprintf("Expected *http.Client, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *provider.Devices, got: %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 
 		return
 	}
 
-	d.client = client
+	d.devices = devices
 }
 
 func (d *StaticRoutesDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
@@ -82,7 +82,22 @@ func (d *StaticRoutesDataSource) Read(ctx context.Context, req datasource.ReadRe
 		return
 	}
 
-	routes, err := models.GetRoutes(d.client)
+	client, diags := d.devices.With(ctx, data.Device, "ios_static_routes", "")
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if client.IsUnknown() {
+		resp.Diagnostics.AddError(
+			"Unknown Cisco IOS Provider Configuration",
+			"The data source cannot be read as the provider configuration depends on values not known until apply. "+
+				"Add a depends_on on the resources the provider configuration refers to so the read happens during apply.",
+		)
+		return
+	}
+
+	routes, err := models.GetRoutes(client)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to get static routes",
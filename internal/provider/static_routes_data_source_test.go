@@ -0,0 +1,34 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"terraform-provider-ios/internal/fakeios"
+)
+
+func TestAccStaticRoutesDataSource(t *testing.T) {
+	d := newAccDevice(t, fakeios.DefaultConfig+"ip route 10.2.0.0 255.255.0.0 192.168.0.254\n")
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: d.provider() + `
+data "ios_static_routes" "test" {}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ios_static_routes.test", "routes.#", "2"),
+					resource.TestCheckResourceAttr("data.ios_static_routes.test", "routes.0.prefix", "0.0.0.0"),
+					resource.TestCheckResourceAttr("data.ios_static_routes.test", "routes.0.next_hop", "192.168.0.1"),
+					resource.TestCheckResourceAttr("data.ios_static_routes.test", "routes.1.prefix", "10.2.0.0"),
+					resource.TestCheckResourceAttr("data.ios_static_routes.test", "routes.1.mask", "255.255.0.0"),
+					resource.TestCheckResourceAttr("data.ios_static_routes.test", "routes.1.next_hop", "192.168.0.254"),
+				),
+			},
+		},
+	})
+}
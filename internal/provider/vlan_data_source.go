@@ -6,7 +6,6 @@ package provider
 import (
 	"context"
 	"fmt"
-	"github.com/CorentinPtrl/cgnet"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
 	"terraform-provider-ios/internal/provider/models"
@@ -19,7 +18,7 @@ func NewVlanDataSource() datasource.DataSource {
 }
 
 type VlanDataSource struct {
-	client *cgnet.Device
+	devices *Devices
 }
 
 func (d *VlanDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
@@ -31,6 +30,7 @@ func (d *VlanDataSource) Schema(ctx context.Context, req datasource.SchemaReques
 		MarkdownDescription: "Vlan data source",
 
 		Attributes: map[string]schema.Attribute{
+			"device": deviceDataSourceAttribute(),
 			"id": schema.Int32Attribute{
 				Required:    true,
 				Description: "The VLAN ID to retrieve. This is a required field and must be specified.",
@@ -47,22 +47,22 @@ func (d *VlanDataSource) Configure(ctx context.Context, req datasource.Configure
 		return
 	}
 
-	client, ok := req.ProviderData.(*cgnet.Device)
+	devices, ok := req.ProviderData.(*Devices)
 
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Data Source Configure Type",
-			fmt.Sprintf("Expected *http.Client, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *provider.Devices, got: %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 
 		return
 	}
 
-	d.client = client
+	d.devices = devices
 }
 
 func (d *VlanDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
-	var data models.VlanModel
+	var data models.VlanDataSourceModel
 
 	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
 
@@ -70,7 +70,22 @@ func (d *VlanDataSource) Read(ctx context.Context, req datasource.ReadRequest, r
 		return
 	}
 
-	vlans, err := models.GetVlans(d.client)
+	client, diags := d.devices.With(ctx, data.Device, "ios_vlan", "")
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if client.IsUnknown() {
+		resp.Diagnostics.AddError(
+			"Unknown Cisco IOS Provider Configuration",
+			"The data source cannot be read as the provider configuration depends on values not known until apply. "+
+				"Add a depends_on on the resources the provider configuration refers to so the read happens during apply.",
+		)
+		return
+	}
+
+	vlans, err := models.GetVlans(ctx, client)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to get VLANs",
@@ -84,5 +99,8 @@ func (d *VlanDataSource) Read(ctx context.Context, req datasource.ReadRequest, r
 		return
 	}
 
-	resp.Diagnostics.Append(resp.State.Set(ctx, &vlan)...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, models.VlanDataSourceModel{
+		VlanModel: vlan,
+		Device:    data.Device,
+	})...)
 }
@@ -0,0 +1,36 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"terraform-provider-ios/internal/fakeios"
+)
+
+func TestAccVlanDataSource(t *testing.T) {
+	d := newAccDevice(t, fakeios.DefaultConfig+"vlan 100\n name mgmt\n!\n")
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: d.provider() + `
+data "ios_vlan" "mgmt" {
+  id = 100
+}
+
+data "ios_vlan" "default" {
+  id = 1
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ios_vlan.mgmt", "name", "mgmt"),
+					resource.TestCheckResourceAttr("data.ios_vlan.default", "name", "default"),
+				),
+			},
+		},
+	})
+}
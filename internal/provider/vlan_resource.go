@@ -6,24 +6,29 @@ package provider
 import (
 	"context"
 	"fmt"
-	"github.com/CorentinPtrl/cgnet"
 	"github.com/CorentinPtrl/cisconf"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int32planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"strconv"
 	"terraform-provider-ios/internal/provider/models"
+	"terraform-provider-ios/internal/session"
 	"terraform-provider-ios/internal/utils"
 )
 
 var _ resource.Resource = &VlanResource{}
+var _ resource.ResourceWithModifyPlan = &VlanResource{}
+var _ resource.ResourceWithImportState = &VlanResource{}
 
 func NewVlanResource() resource.Resource {
 	return &VlanResource{}
 }
 
 type VlanResource struct {
-	client *cgnet.Device
+	devices *Devices
 }
 
 func (r *VlanResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -35,6 +40,12 @@ func (r *VlanResource) Schema(ctx context.Context, req resource.SchemaRequest, r
 		MarkdownDescription: "Vlan resource",
 
 		Attributes: map[string]schema.Attribute{
+			"device": deviceResourceAttribute(),
+			"planned_commands": schema.ListAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "IOS commands the next apply pushes to the device, computed during plan from the running-config. Empty once the device matches the configuration, an apply leaves the commands it pushed until the next refresh. Null with the netconf and restconf transports, which send YANG payloads rather than commands.",
+			},
 			"id": schema.Int32Attribute{
 				Required: true,
 				PlanModifiers: []planmodifier.Int32{
@@ -54,48 +65,89 @@ func (r *VlanResource) Configure(ctx context.Context, req resource.ConfigureRequ
 		return
 	}
 
-	client, ok := req.ProviderData.(*cgnet.Device)
+	devices, ok := req.ProviderData.(*Devices)
 
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Resource Configure Type",
-			fmt.Sprintf("Expected *http.Client, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *provider.Devices, got: %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 
 		return
 	}
 
-	r.client = client
+	r.devices = devices
 }
 
-func (r *VlanResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
-	var data models.VlanModel
+func (r *VlanResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	// Nothing is planned when the resource is destroyed or the provider is
+	// not configured yet.
+	if req.Plan.Raw.IsNull() || r.devices == nil {
+		return
+	}
 
-	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	// The commands can only be computed once every planned value is known
+	// and the device can be reached.
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("planned_commands"), types.ListNull(types.StringType))...)
+	if !resp.Plan.Raw.IsFullyKnown() || r.devices.IsUnknown() {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("planned_commands"), types.ListUnknown(types.StringType))...)
+		return
+	}
+
+	var data models.VlanResourceModel
+
+	resp.Diagnostics.Append(resp.Plan.Get(ctx, &data)...)
 
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	vlan, err := models.GetVlan(r.client, int(data.Id.ValueInt32()))
+	client, diags := r.devices.With(ctx, data.Device, "ios_vlan", data.Id.String())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(checkFeature(ctx, client, models.VlanConfiguration, path.Root("id"))...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	marshal, err := utils.Commands(func() (any, any, error) {
+		return r.change(ctx, client, data.VlanModel)
+	})
 	if err != nil {
 		resp.Diagnostics.AddError(
-			"Failed to get vlan",
-			fmt.Sprintf("Unable to get vlan: %s", err),
+			"Failed to plan vlan",
+			fmt.Sprintf("Unable to compute the commands configuring the vlan: %s", err),
 		)
 		return
 	}
 
-	var marshal string
-	if vlan == nil {
-		marshal, err = cisconf.Marshal(models.VlanToCisconf(ctx, data))
-	} else {
-		marshal, err = cisconf.Diff(models.VlanToCisconf(ctx, *vlan), models.VlanToCisconf(ctx, data))
+	setPlannedCommands(ctx, client, req, resp, marshal)
+}
+
+func (r *VlanResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data models.VlanResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
 	}
-	if err != nil {
+
+	client, diags := r.devices.With(ctx, data.Device, "ios_vlan", data.Id.String())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
-	err = utils.ConfigDevice(marshal, r.client)
+
+	client.Lock()
+	defer unlock(client, &resp.Diagnostics)
+
+	err := utils.ConfigDevice(client, func() (any, any, error) {
+		return r.change(ctx, client, data.VlanModel)
+	})
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to configure interface",
@@ -104,7 +156,7 @@ func (r *VlanResource) Create(ctx context.Context, req resource.CreateRequest, r
 		return
 	}
 
-	vlan, err = models.GetVlan(r.client, int(data.Id.ValueInt32()))
+	vlan, err := models.GetVlan(ctx, client, int(data.Id.ValueInt32()))
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to get vlan",
@@ -113,11 +165,23 @@ func (r *VlanResource) Create(ctx context.Context, req resource.CreateRequest, r
 		return
 	}
 
-	resp.Diagnostics.Append(resp.State.Set(ctx, vlan)...)
+	if vlan == nil {
+		resp.Diagnostics.AddError(
+			"Failed to get vlan",
+			"The vlan is missing from the running-config once configured.",
+		)
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, models.VlanResourceModel{
+		VlanModel:       *vlan,
+		Device:          data.Device,
+		PlannedCommands: appliedCommands(client, data.PlannedCommands),
+	})...)
 }
 
 func (r *VlanResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
-	var data models.VlanModel
+	var data models.VlanResourceModel
 
 	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
 
@@ -125,7 +189,19 @@ func (r *VlanResource) Read(ctx context.Context, req resource.ReadRequest, resp
 		return
 	}
 
-	vlan, err := models.GetVlan(r.client, int(data.Id.ValueInt32()))
+	client, diags := r.devices.With(ctx, data.Device, "ios_vlan", data.Id.String())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// The device cannot be reached until the provider configuration is known,
+	// keep the prior state until then.
+	if client.IsUnknown() {
+		return
+	}
+
+	vlan, err := models.GetVlan(ctx, client, int(data.Id.ValueInt32()))
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to get vlan",
@@ -139,11 +215,15 @@ func (r *VlanResource) Read(ctx context.Context, req resource.ReadRequest, resp
 		return
 	}
 
-	resp.Diagnostics.Append(resp.State.Set(ctx, vlan)...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, models.VlanResourceModel{
+		VlanModel:       *vlan,
+		Device:          data.Device,
+		PlannedCommands: refreshedCommands(client),
+	})...)
 }
 
 func (r *VlanResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
-	var data models.VlanModel
+	var data models.VlanResourceModel
 
 	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
 
@@ -151,25 +231,18 @@ func (r *VlanResource) Update(ctx context.Context, req resource.UpdateRequest, r
 		return
 	}
 
-	vlan, err := models.GetVlan(r.client, int(data.Id.ValueInt32()))
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to get vlan",
-			fmt.Sprintf("Unable to get vlan: %s", err),
-		)
+	client, diags := r.devices.With(ctx, data.Device, "ios_vlan", data.Id.String())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	var marshal string
-	if vlan == nil {
-		marshal, err = cisconf.Marshal(models.VlanToCisconf(ctx, data))
-	} else {
-		marshal, err = cisconf.Diff(models.VlanToCisconf(ctx, *vlan), models.VlanToCisconf(ctx, data))
-	}
-	if err != nil {
-		return
-	}
-	err = utils.ConfigDevice(marshal, r.client)
+	client.Lock()
+	defer unlock(client, &resp.Diagnostics)
+
+	err := utils.ConfigDevice(client, func() (any, any, error) {
+		return r.change(ctx, client, data.VlanModel)
+	})
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to configure interface",
@@ -178,7 +251,7 @@ func (r *VlanResource) Update(ctx context.Context, req resource.UpdateRequest, r
 		return
 	}
 
-	vlan, err = models.GetVlan(r.client, int(data.Id.ValueInt32()))
+	vlan, err := models.GetVlan(ctx, client, int(data.Id.ValueInt32()))
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to get vlan",
@@ -187,11 +260,23 @@ func (r *VlanResource) Update(ctx context.Context, req resource.UpdateRequest, r
 		return
 	}
 
-	resp.Diagnostics.Append(resp.State.Set(ctx, vlan)...)
+	if vlan == nil {
+		resp.Diagnostics.AddError(
+			"Failed to get vlan",
+			"The vlan is missing from the running-config once configured.",
+		)
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, models.VlanResourceModel{
+		VlanModel:       *vlan,
+		Device:          data.Device,
+		PlannedCommands: appliedCommands(client, data.PlannedCommands),
+	})...)
 }
 
 func (r *VlanResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
-	var data models.VlanModel
+	var data models.VlanResourceModel
 
 	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
 
@@ -199,7 +284,16 @@ func (r *VlanResource) Delete(ctx context.Context, req resource.DeleteRequest, r
 		return
 	}
 
-	err := r.client.Configure([]string{"no vlan " + fmt.Sprintf("%d", data.Id.ValueInt32())})
+	client, diags := r.devices.With(ctx, data.Device, "ios_vlan", data.Id.String())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client.Lock()
+	defer unlock(client, &resp.Diagnostics)
+
+	err := client.Remove(cisconf.Vlan{Id: int(data.Id.ValueInt32())}, []string{"no vlan " + fmt.Sprintf("%d", data.Id.ValueInt32())})
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to configure interface",
@@ -208,3 +302,31 @@ func (r *VlanResource) Delete(ctx context.Context, req resource.DeleteRequest, r
 		return
 	}
 }
+
+// ImportState adopts a vlan already configured on the device from its ID,
+// the following read fills the name from the running-config.
+func (r *VlanResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	id, err := strconv.ParseInt(r.devices.importDevice(ctx, req.ID, resp), 10, 32)
+	if err != nil || id < 1 || id > 4094 {
+		resp.Diagnostics.AddError(
+			"Unexpected Import Identifier",
+			fmt.Sprintf("Expected the VLAN ID, a number between 1 and 4094, optionally prefixed with the device and a colon. Got: %q", req.ID),
+		)
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), int32(id))...)
+}
+
+// change returns the vlan read from the running-config, nil when it does not
+// exist, and the vlan as planned in data.
+func (r *VlanResource) change(ctx context.Context, client *session.Session, data models.VlanModel) (any, any, error) {
+	vlan, err := models.GetVlan(ctx, client, int(data.Id.ValueInt32()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to get vlan: %w", err)
+	}
+	if vlan == nil {
+		return nil, models.VlanToCisconf(ctx, data), nil
+	}
+	return models.VlanToCisconf(ctx, *vlan), models.VlanToCisconf(ctx, data), nil
+}
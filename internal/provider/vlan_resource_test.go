@@ -0,0 +1,170 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"terraform-provider-ios/internal/fakeios"
+)
+
+func TestAccVlanResource(t *testing.T) {
+	d := newAccDevice(t, "")
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: d.provider() + `
+resource "ios_vlan" "test" {
+  id   = 10
+  name = "users"
+}
+`,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectKnownValue("ios_vlan.test", tfjsonpath.New("planned_commands"), knownvalue.ListPartial(map[int]knownvalue.Check{
+							0: knownvalue.StringExact("vlan 10"),
+							1: knownvalue.StringExact(" name users"),
+						})),
+					},
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_vlan.test", "id", "10"),
+					resource.TestCheckResourceAttr("ios_vlan.test", "name", "users"),
+					d.contains("vlan 10\n name users\n"),
+				),
+			},
+			{
+				Config: d.provider() + `
+resource "ios_vlan" "test" {
+  id   = 10
+  name = "staff"
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_vlan.test", "name", "staff"),
+					d.contains("vlan 10\n name staff\n"),
+				),
+			},
+			{
+				RefreshState: true,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_vlan.test", "name", "staff"),
+					resource.TestCheckResourceAttr("ios_vlan.test", "planned_commands.#", "0"),
+				),
+			},
+			{
+				ResourceName:      "ios_vlan.test",
+				ImportState:       true,
+				ImportStateId:     "10",
+				ImportStateVerify: true,
+			},
+		},
+		CheckDestroy: d.lacks("vlan 10\n"),
+	})
+}
+
+func TestAccVlanResourceImport(t *testing.T) {
+	d := newAccDevice(t, strings.Replace(fakeios.DefaultConfig, "vlan internal", "vlan 30\n name printers\n!\nvlan internal", 1))
+	config := d.provider() + `
+resource "ios_vlan" "test" {
+  id   = 30
+  name = "printers"
+}
+`
+
+	steps := []resource.TestStep{
+		{
+			Config:             config,
+			ResourceName:       "ios_vlan.test",
+			ImportState:        true,
+			ImportStateId:      "30",
+			ImportStatePersist: true,
+		},
+		{
+			Config: config,
+			ConfigPlanChecks: resource.ConfigPlanChecks{
+				PreApply: []plancheck.PlanCheck{
+					plancheck.ExpectEmptyPlan(),
+				},
+			},
+			Check: resource.ComposeAggregateTestCheckFunc(
+				resource.TestCheckResourceAttr("ios_vlan.test", "id", "30"),
+				resource.TestCheckResourceAttr("ios_vlan.test", "name", "printers"),
+			),
+		},
+	}
+	for _, id := range []string{"vlan30", "0", "4095"} {
+		steps = append(steps, resource.TestStep{
+			Config:        config,
+			ResourceName:  "ios_vlan.test",
+			ImportState:   true,
+			ImportStateId: id,
+			ExpectError:   regexp.MustCompile("Unexpected Import Identifier"),
+		})
+	}
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps:                    steps,
+	})
+}
+
+func TestAccVlanResourceRouter(t *testing.T) {
+	d := newAccDevice(t, "")
+	d.runs(t, "ios15_c1921")
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: d.provider() + `
+resource "ios_vlan" "test" {
+  id   = 10
+  name = "users"
+}
+`,
+				ExpectError: regexp.MustCompile(`(?s)Unsupported Cisco IOS Feature.*vlan\s+database`),
+			},
+		},
+	})
+}
+
+func TestAccVlanResourceUnknownPlan(t *testing.T) {
+	d := newAccDevice(t, "")
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				// The name is only known once ios_save_config is applied,
+				// the commands are computed when Terraform plans the vlan
+				// again during apply.
+				Config: d.provider() + `
+resource "ios_save_config" "test" {}
+
+resource "ios_vlan" "test" {
+  id   = 10
+  name = "v${ios_save_config.test.id}"
+}
+`,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectUnknownValue("ios_vlan.test", tfjsonpath.New("planned_commands")),
+					},
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ios_vlan.test", "planned_commands.0", "vlan 10"),
+					d.contains("vlan 10\n name v"),
+				),
+			},
+		},
+	})
+}
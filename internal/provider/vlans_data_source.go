@@ -6,7 +6,6 @@ package provider
 import (
 	"context"
 	"fmt"
-	"github.com/CorentinPtrl/cgnet"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
 	"sort"
@@ -20,7 +19,7 @@ func NewVlansDataSource() datasource.DataSource {
 }
 
 type VlansDataSource struct {
-	client *cgnet.Device
+	devices *Devices
 }
 
 func (d *VlansDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
@@ -32,6 +31,7 @@ func (d *VlansDataSource) Schema(ctx context.Context, req datasource.SchemaReque
 		MarkdownDescription: "Vlans data source",
 
 		Attributes: map[string]schema.Attribute{
+			"device": deviceDataSourceAttribute(),
 			"vlans": schema.ListNestedAttribute{
 				Computed: true,
 				NestedObject: schema.NestedAttributeObject{
@@ -54,18 +54,18 @@ func (d *VlansDataSource) Configure(ctx context.Context, req datasource.Configur
 		return
 	}
 
-	client, ok := req.ProviderData.(*cgnet.Device)
+	devices, ok := req.ProviderData.(*Devices)
 
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Data Source Configure Type",
-			fmt.Sprintf("Expected *http.Client, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *provider.Devices, got: %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 
 		return
 	}
 
-	d.client = client
+	d.devices = devices
 }
 
 func (d *VlansDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
@@ -77,7 +77,22 @@ func (d *VlansDataSource) Read(ctx context.Context, req datasource.ReadRequest,
 		return
 	}
 
-	vlans, err := models.GetVlans(d.client)
+	client, diags := d.devices.With(ctx, data.Device, "ios_vlans", "")
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if client.IsUnknown() {
+		resp.Diagnostics.AddError(
+			"Unknown Cisco IOS Provider Configuration",
+			"The data source cannot be read as the provider configuration depends on values not known until apply. "+
+				"Add a depends_on on the resources the provider configuration refers to so the read happens during apply.",
+		)
+		return
+	}
+
+	vlans, err := models.GetVlans(ctx, client)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to get VLANs",
@@ -0,0 +1,31 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"terraform-provider-ios/internal/fakeios"
+)
+
+func TestAccVlansDataSource(t *testing.T) {
+	d := newAccDevice(t, fakeios.DefaultConfig+"vlan 100\n name mgmt\n!\nvlan 200\n!\n")
+
+	checks := []resource.TestCheckFunc{}
+	for id, name := range map[string]string{"1": "default", "100": "mgmt", "200": "VLAN0200", "1002": "fddi-default"} {
+		checks = append(checks, resource.TestCheckTypeSetElemNestedAttrs("data.ios_vlans.test", "vlans.*", map[string]string{"id": id, "name": name}))
+	}
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: d.provider() + `
+data "ios_vlans" "test" {}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(checks...),
+			},
+		},
+	})
+}
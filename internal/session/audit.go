@@ -0,0 +1,285 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package session
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// secrets matches the arguments of the IOS commands carrying passwords, keys
+// and SNMP communities, with their optional encryption type.
+var secrets = regexp.MustCompile(`(?im)(^|[ \t])(password|secret|community|key-string|key|authentication-key|pre-shared-key|md5)((?:[ \t]+[0-9])?[ \t]+)\S+`)
+
+// snmpHosts matches the community, or the SNMPv3 user, of snmp-server host.
+var snmpHosts = regexp.MustCompile(`(?im)^([ \t]*(?:no[ \t]+)?snmp-server[ \t]+host[ \t]+\S+(?:[ \t]+vrf[ \t]+\S+)?(?:[ \t]+(?:informs|traps))?(?:[ \t]+version[ \t]+(?:1|2c|3[ \t]+(?:auth|noauth|priv)))?[ \t]+)([^\s<]\S*)`)
+
+// secretLeaves are the leaves of the Cisco-IOS-XE-native model carrying
+// passwords, keys and SNMP communities.
+var secretLeaves = map[string]bool{
+	"password":           true,
+	"secret":             true,
+	"key-string":         true,
+	"key":                true,
+	"authentication-key": true,
+	"pre-shared-key":     true,
+	"md5":                true,
+	"community-or-user":  true,
+}
+
+// secretLeaf tells whether the leaf name, under the node parent, carries a
+// secret. The SNMP communities are the keys of the community lists.
+func secretLeaf(parent string, name string) bool {
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.LastIndex(parent, ":"); i >= 0 {
+		parent = parent[i+1:]
+	}
+	return secretLeaves[name] || name == "name" && (parent == "community" || parent == "community-config")
+}
+
+// Redact hides the passwords, secrets and SNMP communities from a command or
+// the output of the device.
+func Redact(text string) string {
+	text = secrets.ReplaceAllString(text, "$1$2$3<redacted>")
+	return snmpHosts.ReplaceAllString(text, "$1<redacted>")
+}
+
+// span is a part of a body to replace.
+type span struct {
+	start int64
+	end   int64
+	text  string
+}
+
+// splice replaces the spans, in order, of data.
+func splice(data []byte, spans []span) string {
+	var b strings.Builder
+	var last int64
+	for _, s := range spans {
+		b.Write(data[last:s.start])
+		b.WriteString(s.text)
+		last = s.end
+	}
+	b.Write(data[last:])
+	return b.String()
+}
+
+// redactJSON hides the secret leaves of a RESTCONF body. A body that is not
+// JSON is redacted as a command.
+func redactJSON(data []byte) string {
+	// node is an object or array being read, named after its member or
+	// after the list holding it.
+	type node struct {
+		name   string
+		object bool
+		key    string
+		// member is set when the next token of an object is a member name.
+		member bool
+	}
+	var spans []span
+	var nodes []*node
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	for {
+		start := dec.InputOffset()
+		token, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Redact(string(data))
+		}
+		if token == json.Delim('}') || token == json.Delim(']') {
+			nodes = nodes[:len(nodes)-1]
+			continue
+		}
+		var top *node
+		if len(nodes) > 0 {
+			top = nodes[len(nodes)-1]
+		}
+		if top != nil && top.member {
+			top.key, _ = token.(string)
+			top.member = false
+			continue
+		}
+
+		name, parent := "", ""
+		if top != nil && top.object {
+			name, parent = top.key, top.name
+			top.member = true
+		} else if top != nil {
+			name = top.name
+		}
+		switch token {
+		case json.Delim('{'):
+			nodes = append(nodes, &node{name: name, object: true, member: true})
+		case json.Delim('['):
+			nodes = append(nodes, &node{name: name})
+		default:
+			if _, ok := token.(string); ok && secretLeaf(parent, name) {
+				end := dec.InputOffset()
+				start += int64(bytes.IndexByte(data[start:end], '"'))
+				spans = append(spans, span{start, end, `"<redacted>"`})
+			}
+		}
+	}
+	return splice(data, spans)
+}
+
+// redactXML hides the secret leaves of a NETCONF message. A message that is
+// not XML is redacted as a command.
+func redactXML(data []byte) string {
+	var spans []span
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var names []string
+	for {
+		start := dec.InputOffset()
+		token, err := dec.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Redact(string(data))
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			names = append(names, token.Name.Local)
+		case xml.EndElement:
+			if len(names) > 0 {
+				names = names[:len(names)-1]
+			}
+		case xml.CharData:
+			if len(names) == 0 || len(bytes.TrimSpace(token)) == 0 {
+				continue
+			}
+			parent := ""
+			if len(names) > 1 {
+				parent = names[len(names)-2]
+			}
+			if secretLeaf(parent, names[len(names)-1]) {
+				spans = append(spans, span{start, dec.InputOffset(), "&lt;redacted&gt;"})
+			}
+		}
+	}
+	return splice(data, spans)
+}
+
+// AuditEntry is a command sent to a device and its response. Resource is the
+// type of the resource or data source sending it and ID the object it
+// manages, like ios_vlan and 10: the plugin framework does not pass the
+// address of a resource in the configuration, like ios_vlan.users, to the
+// provider.
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	Host     string    `json:"host"`
+	Resource string    `json:"resource,omitempty"`
+	ID       string    `json:"id,omitempty"`
+	Command  string    `json:"command"`
+	Response string    `json:"response"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// AuditLog appends the commands sent to the devices to a JSON lines file.
+type AuditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenAuditLog opens the audit log at path, creating it when needed.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditLog{file: file}, nil
+}
+
+// Write appends the entry as a single line.
+func (a *AuditLog) Write(entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err = a.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the file of the audit log.
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.file.Close()
+}
+
+// tracer is implemented by the connections reporting each command they send.
+type tracer interface {
+	setTrace(trace func(cmd string, output string, err error))
+}
+
+// traced makes conn report the commands it sends to the session.
+func (s *Session) traced(conn Conn) Conn {
+	if t, ok := conn.(tracer); ok {
+		t.setTrace(s.record)
+	}
+	return conn
+}
+
+// context returns the context the session is scoped to, the background
+// context when it is not scoped.
+func (s *Session) context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// record logs a command sent to the device, redacted, with tflog and to the
+// audit log.
+func (s *Session) record(cmd string, output string, err error) {
+	ctx := s.context()
+	entry := AuditEntry{
+		Time:     time.Now().UTC(),
+		Host:     s.host,
+		Resource: s.resource,
+		ID:       s.id,
+		Command:  Redact(cmd),
+		Response: Redact(output),
+	}
+	if err != nil {
+		entry.Error = Redact(err.Error())
+	}
+
+	tflog.Debug(ctx, "Sent command to Cisco IOS device", map[string]interface{}{
+		"host":     entry.Host,
+		"resource": entry.Resource,
+		"id":       entry.ID,
+		"command":  entry.Command,
+		"response": entry.Response,
+		"error":    entry.Error,
+	})
+
+	if s.audit == nil {
+		return
+	}
+	auditErr := s.audit.Write(entry)
+	if auditErr != nil {
+		tflog.Error(ctx, "Failed to write the Cisco IOS audit log", map[string]interface{}{
+			"error": auditErr.Error(),
+		})
+	}
+}
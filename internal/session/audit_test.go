@@ -0,0 +1,99 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package session
+
+import (
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"enable secret 5 $1$abcd$efgh", "enable secret 5 <redacted>"},
+		{"username admin privilege 15 password 0 cisco", "username admin privilege 15 password 0 <redacted>"},
+		{"snmp-server community public RO", "snmp-server community <redacted> RO"},
+		{" key-string 7 0822455D0A16", " key-string 7 <redacted>"},
+		{" ip authentication key-chain eigrp 10 KEYS", " ip authentication key-chain eigrp 10 KEYS"},
+		{"snmp-server host 10.0.0.1 public", "snmp-server host 10.0.0.1 <redacted>"},
+		{"snmp-server host 10.0.0.1 vrf MGMT informs version 2c public udp-port 162", "snmp-server host 10.0.0.1 vrf MGMT informs version 2c <redacted> udp-port 162"},
+		{"no snmp-server host 10.0.0.1 version 3 priv monitor", "no snmp-server host 10.0.0.1 version 3 priv <redacted>"},
+		{"vlan 10\n name users\n!", "vlan 10\n name users\n!"},
+	}
+	for _, tt := range tests {
+		if got := Redact(tt.text); got != tt.want {
+			t.Errorf("Redact(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestRedactJSON(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{
+			`{"Cisco-IOS-XE-native:enable": {"secret": {"type": "5", "secret": "$1$abcd"}}}`,
+			`{"Cisco-IOS-XE-native:enable": {"secret": {"type": "5", "secret": "<redacted>"}}}`,
+		},
+		{
+			`{"username": [{"name": "admin", "password": {"encryption": "0", "password": "cisco"}}]}`,
+			`{"username": [{"name": "admin", "password": {"encryption": "0", "password": "<redacted>"}}]}`,
+		},
+		{
+			`{"snmp-server": {"Cisco-IOS-XE-snmp:community": [{"name": "public", "RO": [null]}, {"name": "private", "RW": [null]}]}}`,
+			`{"snmp-server": {"Cisco-IOS-XE-snmp:community": [{"name": "<redacted>", "RO": [null]}, {"name": "<redacted>", "RW": [null]}]}}`,
+		},
+		{
+			`{"host": [{"ip-address": "10.0.0.1", "community-or-user": "public", "version": "2c"}]}`,
+			`{"host": [{"ip-address": "10.0.0.1", "community-or-user": "<redacted>", "version": "2c"}]}`,
+		},
+		{
+			`{"vlan-list": [{"id": 10, "name": "users"}]}`,
+			`{"vlan-list": [{"id": 10, "name": "users"}]}`,
+		},
+		{"", ""},
+		{"username admin password cisco", "username admin password <redacted>"},
+	}
+	for _, tt := range tests {
+		if got := redactJSON([]byte(tt.body)); got != tt.want {
+			t.Errorf("redactJSON(%s) = %s, want %s", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestRedactXML(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{
+			`<enable><secret><type>5</type><secret>$1$abcd</secret></secret></enable>`,
+			`<enable><secret><type>5</type><secret>&lt;redacted&gt;</secret></secret></enable>`,
+		},
+		{
+			`<username><name>admin</name><password><encryption>0</encryption><password>cisco</password></password></username>`,
+			`<username><name>admin</name><password><encryption>0</encryption><password>&lt;redacted&gt;</password></password></username>`,
+		},
+		{
+			`<community xmlns="http://cisco.com/ns/yang/Cisco-IOS-XE-snmp"><name>public</name><RO/></community>`,
+			`<community xmlns="http://cisco.com/ns/yang/Cisco-IOS-XE-snmp"><name>&lt;redacted&gt;</name><RO/></community>`,
+		},
+		{
+			`<ios-snmp:host><ios-snmp:ip-address>10.0.0.1</ios-snmp:ip-address><ios-snmp:community-or-user>public</ios-snmp:community-or-user></ios-snmp:host>`,
+			`<ios-snmp:host><ios-snmp:ip-address>10.0.0.1</ios-snmp:ip-address><ios-snmp:community-or-user>&lt;redacted&gt;</ios-snmp:community-or-user></ios-snmp:host>`,
+		},
+		{
+			`<vlan-list><id>10</id><name>users</name></vlan-list>`,
+			`<vlan-list><id>10</id><name>users</name></vlan-list>`,
+		},
+		{"username admin password cisco <", "username admin password <redacted> <"},
+	}
+	for _, tt := range tests {
+		if got := redactXML([]byte(tt.message)); got != tt.want {
+			t.Errorf("redactXML(%s) = %s, want %s", tt.message, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// A cassette holds the commands sent to a device and its responses, recorded
+// from a real session so that the provider can later be run against it
+// without the device. The passwords, secrets and communities are redacted
+// before they are recorded, a cassette can be attached to a bug report.
+//
+// Terraform starts the provider once to plan and again to apply, each process
+// records its own run, appended to the runs already in the cassette.
+type cassette struct {
+	path string
+	mu   sync.Mutex
+	Runs []Run `json:"runs"`
+}
+
+// Run is the session of a provider process.
+type Run struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a command of a cassette.
+type Interaction struct {
+	// Kind is the Conn method called: exec, confirm or configure.
+	Kind     string   `json:"kind"`
+	Commands []string `json:"commands"`
+	Response string   `json:"response,omitempty"`
+	Error    *Failure `json:"error,omitempty"`
+}
+
+// Failure is the error returned by the device to an interaction, with the
+// details the session acts upon.
+type Failure struct {
+	Message string `json:"message"`
+	// Command, Line, Marker and Output are set when the device rejected a
+	// command.
+	Command string `json:"command,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Marker  string `json:"marker,omitempty"`
+	Output  string `json:"output,omitempty"`
+	// Dropped is set when the connection was lost.
+	Dropped bool `json:"dropped,omitempty"`
+}
+
+// CassetteMode tells whether a cassette is recorded or replayed.
+type CassetteMode string
+
+const (
+	// CassetteRecord records the commands sent to the device.
+	CassetteRecord CassetteMode = "record"
+	// CassetteReplay answers the commands from the cassette, no device is
+	// reached.
+	CassetteReplay CassetteMode = "replay"
+)
+
+// WithCassette returns the dialer recording the connections opened by dial to
+// a new run of the cassette at path, or replaying the cassette without calling
+// dial. A recording is appended to the cassette, which is removed to record it
+// again from scratch.
+func WithCassette(path string, mode CassetteMode, dial Dialer) (Dialer, error) {
+	switch mode {
+	case CassetteRecord:
+		c, err := readCassette(path)
+		if errors.Is(err, os.ErrNotExist) {
+			c, err = &cassette{path: path}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.Runs = append(c.Runs, Run{Interactions: []Interaction{}})
+		// The cassette is written up front so that an unwritable path fails
+		// before anything is sent to the device.
+		err = c.save()
+		if err != nil {
+			return nil, err
+		}
+		return func() (Conn, error) {
+			conn, err := dial()
+			if err != nil {
+				return nil, err
+			}
+			return &recorder{conn: conn, cassette: c}, nil
+		}, nil
+	case CassetteReplay, "":
+		c, err := readCassette(path)
+		if err != nil {
+			return nil, err
+		}
+		p := &player{path: path, current: -1, sent: map[string]int{}}
+		for _, run := range c.Runs {
+			queues := map[string][]Interaction{}
+			for _, interaction := range run.Interactions {
+				key := interactionKey(interaction.Kind, interaction.Commands)
+				queues[key] = append(queues[key], interaction)
+			}
+			p.runs = append(p.runs, queues)
+		}
+		return func() (Conn, error) {
+			return &replayer{player: p}, nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown cassette mode %q, expected %q or %q", mode, CassetteRecord, CassetteReplay)
+}
+
+// readCassette reads the cassette at path.
+func readCassette(path string) (*cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the cassette: %w", err)
+	}
+	c := &cassette{path: path}
+	err = json.Unmarshal(data, c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the cassette %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// interactionKey identifies the interactions answering the same commands.
+func interactionKey(kind string, cmds []string) string {
+	return kind + "\x00" + strings.Join(cmds, "\n")
+}
+
+func (c *cassette) add(kind string, cmds []string, response string, err error) {
+	interaction := Interaction{
+		Kind:     kind,
+		Commands: []string{},
+		Response: Redact(response),
+	}
+	for _, cmd := range cmds {
+		interaction.Commands = append(interaction.Commands, Redact(cmd))
+	}
+	if err != nil {
+		interaction.Error = &Failure{
+			Message: Redact(err.Error()),
+			Dropped: isDropped(err),
+		}
+		var cmdErr *CommandError
+		if errors.As(err, &cmdErr) {
+			interaction.Error.Command = Redact(cmdErr.Command)
+			interaction.Error.Line = cmdErr.Line
+			interaction.Error.Marker = cmdErr.Marker
+			interaction.Error.Output = Redact(cmdErr.Output)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	run := &c.Runs[len(c.Runs)-1]
+	run.Interactions = append(run.Interactions, interaction)
+}
+
+// save writes the cassette, it is rewritten whole each time a recorded
+// connection is closed.
+func (c *cassette) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the cassette: %w", err)
+	}
+	err = os.WriteFile(c.path, data, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write the cassette: %w", err)
+	}
+	return nil
+}
+
+// recorder is a connection recording its commands to a cassette.
+type recorder struct {
+	conn     Conn
+	cassette *cassette
+}
+
+func (r *recorder) Exec(cmd ...string) (string, error) {
+	output, err := r.conn.Exec(cmd...)
+	r.cassette.add("exec", cmd, output, err)
+	return output, err
+}
+
+func (r *recorder) Confirm(cmd string) (string, error) {
+	output, err := r.conn.Confirm(cmd)
+	r.cassette.add("confirm", []string{cmd}, output, err)
+	return output, err
+}
+
+func (r *recorder) Configure(cmds []string) error {
+	err := r.conn.Configure(cmds)
+	r.cassette.add("configure", cmds, "", err)
+	return err
+}
+
+func (r *recorder) Close() error {
+	err := r.conn.Close()
+	return errors.Join(err, r.cassette.save())
+}
+
+func (r *recorder) setTrace(trace func(cmd string, output string, err error)) {
+	if t, ok := r.conn.(tracer); ok {
+		t.setTrace(trace)
+	}
+}
+
+// player hands out the interactions of a run of a cassette, in the order they
+// were recorded for each command.
+//
+// The process replaying cannot tell which run it is, the plan or the apply.
+// It follows the first run answering the commands sent so far, and moves on to
+// a later run holding the same commands when the current one has no answer
+// left: a plan and the apply after it send the same commands until the apply
+// changes the device.
+type player struct {
+	path    string
+	mu      sync.Mutex
+	runs    []map[string][]Interaction
+	current int
+	// sent counts the commands answered by the player, by interaction key.
+	sent map[string]int
+}
+
+// answers reports whether run answers the commands sent so far, then key.
+func (p *player) answers(run map[string][]Interaction, key string) bool {
+	for sent, count := range p.sent {
+		if len(run[sent]) < count {
+			return false
+		}
+	}
+	return len(run[key]) > p.sent[key]
+}
+
+func (p *player) next(kind string, cmds []string) (string, error) {
+	redacted := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		redacted[i] = Redact(cmd)
+	}
+	key := interactionKey(kind, redacted)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.current < 0 || !p.answers(p.runs[p.current], key) {
+		next := p.current + 1
+		for next < len(p.runs) && !p.answers(p.runs[next], key) {
+			next++
+		}
+		if next == len(p.runs) {
+			return "", fmt.Errorf("the cassette %s holds no more responses to %s %q", p.path, kind, strings.Join(redacted, "\n"))
+		}
+		p.current = next
+	}
+	interaction := p.runs[p.current][key][p.sent[key]]
+	p.sent[key]++
+	if interaction.Error == nil {
+		return interaction.Response, nil
+	}
+	failure := interaction.Error
+	switch {
+	case failure.Marker != "":
+		return interaction.Response, &CommandError{
+			Command: failure.Command,
+			Line:    failure.Line,
+			Marker:  failure.Marker,
+			Output:  failure.Output,
+		}
+	case failure.Dropped:
+		return interaction.Response, droppedError(failure.Message)
+	}
+	return interaction.Response, errors.New(failure.Message)
+}
+
+// droppedError replays the error of a lost connection, the session handles it
+// as it would ErrClosed.
+type droppedError string
+
+func (e droppedError) Error() string {
+	return string(e)
+}
+
+func (e droppedError) Is(target error) bool {
+	return target == ErrClosed
+}
+
+// replayer is a connection answered by a cassette.
+type replayer struct {
+	player *player
+	trace  func(cmd string, output string, err error)
+}
+
+func (r *replayer) Exec(cmd ...string) (string, error) {
+	output, err := r.player.next("exec", cmd)
+	r.traceCommand(strings.Join(cmd, "\n"), output, err)
+	return output, err
+}
+
+func (r *replayer) Confirm(cmd string) (string, error) {
+	output, err := r.player.next("confirm", []string{cmd})
+	r.traceCommand(cmd, output, err)
+	return output, err
+}
+
+func (r *replayer) Configure(cmds []string) error {
+	_, err := r.player.next("configure", cmds)
+	r.traceCommand(strings.Join(cmds, "\n"), "", err)
+	return err
+}
+
+func (r *replayer) Close() error {
+	return nil
+}
+
+func (r *replayer) setTrace(trace func(cmd string, output string, err error)) {
+	r.trace = trace
+}
+
+func (r *replayer) traceCommand(cmd string, output string, err error) {
+	if r.trace != nil {
+		r.trace(cmd, output, err)
+	}
+}
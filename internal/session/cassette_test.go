@@ -0,0 +1,92 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package session
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"terraform-provider-ios/internal/fakeios"
+)
+
+// cassetteRun sends the same commands to a session, recorded or replayed.
+func cassetteRun(t *testing.T, s *Session) (string, error) {
+	t.Helper()
+	err := s.Configure([]string{"vlan 10", " name users", "!", "username backup secret s3cr3t"})
+	if err != nil {
+		t.Fatalf("Configure() error = %s", err)
+	}
+	output, err := s.Exec("show vlan")
+	if err != nil {
+		t.Fatalf("Exec() error = %s", err)
+	}
+	return output, s.Configure([]string{"vlan 20", " name voice", "!"})
+}
+
+func TestCassette(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	device := fakeios.New("")
+	device.Reject("name voice")
+	server, err := fakeios.Serve(device, "admin", "cisco")
+	if err != nil {
+		t.Fatalf("failed to start the device: %s", err)
+	}
+	defer server.Close()
+
+	dial, err := WithCassette(path, CassetteRecord, func() (Conn, error) {
+		return DialSSH(SSHConfig{Host: server.Host(), Port: server.Port(), Username: "admin", Password: "cisco", InsecureIgnoreHostKey: true})
+	})
+	if err != nil {
+		t.Fatalf("WithCassette(record) error = %s", err)
+	}
+	recorded := New(dial, Options{})
+	recordedOutput, recordedErr := cassetteRun(t, recorded)
+	recorded.close()
+	server.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read the cassette: %s", err)
+	}
+	if strings.Contains(string(data), "s3cr3t") {
+		t.Errorf("the cassette holds the secret:\n%s", data)
+	}
+
+	dial, err = WithCassette(path, CassetteReplay, func() (Conn, error) {
+		t.Fatalf("the device is dialed during the replay")
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("WithCassette(replay) error = %s", err)
+	}
+	replayed := New(dial, Options{})
+	defer replayed.close()
+	output, err := cassetteRun(t, replayed)
+	if output != recordedOutput || !strings.Contains(output, "users") {
+		t.Errorf("replayed output = %q, want %q", output, recordedOutput)
+	}
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Line != 2 || err.Error() != recordedErr.Error() {
+		t.Errorf("replayed error = %v, want the CommandError %v", err, recordedErr)
+	}
+
+	_, err = replayed.Exec("show running-config")
+	if err == nil || !strings.Contains(err.Error(), "no more responses") {
+		t.Errorf("Exec() of a command not recorded error = %v", err)
+	}
+}
+
+func TestCassetteMissing(t *testing.T) {
+	_, err := WithCassette(filepath.Join(t.TempDir(), "missing.json"), CassetteReplay, nil)
+	if err == nil {
+		t.Errorf("WithCassette() replays a missing cassette")
+	}
+	_, err = WithCassette(filepath.Join(t.TempDir(), "cassette.json"), "rewind", nil)
+	if err == nil {
+		t.Errorf("WithCassette() accepts an unknown mode")
+	}
+}
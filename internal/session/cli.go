@@ -0,0 +1,374 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package session
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoPrompt = errors.New("no return of prompt after command")
+	ErrClosed   = errors.New("connection closed by the device")
+
+	// Timeout for waiting for a prompt, the default of each new CLI session.
+	Timeout = time.Second * 30
+	// ConfirmTimeout for waiting for the prompt after a confirmed command,
+	// like copy or configure replace, which write or read the flash and can
+	// take minutes on a large configuration.
+	ConfirmTimeout = time.Minute * 5
+
+	anyPrompt      = regexp.MustCompile(`(?m)^[[:alnum:]._:/\-]+(\([[:alnum:]\-]+\))?[>#]\s*$`)
+	passwordPrompt = regexp.MustCompile(`(?i)password:\s*$`)
+	privilegeLevel = regexp.MustCompile(`Current privilege level is (\d+)`)
+	confirmPrompt  = regexp.MustCompile(`(\[confirm\]|\[[^\]\n]*\]\?)\s*$`)
+	cliErrors      = []string{"% Invalid input", "% Incomplete command", "% Ambiguous command", "% Unknown command", "%Error"}
+	lineEnding     = regexp.MustCompile(`\r+\n`)
+)
+
+// PrivilegeError is returned when the session cannot reach privilege level
+// 15, which is needed to read the running-config and enter configuration mode.
+type PrivilegeError struct {
+	Level  int
+	Reason string
+}
+
+func (e *PrivilegeError) Error() string {
+	return fmt.Sprintf("session is at privilege level %d: %s", e.Level, e.Reason)
+}
+
+// CommandError is returned when the device rejects a command with one of the
+// IOS error markers.
+type CommandError struct {
+	Command string
+	// Line is the position of the command in the pushed configuration,
+	// starting at 1, or 0 for an exec command.
+	Line   int
+	Marker string
+	Output string
+}
+
+func (e *CommandError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d %q: %s", e.Line, e.Command, e.Marker)
+	}
+	return fmt.Sprintf("%q: %s", e.Command, e.Marker)
+}
+
+// Conn is an interactive CLI channel opened to a device.
+type Conn interface {
+	Exec(cmd ...string) (string, error)
+	Confirm(cmd string) (string, error)
+	Configure(cmds []string) error
+	Close() error
+}
+
+// cli drives the IOS command line over any stream, the transport only has to
+// provide the input and output of the terminal.
+type cli struct {
+	stdin          io.Writer
+	output         chan []byte
+	buf            bytes.Buffer
+	prompt         *regexp.Regexp
+	newline        string
+	timeout        time.Duration
+	confirmTimeout time.Duration
+	closer         io.Closer
+	trace          func(cmd string, output string, err error)
+}
+
+func newCli(stdin io.Writer, stdout io.Reader, closer io.Closer) *cli {
+	c := &cli{
+		stdin:          stdin,
+		output:         make(chan []byte, 64),
+		prompt:         anyPrompt,
+		newline:        "\n",
+		timeout:        Timeout,
+		confirmTimeout: ConfirmTimeout,
+		closer:         closer,
+	}
+	go c.reader(stdout)
+	return c
+}
+
+func (c *cli) reader(stdout io.Reader) {
+	defer close(c.output)
+	buf := make([]byte, 4096)
+	for {
+		n, err := stdout.Read(buf)
+		if n > 0 {
+			chunk := make([]byte, n)
+			copy(chunk, buf[:n])
+			c.output <- chunk
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// readUntil consumes the output of the device until pattern matches, and
+// returns everything read so far.
+func (c *cli) readUntil(patterns ...*regexp.Regexp) (string, int, error) {
+	return c.readWithin(c.timeout, patterns...)
+}
+
+// readWithin is readUntil giving up after timeout.
+func (c *cli) readWithin(timeout time.Duration, patterns ...*regexp.Regexp) (string, int, error) {
+	deadline := time.After(timeout)
+	for {
+		text := lineEnding.ReplaceAllString(c.buf.String(), "\n")
+		for i, pattern := range patterns {
+			if pattern.MatchString(text) {
+				c.buf.Reset()
+				return text, i, nil
+			}
+		}
+		select {
+		case chunk, ok := <-c.output:
+			if !ok {
+				return text, -1, ErrClosed
+			}
+			c.buf.Write(chunk)
+		case <-deadline:
+			return text, -1, ErrNoPrompt
+		}
+	}
+}
+
+func (c *cli) write(line string) error {
+	_, err := io.WriteString(c.stdin, line+c.newline)
+	return err
+}
+
+// start waits for the first prompt, locks the prompt pattern on the hostname
+// of the device and prepares the session.
+func (c *cli) start(enable string) error {
+	text, _, err := c.readUntil(anyPrompt)
+	if err != nil {
+		return err
+	}
+	c.learnPrompt(text)
+	return c.setup(enable)
+}
+
+// setup escalates to privilege level 15 with the enable secret when the login
+// lands at a lower level, and disables paging.
+func (c *cli) setup(enable string) error {
+	err := c.escalate(enable)
+	if err != nil {
+		return err
+	}
+	_, err = c.Exec("terminal length 0")
+	if err != nil {
+		return err
+	}
+	_, err = c.Exec("terminal width 0")
+	return err
+}
+
+func (c *cli) learnPrompt(text string) {
+	matches := anyPrompt.FindAllString(text, -1)
+	if len(matches) == 0 {
+		return
+	}
+	hostname := strings.TrimSpace(matches[len(matches)-1])
+	hostname = strings.TrimRight(hostname, ">#")
+	if i := strings.Index(hostname, "("); i >= 0 {
+		hostname = hostname[:i]
+	}
+	c.prompt = regexp.MustCompile(`(?m)^` + regexp.QuoteMeta(hostname) + `(\([[:alnum:]\-]+\))?[>#]\s*$`)
+}
+
+func (c *cli) privilege() (int, error) {
+	output, err := c.Exec("show privilege")
+	if err != nil {
+		return 0, fmt.Errorf("failed to detect privilege level: %w", err)
+	}
+	match := privilegeLevel.FindStringSubmatch(output)
+	if match == nil {
+		return 0, fmt.Errorf("failed to detect privilege level from %q", output)
+	}
+	return strconv.Atoi(match[1])
+}
+
+func (c *cli) escalate(enable string) error {
+	level, err := c.privilege()
+	if err != nil {
+		return err
+	}
+	if level == 15 {
+		return nil
+	}
+	if enable == "" {
+		return &PrivilegeError{Level: level, Reason: "no enable password is set to escalate to level 15"}
+	}
+
+	err = c.write("enable")
+	if err != nil {
+		return err
+	}
+	// IOS asks up to three times for the secret, the retries are answered
+	// empty so that a wrong secret falls back to the prompt.
+	secret := enable
+	for {
+		_, match, err := c.readUntil(passwordPrompt, c.prompt)
+		if err != nil {
+			return err
+		}
+		if match == 1 {
+			break
+		}
+		err = c.write(secret)
+		if err != nil {
+			return err
+		}
+		secret = ""
+	}
+
+	level, err = c.privilege()
+	if err != nil {
+		return err
+	}
+	if level != 15 {
+		return &PrivilegeError{Level: level, Reason: "the enable password was rejected"}
+	}
+	return nil
+}
+
+func (c *cli) Exec(cmd ...string) (string, error) {
+	command := strings.Join(cmd, "")
+	output, err := c.exec(command)
+	c.traceCommand(command, output, err)
+	return output, err
+}
+
+func (c *cli) exec(command string) (string, error) {
+	err := c.write(command)
+	if err != nil {
+		return "", err
+	}
+	// The prompt follows the hostname as soon as it is configured.
+	renamed := strings.HasPrefix(command, "hostname ")
+	prompt := c.prompt
+	if renamed {
+		prompt = anyPrompt
+	}
+	text, _, err := c.readUntil(prompt)
+	if err != nil {
+		return "", err
+	}
+	if renamed {
+		c.learnPrompt(text)
+	}
+	output := cleanOutput(text, command, c.prompt)
+	return output, checkOutput(command, output)
+}
+
+// Confirm runs a command asking questions before acting, like copy or
+// delete, and accepts the default answer of each question.
+func (c *cli) Confirm(cmd string) (string, error) {
+	output, err := c.confirm(cmd)
+	c.traceCommand(cmd, output, err)
+	return output, err
+}
+
+func (c *cli) confirm(cmd string) (string, error) {
+	err := c.write(cmd)
+	if err != nil {
+		return "", err
+	}
+	var text strings.Builder
+	for {
+		chunk, match, err := c.readWithin(c.confirmTimeout, c.prompt, confirmPrompt)
+		if err != nil {
+			return "", err
+		}
+		text.WriteString(chunk)
+		if match == 0 {
+			break
+		}
+		err = c.write("")
+		if err != nil {
+			return "", err
+		}
+	}
+	output := cleanOutput(text.String(), cmd, c.prompt)
+	return output, checkOutput(cmd, output)
+}
+
+// checkOutput returns a CommandError when the output of command holds one of
+// the IOS error markers.
+func checkOutput(command string, output string) error {
+	for _, line := range strings.Split(output, "\n") {
+		for _, marker := range cliErrors {
+			if strings.HasPrefix(strings.TrimSpace(line), marker) {
+				return &CommandError{Command: command, Marker: strings.TrimSpace(line), Output: output}
+			}
+		}
+	}
+	return nil
+}
+
+func (c *cli) Configure(cmds []string) error {
+	_, err := c.Exec("configure terminal")
+	if err != nil {
+		return err
+	}
+
+	for i, cmd := range cmds {
+		if strings.TrimSpace(cmd) == "" {
+			continue
+		}
+		_, err = c.Exec(cmd)
+		if err != nil {
+			var cmdErr *CommandError
+			if errors.As(err, &cmdErr) {
+				cmdErr.Line = i + 1
+			} else {
+				err = fmt.Errorf("error on command %s, aborting: %w", cmd, err)
+			}
+			break
+		}
+	}
+
+	_, endErr := c.Exec("end")
+	if err != nil {
+		return err
+	}
+	return endErr
+}
+
+func (c *cli) setTrace(trace func(cmd string, output string, err error)) {
+	c.trace = trace
+}
+
+func (c *cli) traceCommand(cmd string, output string, err error) {
+	if c.trace != nil {
+		c.trace(cmd, output, err)
+	}
+}
+
+func (c *cli) Close() error {
+	return c.closer.Close()
+}
+
+// cleanOutput removes the echoed command and the trailing prompt from the
+// output of a command.
+func cleanOutput(text string, command string, prompt *regexp.Regexp) string {
+	lines := strings.Split(text, "\n")
+	if len(lines) > 0 && strings.Contains(lines[0], command) {
+		lines = lines[1:]
+	}
+	if len(lines) > 0 && prompt.MatchString(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
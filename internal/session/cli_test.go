@@ -0,0 +1,255 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package session
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"terraform-provider-ios/internal/fakeios"
+)
+
+func TestCliErrors(t *testing.T) {
+	tests := []struct {
+		marker  string
+		message string
+	}{
+		{"% Invalid input", ""},
+		{"% Incomplete command", "% Incomplete command."},
+		{"% Ambiguous command", `% Ambiguous command:  "vlan 10"`},
+		{"% Unknown command", "% Unknown command or computer name, or unable to find computer address"},
+		{"%Error", "%Error opening flash:vlan.dat (Permission denied)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.marker, func(t *testing.T) {
+			s, server := newTestSession(t, Options{})
+			server.Device.RejectWith("vlan 10", tt.message)
+			server.Device.RejectWith("show vlan", tt.message)
+
+			err := s.Configure([]string{"vlan 20", "vlan 10", "vlan 30"})
+			var cmdErr *CommandError
+			if !errors.As(err, &cmdErr) {
+				t.Fatalf("Configure() error = %v, want a CommandError", err)
+			}
+			if !strings.HasPrefix(cmdErr.Marker, tt.marker) || cmdErr.Command != "vlan 10" || cmdErr.Line != 2 {
+				t.Errorf("Configure() error = %+v, want %q on line 2", cmdErr, tt.marker)
+			}
+			// The push stops at the rejected line.
+			if config := server.Device.RunningConfig(); !strings.Contains(config, "vlan 20\n") || strings.Contains(config, "vlan 30") {
+				t.Errorf("running-config after the rejected push:\n%s", config)
+			}
+
+			_, err = s.Exec("show vlan")
+			if !errors.As(err, &cmdErr) || !strings.HasPrefix(cmdErr.Marker, tt.marker) || cmdErr.Line != 0 {
+				t.Errorf("Exec() error = %v, want %q", err, tt.marker)
+			}
+		})
+	}
+}
+
+func TestCliEnable(t *testing.T) {
+	device := fakeios.New("")
+	device.EnableSecret = "enable"
+	server, err := fakeios.Serve(device, "admin", "cisco")
+	if err != nil {
+		t.Fatalf("failed to start the device: %s", err)
+	}
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		enable string
+		// reason is the PrivilegeError expected, empty when the session
+		// reaches level 15.
+		reason string
+	}{
+		{"escalated", "enable", ""},
+		{"wrong enable password", "wrong", "the enable password was rejected"},
+		{"no enable password", "", "no enable password is set to escalate to level 15"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := DialSSH(SSHConfig{
+				Host:                  server.Host(),
+				Port:                  server.Port(),
+				Username:              "admin",
+				Password:              "cisco",
+				EnablePassword:        tt.enable,
+				InsecureIgnoreHostKey: true,
+			})
+			if tt.reason == "" {
+				if err != nil {
+					t.Fatalf("DialSSH() error = %s", err)
+				}
+				defer conn.Close()
+				output, err := conn.Exec("show privilege")
+				if err != nil || output != "Current privilege level is 15" {
+					t.Errorf("show privilege = %q, %v, want level 15", output, err)
+				}
+				return
+			}
+			var privErr *PrivilegeError
+			if !errors.As(err, &privErr) {
+				t.Fatalf("DialSSH() error = %v, want a PrivilegeError", err)
+			}
+			if privErr.Level != 1 || privErr.Reason != tt.reason {
+				t.Errorf("DialSSH() error = %+v, want level 1: %s", privErr, tt.reason)
+			}
+		})
+	}
+}
+
+// scriptedCli returns a CLI session to a device printing banner, then
+// answering each line with the chunks reply returns for it, one write each.
+// The device closes the stream on exit.
+func scriptedCli(t *testing.T, banner string, reply func(line string) []string) *cli {
+	t.Helper()
+	inRead, inWrite := io.Pipe()
+	outRead, outWrite := io.Pipe()
+	c := newCli(inWrite, outRead, inWrite)
+	go func() {
+		defer outWrite.Close()
+		io.WriteString(outWrite, banner)
+		scanner := bufio.NewScanner(inRead)
+		for scanner.Scan() {
+			if scanner.Text() == "exit" {
+				return
+			}
+			for _, chunk := range reply(scanner.Text()) {
+				if _, err := io.WriteString(outWrite, chunk); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		c.Close()
+	})
+	return c
+}
+
+// privileged answers the commands run by the setup of a session at level 15
+// with the prompt, and the others with reply.
+func privileged(prompt string, reply func(line string) []string) func(line string) []string {
+	return func(line string) []string {
+		switch line {
+		case "show privilege":
+			return []string{line + "\r\nCurrent privilege level is 15\r\n" + prompt}
+		case "terminal length 0", "terminal width 0":
+			return []string{line + "\r\n" + prompt}
+		}
+		return reply(line)
+	}
+}
+
+func TestCliPrompt(t *testing.T) {
+	c := scriptedCli(t, "\r\nUser Access Verification\r\n\r\nCore-1#", privileged("Core-1#", func(line string) []string {
+		switch line {
+		case "show banner motd":
+			// A line of the output looking like the prompt of another
+			// device does not end the output.
+			return []string{line + "\r\nAccess-Switch#\r\n", "authorized access only\r\nCore-1#"}
+		case "configure terminal":
+			return []string{line + "\r\nEnter configuration commands, one per line.  End with CNTL/Z.\r\nCore-1(config)#"}
+		case "interface GigabitEthernet0/1":
+			return []string{line + "\r\nCore-1(config-if)#"}
+		case "end":
+			return []string{line + "\r\nCore-1#"}
+		}
+		return []string{line + "\r\n% Invalid input detected at '^' marker.\r\nCore-1#"}
+	}))
+	if err := c.start(""); err != nil {
+		t.Fatalf("start() error = %s", err)
+	}
+	if c.prompt.String() != `(?m)^Core-1(\([[:alnum:]\-]+\))?[>#]\s*$` {
+		t.Errorf("the prompt learnt is %s", c.prompt)
+	}
+
+	output, err := c.Exec("show banner motd")
+	if err != nil || output != "Access-Switch#\nauthorized access only" {
+		t.Errorf("Exec() = %q, %v", output, err)
+	}
+	// The prompts of the configuration modes keep the hostname.
+	if err := c.Configure([]string{"interface GigabitEthernet0/1"}); err != nil {
+		t.Errorf("Configure() error = %s", err)
+	}
+}
+
+func TestCliHostname(t *testing.T) {
+	s, server := newTestSession(t, Options{})
+
+	err := s.Configure([]string{"hostname Core-1"})
+	if err != nil {
+		t.Fatalf("Configure() error = %s", err)
+	}
+	// The session follows the prompt of the new hostname.
+	output, err := s.Exec("show running-config")
+	if err != nil || !strings.Contains(output, "hostname Core-1\n") {
+		t.Errorf("Exec() = %q, %v, want the new hostname", output, err)
+	}
+	if !strings.Contains(server.Device.RunningConfig(), "hostname Core-1\n") {
+		t.Errorf("the hostname is missing from the running-config:\n%s", server.Device.RunningConfig())
+	}
+}
+
+func TestCliTimeout(t *testing.T) {
+	c := scriptedCli(t, "Switch>", privileged("Switch>", func(line string) []string {
+		if line == "show tech-support" {
+			// The device prints part of the output, then hangs.
+			return []string{line + "\r\n------------------ show version ------------------\r\n"}
+		}
+		return []string{line + "\r\nSwitch>"}
+	}))
+	if err := c.start(""); err != nil {
+		t.Fatalf("start() error = %s", err)
+	}
+
+	c.timeout = 50 * time.Millisecond
+	start := time.Now()
+	_, err := c.Exec("show tech-support")
+	if !errors.Is(err, ErrNoPrompt) {
+		t.Errorf("Exec() error = %v, want %v", err, ErrNoPrompt)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Exec() gave up after %s, want %s", elapsed, c.timeout)
+	}
+
+	// A device closing the session is reported at once.
+	_, err = c.Exec("exit")
+	if !errors.Is(err, ErrClosed) {
+		t.Errorf("Exec() error = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestCliConfirmTimeout(t *testing.T) {
+	c := scriptedCli(t, "Switch#", privileged("Switch#", func(line string) []string {
+		switch line {
+		case "configure replace flash:terraform-rollback.cfg force":
+			// The device works on the command longer than the timeout of
+			// the session.
+			time.Sleep(200 * time.Millisecond)
+			return []string{line + "\r\nTotal number of passes: 1\r\nRollback Done\r\n\r\nSwitch#"}
+		}
+		return []string{line + "\r\nSwitch#"}
+	}))
+	if err := c.start(""); err != nil {
+		t.Fatalf("start() error = %s", err)
+	}
+
+	c.timeout = 50 * time.Millisecond
+	output, err := c.Confirm("configure replace flash:terraform-rollback.cfg force")
+	if err != nil || !strings.Contains(output, "Rollback Done") {
+		t.Errorf("Confirm() = %q, %v, want the replace waited for", output, err)
+	}
+
+	c.confirmTimeout = 50 * time.Millisecond
+	_, err = c.Confirm("configure replace flash:terraform-rollback.cfg force")
+	if !errors.Is(err, ErrNoPrompt) {
+		t.Errorf("Confirm() error = %v, want %v", err, ErrNoPrompt)
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package session
+
+import (
+	"errors"
+	"fmt"
+	"github.com/CorentinPtrl/cisconf"
+	"terraform-provider-ios/internal/native"
+)
+
+// Datastore is the configuration of a device reached through a model-driven
+// interface, such as RESTCONF, instead of the CLI. It is edited with patches
+// of the native data tree rather than commands.
+type Datastore interface {
+	// Config returns the running configuration.
+	Config() (*cisconf.Config, error)
+	// Edit applies the patch to the running configuration.
+	Edit(patch native.Patch) error
+	// Save copies the running configuration to the startup configuration.
+	Save() (string, error)
+	Close() error
+	// String names the transport in the errors.
+	String() string
+}
+
+// NewDatastore creates a session editing the configuration of the device
+// through store. The commands of the CLI fail with errors.ErrUnsupported.
+func NewDatastore(store Datastore, options Options) *Session {
+	s := New(func() (Conn, error) {
+		return nil, unsupported(store, "the CLI")
+	}, options)
+	s.store = store
+	return s
+}
+
+// unsupported returns the error of an operation the datastore cannot run.
+func unsupported(store Datastore, operation string) error {
+	return fmt.Errorf("%w: the %s transport cannot run %s", errors.ErrUnsupported, store, operation)
+}
+
+// Structured reports whether the session edits a datastore with Edit, rather
+// than sending commands.
+func (s *Session) Structured() bool {
+	return s.store != nil
+}
+
+// tracedStore makes the datastore report the requests it sends to the
+// session, it must be called with the cli lock held.
+func (s *Session) tracedStore() Datastore {
+	if t, ok := s.store.(tracer); ok {
+		t.setTrace(s.record)
+	}
+	return s.store
+}
+
+// Edit applies the patch turning src into dest, as returned by change which
+// reads the current object from the running-config. src is nil when the
+// object does not exist yet. When the connection is dropped, change runs
+// again against a fresh running-config before the patch is replayed.
+func (s *Session) Edit(change func() (src any, dest any, err error)) error {
+	return s.change(func() error {
+		interval := s.retryInterval
+		for attempt := 0; ; attempt++ {
+			src, dest, err := change()
+			if err != nil {
+				return err
+			}
+			patch, err := native.Diff(src, dest)
+			if err != nil {
+				return err
+			}
+			err = s.edit(patch)
+			if err == nil || !isDropped(err) || attempt >= s.retryMax {
+				return err
+			}
+			if waitErr := s.wait(interval); waitErr != nil {
+				return fmt.Errorf("%w, retrying was cancelled: %w", err, waitErr)
+			}
+			interval *= 2
+		}
+	}, nil)
+}
+
+// Remove deletes obj from the configuration of the device, through the
+// datastore when there is one, or with the commands cmds.
+func (s *Session) Remove(obj any, cmds []string) error {
+	if s.store == nil {
+		return s.Configure(cmds)
+	}
+	patch, err := native.Remove(obj)
+	if err != nil {
+		return err
+	}
+	return s.change(func() error {
+		return s.edit(patch)
+	}, nil)
+}
+
+func (s *Session) edit(patch native.Patch) error {
+	if s.store == nil {
+		return errors.New("the session edits the device with commands, not patches")
+	}
+	if patch.IsEmpty() {
+		return nil
+	}
+	defer s.Invalidate()
+	s.cli.Lock()
+	defer s.cli.Unlock()
+	return s.tracedStore().Edit(patch)
+}
+
+// storeConfig reads the running configuration from the datastore.
+func (s *Session) storeConfig() (*cisconf.Config, error) {
+	var config *cisconf.Config
+	err := s.retry(func() error {
+		s.cli.Lock()
+		defer s.cli.Unlock()
+		var err error
+		config, err = s.tracedStore().Config()
+		return err
+	})
+	return config, err
+}
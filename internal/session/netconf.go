@@ -0,0 +1,400 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/CorentinPtrl/cisconf"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"terraform-provider-ios/internal/native"
+	"time"
+)
+
+const (
+	netconfBase      = "urn:ietf:params:xml:ns:netconf:base:1.0"
+	netconfBase11    = "urn:ietf:params:netconf:base:1.1"
+	netconfCandidate = "urn:ietf:params:netconf:capability:candidate:1.0"
+	netconfValidate  = "urn:ietf:params:netconf:capability:validate:"
+	// endOfMessage delimits the messages of NETCONF 1.0, and the hellos.
+	endOfMessage = "]]>]]>"
+)
+
+// netconf is a datastore edited through the NETCONF server of the device over
+// SSH, see RFC 6241. When the device has a candidate datastore, every edit is
+// validated then committed as a single transaction.
+type netconf struct {
+	config SSHConfig
+	trace  func(cmd string, output string, err error)
+
+	// The connection, opened by the first rpc and dropped on any transport
+	// error.
+	closer    io.Closer
+	stdin     io.Writer
+	messages  chan netconfMessage
+	chunked   bool
+	candidate bool
+	validate  bool
+	messageID int
+}
+
+// netconfMessage is a message read from the server, or the error that ended
+// the connection.
+type netconfMessage struct {
+	data []byte
+	err  error
+}
+
+// NetconfError is an rpc-error returned by the NETCONF server.
+type NetconfError struct {
+	Type     string `xml:"error-type"`
+	Tag      string `xml:"error-tag"`
+	Severity string `xml:"error-severity"`
+	Message  string `xml:"error-message"`
+	Path     string `xml:"error-path"`
+}
+
+func (e *NetconfError) Error() string {
+	text := "netconf rpc failed"
+	if e.Tag != "" {
+		text += ": " + e.Tag
+	}
+	if message := strings.TrimSpace(e.Message); message != "" {
+		text += ": " + message
+	}
+	if path := strings.TrimSpace(e.Path); path != "" {
+		text += " at " + path
+	}
+	return text
+}
+
+// rpcReply is the rpc-reply of the server, holding either the data of a
+// get-config, the result of an operation or its errors.
+type rpcReply struct {
+	MessageID string         `xml:"message-id,attr"`
+	Errors    []NetconfError `xml:"rpc-error"`
+	Data      *struct {
+		Inner []byte `xml:",innerxml"`
+	} `xml:"data"`
+	Result string `xml:"result"`
+}
+
+// DialNetconf returns the NETCONF datastore of the device reached with
+// config. The SSH connection is opened by the first request.
+func DialNetconf(config SSHConfig) Datastore {
+	return &netconf{config: config}
+}
+
+func (n *netconf) String() string {
+	return "netconf"
+}
+
+func (n *netconf) Config() (*cisconf.Config, error) {
+	filter := `<native xmlns="` + native.Namespace(native.Module) + `"/>`
+	reply, err := n.rpc(`<get-config><source><running/></source><filter type="subtree">` + filter + `</filter></get-config>`)
+	if err != nil {
+		return nil, err
+	}
+	data := filter
+	if reply.Data != nil && len(bytes.TrimSpace(reply.Data.Inner)) > 0 {
+		data = string(reply.Data.Inner)
+	}
+	tree, err := native.DecodeXML([]byte(data))
+	if err != nil {
+		return nil, err
+	}
+	return native.Config(tree)
+}
+
+// Edit applies the patch to the candidate datastore, validates and commits
+// it, so that the whole patch lands or none of it does. The candidate is
+// locked for the duration of the edit and its changes are discarded when one
+// of the steps fails. Without a candidate datastore, the patch is applied to
+// the running datastore with rollback-on-error.
+//
+// Each call is its own transaction. The session edits once per resource, so
+// an apply is committed resource by resource and a failure only reverts the
+// resource that failed.
+func (n *netconf) Edit(patch native.Patch) error {
+	config := "<config>" + string(native.EncodeEditXML(patch)) + "</config>"
+	err := n.connect()
+	if err != nil {
+		return err
+	}
+	if !n.candidate {
+		_, err := n.rpc(`<edit-config><target><running/></target><error-option>rollback-on-error</error-option>` + config + `</edit-config>`)
+		return err
+	}
+
+	_, err = n.rpc(`<lock><target><candidate/></target></lock>`)
+	if err != nil {
+		return err
+	}
+	err = n.commit(config)
+	if err != nil {
+		// A dropped session releases its lock and discards its changes.
+		if !isDropped(err) {
+			n.rpc(`<discard-changes/>`)
+			n.rpc(`<unlock><target><candidate/></target></unlock>`)
+		}
+		return err
+	}
+	_, err = n.rpc(`<unlock><target><candidate/></target></unlock>`)
+	return err
+}
+
+// commit edits the locked candidate datastore with config, validates it when
+// the device supports it, and commits it to the running datastore.
+func (n *netconf) commit(config string) error {
+	_, err := n.rpc(`<edit-config><target><candidate/></target>` + config + `</edit-config>`)
+	if err != nil {
+		return err
+	}
+	if n.validate {
+		_, err = n.rpc(`<validate><source><candidate/></source></validate>`)
+		if err != nil {
+			return fmt.Errorf("the candidate configuration is invalid: %w", err)
+		}
+	}
+	_, err = n.rpc(`<commit/>`)
+	return err
+}
+
+func (n *netconf) Save() (string, error) {
+	reply, err := n.rpc(`<save-config xmlns="http://cisco.com/yang/cisco-ia"/>`)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(reply.Result), nil
+}
+
+func (n *netconf) Close() error {
+	if n.closer == nil {
+		return nil
+	}
+	n.rpc(`<close-session/>`)
+	return n.drop()
+}
+
+func (n *netconf) setTrace(trace func(cmd string, output string, err error)) {
+	n.trace = trace
+}
+
+// connect opens the netconf subsystem of the device and exchanges the hellos,
+// unless the connection is already open.
+func (n *netconf) connect() error {
+	if n.closer != nil {
+		return nil
+	}
+	client, closer, err := n.config.dial()
+	if err != nil {
+		return err
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		closer.Close()
+		return err
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		closer.Close()
+		return err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		closer.Close()
+		return err
+	}
+	err = session.RequestSubsystem("netconf")
+	if err != nil {
+		closer.Close()
+		return fmt.Errorf("the device has no netconf subsystem: %w", err)
+	}
+
+	hello := `<hello xmlns="` + netconfBase + `"><capabilities>` +
+		`<capability>urn:ietf:params:netconf:base:1.0</capability>` +
+		`<capability>` + netconfBase11 + `</capability>` +
+		`</capabilities></hello>` + endOfMessage
+	_, err = io.WriteString(stdin, hello)
+	if err != nil {
+		closer.Close()
+		return err
+	}
+	reader := bufio.NewReader(stdout)
+	received := make(chan netconfMessage, 1)
+	go func() {
+		data, err := readMessage(reader, false)
+		received <- netconfMessage{data: data, err: err}
+	}()
+	var message netconfMessage
+	select {
+	case message = <-received:
+	case <-time.After(Timeout):
+		message.err = fmt.Errorf("no hello from the netconf server within %s: %w", Timeout, os.ErrDeadlineExceeded)
+	}
+	if message.err != nil {
+		closer.Close()
+		return message.err
+	}
+	var capabilities struct {
+		Capability []string `xml:"capabilities>capability"`
+	}
+	err = xml.Unmarshal(message.data, &capabilities)
+	if err != nil {
+		closer.Close()
+		return fmt.Errorf("failed to decode the hello of the netconf server: %w", err)
+	}
+
+	n.chunked, n.candidate, n.validate = false, false, false
+	for _, capability := range capabilities.Capability {
+		capability = strings.TrimSpace(capability)
+		switch {
+		case capability == netconfBase11:
+			n.chunked = true
+		case strings.HasPrefix(capability, netconfCandidate):
+			n.candidate = true
+		case strings.HasPrefix(capability, netconfValidate):
+			n.validate = true
+		}
+	}
+	n.closer = closer
+	n.stdin = stdin
+	// Room for a late reply and the error ending the connection, so that the
+	// reader never blocks once the connection is dropped.
+	n.messages = make(chan netconfMessage, 2)
+	go n.reader(reader, n.chunked, n.messages)
+	return nil
+}
+
+// reader reads the messages of the server until the connection ends.
+func (n *netconf) reader(r *bufio.Reader, chunked bool, messages chan<- netconfMessage) {
+	defer close(messages)
+	for {
+		data, err := readMessage(r, chunked)
+		messages <- netconfMessage{data: data, err: err}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// drop closes the connection, the next rpc opens a new one.
+func (n *netconf) drop() error {
+	if n.closer == nil {
+		return nil
+	}
+	err := n.closer.Close()
+	n.closer = nil
+	n.stdin = nil
+	n.messages = nil
+	return err
+}
+
+// rpc sends the operation and returns the reply of the server, or the first
+// error of the reply. The messages are traced with their secrets redacted.
+func (n *netconf) rpc(operation string) (*rpcReply, error) {
+	err := n.connect()
+	if err != nil {
+		return nil, err
+	}
+	n.messageID++
+	id := strconv.Itoa(n.messageID)
+	request := `<rpc message-id="` + id + `" xmlns="` + netconfBase + `">` + operation + `</rpc>`
+	data, err := n.send(request)
+	if err != nil {
+		n.drop()
+	}
+	if n.trace != nil {
+		n.trace(redactXML([]byte(operation)), redactXML(data), err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	reply := &rpcReply{}
+	err = xml.Unmarshal(data, reply)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the reply of the netconf server: %w", err)
+	}
+	if reply.MessageID != id {
+		n.drop()
+		return nil, fmt.Errorf("the netconf server replied to message %s instead of %s", reply.MessageID, id)
+	}
+	for i := range reply.Errors {
+		// Warnings do not fail the operation.
+		if reply.Errors[i].Severity != "warning" {
+			return reply, &reply.Errors[i]
+		}
+	}
+	return reply, nil
+}
+
+// send writes a message and waits for the next one from the server.
+func (n *netconf) send(request string) ([]byte, error) {
+	var err error
+	if n.chunked {
+		_, err = fmt.Fprintf(n.stdin, "\n#%d\n%s\n##\n", len(request), request)
+	} else {
+		_, err = io.WriteString(n.stdin, request+endOfMessage)
+	}
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case message, ok := <-n.messages:
+		if !ok {
+			return nil, ErrClosed
+		}
+		return message.data, message.err
+	case <-time.After(Timeout):
+		return nil, fmt.Errorf("no reply from the netconf server within %s: %w", Timeout, os.ErrDeadlineExceeded)
+	}
+}
+
+// readMessage reads a message framed with the chunks of NETCONF 1.1, or ended
+// by the end-of-message marker of NETCONF 1.0.
+func readMessage(r *bufio.Reader, chunked bool) ([]byte, error) {
+	var message bytes.Buffer
+	if !chunked {
+		for {
+			part, err := r.ReadBytes('>')
+			message.Write(part)
+			if err != nil {
+				return nil, err
+			}
+			if bytes.HasSuffix(message.Bytes(), []byte(endOfMessage)) {
+				return bytes.TrimSuffix(message.Bytes(), []byte(endOfMessage)), nil
+			}
+		}
+	}
+	for {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if header == "\n" {
+			// The newline starting the chunk, its size follows.
+			header, err = r.ReadString('\n')
+			if err != nil {
+				return nil, err
+			}
+		}
+		if header == "##\n" {
+			return message.Bytes(), nil
+		}
+		size, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(header, "#"), "\n"))
+		if err != nil || !strings.HasPrefix(header, "#") || size <= 0 {
+			return nil, fmt.Errorf("invalid chunk header %q", header)
+		}
+		_, err = io.CopyN(&message, r, int64(size))
+		if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,354 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package session
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/CorentinPtrl/cisconf"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"terraform-provider-ios/internal/native"
+)
+
+// RestconfConfig holds the settings of a RESTCONF connection to an IOS-XE
+// device.
+type RestconfConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	// CACert is the PEM bundle of the authorities trusted to sign the
+	// certificate of the device, the system pool when empty.
+	CACert []byte
+	// Insecure skips the verification of the certificate of the device.
+	Insecure bool
+}
+
+const (
+	yangData  = "application/yang-data+json"
+	yangPatch = "application/yang-patch+json"
+	// yangPatchCapability is advertised by the servers accepting YANG-Patch
+	// edits, see RFC 8072.
+	yangPatchCapability = "urn:ietf:params:restconf:capability:yang-patch:1.0"
+)
+
+// restconf is a datastore edited through the RESTCONF API of the device, see
+// RFC 8040.
+type restconf struct {
+	base     string
+	username string
+	password string
+	client   *http.Client
+	trace    func(cmd string, output string, err error)
+	// atomic is set once the server is known to accept YANG-Patch, nil
+	// until its capabilities are read.
+	atomic *bool
+}
+
+// RestconfError is an error returned by the RESTCONF server.
+type RestconfError struct {
+	Status  int
+	Tag     string
+	Message string
+	Path    string
+}
+
+func (e *RestconfError) Error() string {
+	text := fmt.Sprintf("restconf request failed with status %d", e.Status)
+	if e.Tag != "" {
+		text += ": " + e.Tag
+	}
+	if e.Message != "" {
+		text += ": " + e.Message
+	}
+	if e.Path != "" {
+		text += " at " + e.Path
+	}
+	return text
+}
+
+// DialRestconf returns the RESTCONF datastore of the device. No request is
+// sent until the configuration is read or edited.
+func DialRestconf(config RestconfConfig) (Datastore, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.Insecure}
+	if len(config.CACert) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(config.CACert) {
+			return nil, errors.New("the CA certificate holds no PEM certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	port := config.Port
+	if port == "" {
+		port = "443"
+	}
+	return &restconf{
+		base:     "https://" + net.JoinHostPort(config.Host, port) + "/restconf",
+		username: config.Username,
+		password: config.Password,
+		client: &http.Client{
+			Timeout:   Timeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+func (r *restconf) String() string {
+	return "restconf"
+}
+
+func (r *restconf) Config() (*cisconf.Config, error) {
+	data, err := r.do(http.MethodGet, "/data/"+native.Module+":native", yangData, nil)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := native.DecodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return native.Config(tree)
+}
+
+// Edit applies the patch as a single YANG-Patch when the server accepts it,
+// so that the whole patch lands or none of it does.
+//
+// Other servers are sent the merge, the deletions and the resets as separate
+// requests. Those are not atomic: when a request fails, the requests before it
+// stay applied and the next plan shows what is left to change.
+func (r *restconf) Edit(patch native.Patch) error {
+	atomic, err := r.yangPatch()
+	if err != nil {
+		return err
+	}
+	if atomic {
+		body, err := encodeYangPatch(patch)
+		if err != nil {
+			return err
+		}
+		_, err = r.do(http.MethodPatch, "/data/"+native.Module+":native", yangPatch, body)
+		return err
+	}
+
+	if patch.Merge != nil {
+		body, err := native.EncodeJSON(native.Module, patch.Merge)
+		if err != nil {
+			return err
+		}
+		_, err = r.do(http.MethodPatch, "/data/"+native.Path{patch.Merge}.String(), yangData, body)
+		if err != nil {
+			return err
+		}
+	}
+	for _, path := range patch.Delete {
+		_, err := r.do(http.MethodDelete, "/data/"+path.String(), yangData, nil)
+		// The node may already be gone along with the case of a choice
+		// replaced by the merge.
+		var restErr *RestconfError
+		if errors.As(err, &restErr) && restErr.Status == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, path := range patch.Reset {
+		body, err := native.EncodeJSON(path.Module(), path.Last())
+		if err != nil {
+			return err
+		}
+		_, err = r.do(http.MethodPut, "/data/"+path.String(), yangData, body)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// yangPatch reports whether the server accepts YANG-Patch edits, reading its
+// capabilities once. A server without the RESTCONF monitoring state is
+// assumed not to.
+func (r *restconf) yangPatch() (bool, error) {
+	if r.atomic != nil {
+		return *r.atomic, nil
+	}
+	data, err := r.do(http.MethodGet, "/data/ietf-restconf-monitoring:restconf-state/capabilities", yangData, nil)
+	var restErr *RestconfError
+	if errors.As(err, &restErr) && restErr.Status == http.StatusNotFound {
+		data, err = nil, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read the capabilities of the restconf server: %w", err)
+	}
+	var body struct {
+		Capabilities struct {
+			Capability []string `json:"capability"`
+		} `json:"ietf-restconf-monitoring:capabilities"`
+	}
+	atomic := false
+	if json.Unmarshal(data, &body) == nil {
+		for _, capability := range body.Capabilities.Capability {
+			atomic = atomic || capability == yangPatchCapability
+		}
+	}
+	r.atomic = &atomic
+	return atomic, nil
+}
+
+// yangPatchEdit is an edit of a YANG-Patch, its target relative to the native
+// root.
+type yangPatchEdit struct {
+	ID        string          `json:"edit-id"`
+	Operation string          `json:"operation"`
+	Target    string          `json:"target"`
+	Value     json.RawMessage `json:"value,omitempty"`
+}
+
+// encodeYangPatch encodes the patch as a YANG-Patch of the native root, the
+// merge first, then the deletions and the resets. A node already gone is
+// removed without error.
+func encodeYangPatch(patch native.Patch) ([]byte, error) {
+	var edits []yangPatchEdit
+	add := func(operation string, path native.Path, value []byte) {
+		target := "/"
+		if len(path) > 1 {
+			target += strings.TrimPrefix(path.String(), native.Path{path[0]}.String()+"/")
+		}
+		edits = append(edits, yangPatchEdit{
+			ID:        strconv.Itoa(len(edits) + 1),
+			Operation: operation,
+			Target:    target,
+			Value:     value,
+		})
+	}
+	if patch.Merge != nil {
+		value, err := native.EncodeJSON(native.Module, patch.Merge)
+		if err != nil {
+			return nil, err
+		}
+		add("merge", native.Path{patch.Merge}, value)
+	}
+	for _, path := range patch.Delete {
+		add("remove", path, nil)
+	}
+	for _, path := range patch.Reset {
+		value, err := native.EncodeJSON(path.Module(), path.Last())
+		if err != nil {
+			return nil, err
+		}
+		add("replace", path, value)
+	}
+	return json.Marshal(map[string]any{
+		"ietf-yang-patch:yang-patch": map[string]any{
+			"patch-id": "terraform",
+			"edit":     edits,
+		},
+	})
+}
+
+func (r *restconf) Save() (string, error) {
+	data, err := r.do(http.MethodPost, "/operations/cisco-ia:save-config", yangData, nil)
+	return string(data), err
+}
+
+func (r *restconf) Close() error {
+	r.client.CloseIdleConnections()
+	return nil
+}
+
+func (r *restconf) setTrace(trace func(cmd string, output string, err error)) {
+	r.trace = trace
+}
+
+// do sends a request to the resource at path, under the RESTCONF root, with a
+// body of the media type, and returns the body of the response. The bodies are
+// traced with their secrets redacted.
+func (r *restconf) do(method string, path string, media string, body []byte) ([]byte, error) {
+	data, err := r.send(method, path, media, body)
+	if r.trace != nil {
+		request := method + " " + path
+		if len(body) > 0 {
+			request += "\n" + redactJSON(body)
+		}
+		r.trace(request, redactJSON(data), err)
+	}
+	return data, err
+}
+
+func (r *restconf) send(method string, path string, media string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, r.base+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(r.username, r.password)
+	req.Header.Set("Accept", yangData)
+	if len(body) > 0 {
+		req.Header.Set("Content-Type", media)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return data, nil
+	}
+	return data, restconfError(resp.StatusCode, data)
+}
+
+// restconfErrors is the list of errors of a response.
+type restconfErrors struct {
+	Error []restconfErrorEntry `json:"error"`
+}
+
+type restconfErrorEntry struct {
+	Tag     string `json:"error-tag"`
+	Message string `json:"error-message"`
+	Path    string `json:"error-path"`
+}
+
+// restconfError decodes the first error of the ietf-restconf:errors body of a
+// response, or of the ietf-yang-patch:yang-patch-status body answering a
+// YANG-Patch.
+func restconfError(status int, data []byte) error {
+	restErr := &RestconfError{Status: status}
+	var body struct {
+		Errors      restconfErrors `json:"ietf-restconf:errors"`
+		PatchStatus struct {
+			Errors     restconfErrors `json:"errors"`
+			EditStatus struct {
+				Edit []struct {
+					Errors restconfErrors `json:"errors"`
+				} `json:"edit"`
+			} `json:"edit-status"`
+		} `json:"ietf-yang-patch:yang-patch-status"`
+	}
+	var errs []restconfErrorEntry
+	if json.Unmarshal(data, &body) == nil {
+		errs = append(body.Errors.Error, body.PatchStatus.Errors.Error...)
+		for _, edit := range body.PatchStatus.EditStatus.Edit {
+			errs = append(errs, edit.Errors.Error...)
+		}
+	}
+	if len(errs) > 0 {
+		first := errs[0]
+		restErr.Tag = first.Tag
+		restErr.Message = first.Message
+		restErr.Path = first.Path
+	} else {
+		restErr.Message = strings.TrimSpace(string(data))
+	}
+	return restErr
+}
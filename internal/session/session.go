@@ -0,0 +1,726 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/CorentinPtrl/cisconf"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Session wraps the devices shared by every resource and data source of a
+// provider instance. It keeps a parsed snapshot of the running-config so that
+// a refresh only pulls the configuration once, whatever the number of
+// resources in the state.
+//
+// Terraform runs operations in parallel, so every command sent on a device
+// channel is serialized, and resources hold the session with Lock for the
+// whole read-modify-write cycle of a change. Configuration is always pushed
+// through a single writer session, while read-only commands fan out across a
+// pool of extra sessions when more than one is allowed.
+//
+// No connection is opened until a command is actually sent, so that the
+// provider can be configured with values only known once other resources are
+// applied.
+//
+// A session dropped by the device is reopened on the next command. Reads are
+// retried, while a configuration push is only replayed through Apply, which
+// diffs it again against the running-config first.
+//
+// Every command sent is logged with tflog, and appended to the audit log when
+// one is set, on behalf of the resource the session was scoped to with With.
+type Session struct {
+	*shared
+	ctx      context.Context
+	resource string
+	id       string
+}
+
+// shared is the state of a session common to all its scopes.
+type shared struct {
+	dial          Dialer
+	store         Datastore
+	host          string
+	audit         *AuditLog
+	unknown       bool
+	writer        Conn
+	readers       chan Conn
+	slots         chan struct{}
+	config        *cisconf.Config
+	vlans         any
+	facts         any
+	factsLock     sync.Mutex
+	retryMax      int
+	retryInterval time.Duration
+	undoOnError   bool
+	rollback      bool
+	commitTimeout int
+	save          SaveMode
+	dirty         bool
+	// pending counts the read-modify-write cycles holding or waiting for
+	// the session.
+	pending int
+	lock    sync.Mutex
+	cli     sync.Mutex
+	tx      sync.Mutex
+}
+
+var (
+	ErrUnknownConfig = errors.New("the provider configuration is not known yet")
+	ErrDropped       = errors.New("the session was dropped by the device")
+)
+
+// Options tunes the sessions opened to a device.
+type Options struct {
+	// MaxSessions is the number of connections opened at most: one writer,
+	// and MaxSessions-1 readers opened on demand. With a single session,
+	// reads share the writer.
+	MaxSessions int
+	// RetryMax is the number of times a dropped command is retried.
+	RetryMax int
+	// RetryInterval is the wait before the first retry, doubled on each
+	// following attempt.
+	RetryInterval time.Duration
+	// UndoOnError reverts the lines already applied when the device rejects
+	// a line of a configuration push, from a diff of the running-config
+	// read before the push.
+	UndoOnError bool
+	// RollbackOnError snapshots the running-config before each change, and
+	// replaces the configuration with the snapshot when a line is rejected or
+	// the change is missing once applied.
+	RollbackOnError bool
+	// CommitConfirmTimeout is the number of minutes after which the device
+	// reverts a change the provider could not confirm, 0 to disable.
+	CommitConfirmTimeout int
+	// SaveConfig is when the running-config is copied to the startup-config.
+	SaveConfig SaveMode
+	// Host names the device in the logs.
+	Host string
+	// AuditLog records every command sent to the device, nil to disable.
+	AuditLog *AuditLog
+}
+
+// SaveMode tells when changes are persisted to the startup-config.
+type SaveMode string
+
+const (
+	// SaveNever leaves the changes in the running-config only.
+	SaveNever SaveMode = "never"
+	// SaveAfterEachChange saves the configuration after every change.
+	SaveAfterEachChange SaveMode = "after_each_change"
+	// SaveEndOfApply saves the configuration once the changes applied
+	// together are over: a change only saves when no other cycle holds or
+	// waits for the session. Resources Terraform applies one after the other,
+	// as they depend on each other, are each saved.
+	SaveEndOfApply SaveMode = "end_of_apply"
+)
+
+// sessions lists the sessions opened by the process, to be closed by
+// Shutdown.
+var sessions struct {
+	sync.Mutex
+	list []*Session
+}
+
+// rollbackFile is where the running-config is saved before a change.
+const rollbackFile = "flash:terraform-rollback.cfg"
+
+// Dialer opens a new connection to the device.
+type Dialer func() (Conn, error)
+
+// New creates a session to the device reached through dial.
+func New(dial Dialer, options Options) *Session {
+	s := &Session{shared: &shared{
+		dial:          dial,
+		host:          options.Host,
+		audit:         options.AuditLog,
+		retryMax:      options.RetryMax,
+		retryInterval: options.RetryInterval,
+		undoOnError:   options.UndoOnError,
+		rollback:      options.RollbackOnError,
+		commitTimeout: options.CommitConfirmTimeout,
+		save:          options.SaveConfig,
+	}}
+	if options.MaxSessions > 1 {
+		s.readers = make(chan Conn, options.MaxSessions-1)
+		s.slots = make(chan struct{}, options.MaxSessions-1)
+		for i := 0; i < options.MaxSessions-1; i++ {
+			s.slots <- struct{}{}
+		}
+	}
+	sessions.Lock()
+	sessions.list = append(sessions.list, s)
+	sessions.Unlock()
+	return s
+}
+
+// Unknown creates a session for a provider whose configuration depends on
+// values not known yet. Every command fails with ErrUnknownConfig.
+func Unknown() *Session {
+	return &Session{shared: &shared{
+		unknown: true,
+		dial: func() (Conn, error) {
+			return nil, ErrUnknownConfig
+		},
+	}}
+}
+
+// With returns the session scoped to ctx, the logs of the commands it sends
+// are attributed to the resource type and the id of the object managed.
+func (s *Session) With(ctx context.Context, resource string, id string) *Session {
+	return &Session{
+		shared:   s.shared,
+		ctx:      ctx,
+		resource: resource,
+		id:       id,
+	}
+}
+
+// IsUnknown reports whether the provider configuration is not known yet.
+func (s *Session) IsUnknown() bool {
+	return s.unknown
+}
+
+// connect opens the writer session on first use, it must be called with the
+// cli lock held.
+func (s *Session) connect() error {
+	if s.writer != nil {
+		return nil
+	}
+	device, err := s.dial()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the device: %w", err)
+	}
+	s.writer = device
+	return nil
+}
+
+// acquire returns an idle reader, opening a new one while the pool is not
+// full, or waits for one to be released.
+func (s *Session) acquire() (Conn, error) {
+	select {
+	case device := <-s.readers:
+		return device, nil
+	default:
+	}
+
+	select {
+	case device := <-s.readers:
+		return device, nil
+	case <-s.slots:
+		device, err := s.dial()
+		if err != nil {
+			s.slots <- struct{}{}
+			return nil, fmt.Errorf("failed to connect to the device: %w", err)
+		}
+		return device, nil
+	}
+}
+
+// release returns the reader to the pool, or closes it and frees its slot
+// when the device dropped it.
+func (s *Session) release(device Conn, err error) {
+	if isDropped(err) {
+		device.Close()
+		s.slots <- struct{}{}
+		return
+	}
+	s.readers <- device
+}
+
+// drop closes the writer so that the next command reconnects, it must be
+// called with the cli lock held.
+func (s *Session) drop() {
+	if s.writer != nil {
+		s.writer.Close()
+		s.writer = nil
+	}
+}
+
+// retry runs op again while the connection to the device is dropped, waiting
+// longer before each attempt, until the context of the session is done.
+func (s *Session) retry(op func() error) error {
+	interval := s.retryInterval
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil || !isDropped(err) || attempt >= s.retryMax {
+			return err
+		}
+		if waitErr := s.wait(interval); waitErr != nil {
+			return fmt.Errorf("%w, retrying was cancelled: %w", err, waitErr)
+		}
+		interval *= 2
+	}
+}
+
+// wait pauses for d before a retry, or returns the error of the context of
+// the session when it is done first.
+func (s *Session) wait(d time.Duration) error {
+	ctx := s.context()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isDropped reports whether err means the connection to the device is lost or
+// left in an unknown state, as opposed to an error reported by the device.
+func isDropped(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrClosed) || errors.Is(err, ErrNoPrompt) || errors.Is(err, io.EOF) ||
+		errors.Is(err, net.ErrClosed) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// Lock reserves the session for a read-modify-write cycle. Reads from other
+// operations can still run, but no other cycle can interleave its changes
+// until Unlock is called.
+func (s *Session) Lock() {
+	s.lock.Lock()
+	s.pending++
+	s.lock.Unlock()
+	s.tx.Lock()
+}
+
+// Unlock releases the session reserved by Lock. In SaveEndOfApply mode, the
+// last cycle saves the changes the cycles before it left unsaved when it made
+// no change itself, and returns the error of the save.
+func (s *Session) Unlock() error {
+	s.lock.Lock()
+	s.pending--
+	unsaved := s.pending == 0 && s.dirty
+	s.lock.Unlock()
+	var err error
+	if unsaved {
+		_, err = s.Save()
+		if err != nil {
+			err = fmt.Errorf("the changes applied before were not saved to the startup-config: %w", err)
+		}
+	}
+	s.tx.Unlock()
+	return err
+}
+
+// Exec runs a read-only command on the device and returns its raw output.
+// The command is retried on a new connection when the device drops it.
+func (s *Session) Exec(cmd ...string) (string, error) {
+	var output string
+	err := s.retry(func() error {
+		var err error
+		output, err = s.exec(cmd...)
+		return err
+	})
+	return output, err
+}
+
+func (s *Session) exec(cmd ...string) (string, error) {
+	if s.store != nil {
+		return "", unsupported(s.store, fmt.Sprintf("%q", strings.Join(cmd, "\n")))
+	}
+	if s.readers == nil {
+		s.cli.Lock()
+		defer s.cli.Unlock()
+		err := s.connect()
+		if err != nil {
+			return "", err
+		}
+		output, err := s.traced(s.writer).Exec(cmd...)
+		if isDropped(err) {
+			s.drop()
+		}
+		return output, err
+	}
+
+	device, err := s.acquire()
+	if err != nil {
+		return "", err
+	}
+	output, err := s.traced(device).Exec(cmd...)
+	s.release(device, err)
+	return output, err
+}
+
+// Configure pushes the commands in configuration mode through the writer
+// session and drops the cached running-config, even on failure since some
+// lines may have been applied. The push is never replayed, when the device
+// drops the session midway the error wraps ErrDropped. A line rejected by the
+// device stops the push with a CommandError, after reverting the lines before
+// it when UndoOnError is set.
+func (s *Session) Configure(cmds []string) error {
+	return s.change(func() error {
+		return s.configure(cmds)
+	}, nil)
+}
+
+// Apply pushes the commands returned by diff, which compares the desired
+// configuration with the running-config. When the session is dropped during
+// the push, diff runs again against a fresh running-config so that only the
+// changes still missing are replayed.
+func (s *Session) Apply(diff func() ([]string, error)) error {
+	return s.change(func() error {
+		return s.apply(diff)
+	}, func() error {
+		return s.verify(diff)
+	})
+}
+
+// Save copies the running-config to the startup-config and returns the
+// output of the device.
+func (s *Session) Save() (string, error) {
+	var output string
+	var err error
+	if s.store != nil {
+		s.cli.Lock()
+		output, err = s.tracedStore().Save()
+		s.cli.Unlock()
+	} else {
+		output, err = s.confirm("copy running-config startup-config")
+	}
+	if err != nil {
+		return output, err
+	}
+	s.lock.Lock()
+	s.dirty = false
+	s.lock.Unlock()
+	return output, nil
+}
+
+// Shutdown closes every connection opened by the process.
+func Shutdown() {
+	sessions.Lock()
+	defer sessions.Unlock()
+	for _, s := range sessions.list {
+		s.close()
+		if s.audit != nil {
+			s.audit.Close()
+		}
+	}
+	sessions.list = nil
+}
+
+// close closes the writer, the idle readers and the datastore.
+func (s *Session) close() {
+	s.cli.Lock()
+	s.drop()
+	if s.store != nil {
+		s.store.Close()
+	}
+	s.cli.Unlock()
+	if s.readers == nil {
+		return
+	}
+	for {
+		select {
+		case device := <-s.readers:
+			device.Close()
+		default:
+			return
+		}
+	}
+}
+
+// change runs push as a transaction, then saves the configuration according
+// to the save mode.
+func (s *Session) change(push func() error, verify func() error) error {
+	err := s.transaction(push, verify)
+	if err != nil {
+		return err
+	}
+	switch s.save {
+	case SaveAfterEachChange:
+		_, err = s.Save()
+		if err != nil {
+			return fmt.Errorf("the change was applied but saving it to the startup-config failed: %w", err)
+		}
+	case SaveEndOfApply:
+		s.lock.Lock()
+		s.dirty = true
+		last := s.pending <= 1
+		s.lock.Unlock()
+		if !last {
+			return nil
+		}
+		_, err = s.Save()
+		if err != nil {
+			return fmt.Errorf("the changes were applied but saving them to the startup-config failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// transaction runs push guarded by the safety nets of the session.
+//
+// With RollbackOnError, the running-config is saved to flash first, and
+// restored with configure replace when push fails or verify, when given,
+// finds the change missing afterwards.
+//
+// With CommitConfirmTimeout, a configure replace timer is armed before the
+// push, so that the device restores its configuration on its own if the
+// change locks the provider out. The timer is only cancelled once a new
+// session can be opened after the change.
+func (s *Session) transaction(push func() error, verify func() error) error {
+	if !s.rollback && s.commitTimeout == 0 {
+		return push()
+	}
+
+	_, err := s.confirm("copy running-config " + rollbackFile)
+	if err != nil {
+		return fmt.Errorf("failed to save the running-config before the change: %w", err)
+	}
+	defer func() {
+		_, err := s.confirm("delete /force " + rollbackFile)
+		if err != nil {
+			tflog.Warn(s.context(), "Failed to delete the rollback snapshot from the device", map[string]interface{}{
+				"file":  rollbackFile,
+				"error": Redact(err.Error()),
+			})
+		}
+	}()
+
+	if s.commitTimeout > 0 {
+		_, err = s.confirm(fmt.Sprintf("configure replace %s force time %d", rollbackFile, s.commitTimeout))
+		if err != nil {
+			return fmt.Errorf("failed to arm the commit confirm timer: %w", err)
+		}
+	}
+
+	err = push()
+	if err == nil && s.commitTimeout > 0 {
+		err = s.reconnect()
+		if err != nil {
+			return fmt.Errorf("failed to reconnect after the change, the device reverts it in %d minutes: %w", s.commitTimeout, err)
+		}
+	}
+	if err == nil && s.rollback && verify != nil {
+		err = verify()
+	}
+
+	if s.commitTimeout > 0 {
+		_, confirmErr := s.confirm("configure confirm")
+		if confirmErr != nil {
+			return errors.Join(err, fmt.Errorf("failed to confirm the change, the device reverts it in %d minutes: %w", s.commitTimeout, confirmErr))
+		}
+	}
+	if err != nil && s.rollback {
+		err = s.restore(err)
+	}
+	return err
+}
+
+// restore replaces the running-config with the snapshot taken before a change
+// that failed with err.
+func (s *Session) restore(err error) error {
+	defer s.Invalidate()
+	_, replaceErr := s.confirm("configure replace " + rollbackFile + " force")
+	if replaceErr != nil {
+		return fmt.Errorf("%w, rolling back the running-config failed: %w", err, replaceErr)
+	}
+	return fmt.Errorf("%w, the running-config was rolled back", err)
+}
+
+// verify checks that diff finds nothing left to change once a change is
+// applied.
+func (s *Session) verify(diff func() ([]string, error)) error {
+	cmds, err := diff()
+	if err != nil {
+		return fmt.Errorf("failed to read back the change: %w", err)
+	}
+	var missing []string
+	for _, cmd := range cmds {
+		cmd = strings.TrimSpace(cmd)
+		if cmd != "" && cmd != "!" {
+			missing = append(missing, cmd)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("the running-config does not match the change once applied, still differing: %s", strings.Join(missing, "; "))
+	}
+	return nil
+}
+
+// confirm runs a command answering its questions through the writer session.
+func (s *Session) confirm(cmd string) (string, error) {
+	if s.store != nil {
+		return "", unsupported(s.store, fmt.Sprintf("%q", cmd))
+	}
+	s.cli.Lock()
+	defer s.cli.Unlock()
+	err := s.retry(s.connect)
+	if err != nil {
+		return "", err
+	}
+	output, err := s.traced(s.writer).Confirm(cmd)
+	if isDropped(err) {
+		s.drop()
+	}
+	return output, err
+}
+
+// reconnect replaces the writer with a new session, proving that the device
+// still accepts the provider after a change.
+func (s *Session) reconnect() error {
+	s.cli.Lock()
+	defer s.cli.Unlock()
+	s.drop()
+	return s.retry(s.connect)
+}
+
+func (s *Session) apply(diff func() ([]string, error)) error {
+	interval := s.retryInterval
+	for attempt := 0; ; attempt++ {
+		cmds, err := diff()
+		if err != nil {
+			return err
+		}
+		err = s.configure(cmds)
+		if err == nil || !errors.Is(err, ErrDropped) || attempt >= s.retryMax {
+			return err
+		}
+		if waitErr := s.wait(interval); waitErr != nil {
+			return fmt.Errorf("%w, retrying was cancelled: %w", err, waitErr)
+		}
+		interval *= 2
+	}
+}
+
+func (s *Session) configure(cmds []string) error {
+	if s.store != nil {
+		return unsupported(s.store, "configuration commands")
+	}
+	defer s.Invalidate()
+	s.cli.Lock()
+	defer s.cli.Unlock()
+	err := s.retry(s.connect)
+	if err != nil {
+		return err
+	}
+	var before string
+	if s.undoOnError {
+		before, err = s.traced(s.writer).Exec("show running-config")
+		if err != nil {
+			if isDropped(err) {
+				s.drop()
+			}
+			return fmt.Errorf("failed to read the running-config before the change: %w", err)
+		}
+	}
+	err = s.traced(s.writer).Configure(cmds)
+	if isDropped(err) {
+		s.drop()
+		return fmt.Errorf("%w, some commands may have been applied: %w", ErrDropped, err)
+	}
+	var cmdErr *CommandError
+	if s.undoOnError && errors.As(err, &cmdErr) && cmdErr.Line > 1 {
+		undoErr := s.undo(before, cmds[:cmdErr.Line-1])
+		if undoErr != nil {
+			return fmt.Errorf("%w, reverting the applied lines failed: %w", err, undoErr)
+		}
+		return fmt.Errorf("%w, the applied lines were reverted", err)
+	}
+	return err
+}
+
+// undo reverts the applied lines of a rejected push, comparing the
+// running-config before the push with the one left by the rejected line.
+func (s *Session) undo(before string, applied []string) error {
+	after, err := s.traced(s.writer).Exec("show running-config")
+	if err != nil {
+		return err
+	}
+	cmds := undo(before, after, applied)
+	if len(cmds) == 0 {
+		return nil
+	}
+	return s.traced(s.writer).Configure(cmds)
+}
+
+// RunningConfig returns the parsed running-config of the device, fetching it
+// only when no snapshot is cached. The returned config is shared and must not
+// be modified.
+func (s *Session) RunningConfig() (*cisconf.Config, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.config != nil {
+		return s.config, nil
+	}
+	if s.store != nil {
+		config, err := s.storeConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the running config: %w", err)
+		}
+		s.config = config
+		return s.config, nil
+	}
+
+	config, err := s.Exec("sh running-config")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute running config: %w", err)
+	}
+	runningConfig := cisconf.Config{}
+	err = cisconf.Unmarshal(config, &runningConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal running config: %w", err)
+	}
+	s.config = &runningConfig
+	return s.config, nil
+}
+
+// Vlans returns the vlans of the device returned by read, which parses show
+// vlan. Like the running-config, they are cached until the next change.
+func (s *Session) Vlans(read func() (any, error)) (any, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.vlans != nil {
+		return s.vlans, nil
+	}
+	vlans, err := read()
+	if err != nil {
+		return nil, err
+	}
+	s.vlans = vlans
+	return s.vlans, nil
+}
+
+// Facts returns the facts of the device returned by gather, which only runs
+// until it succeeds once. Unlike the running-config, the facts of a device,
+// such as its platform, do not change while the provider runs.
+func (s *Session) Facts(gather func() (any, error)) (any, error) {
+	s.factsLock.Lock()
+	defer s.factsLock.Unlock()
+	if s.facts != nil {
+		return s.facts, nil
+	}
+	facts, err := gather()
+	if err != nil {
+		return nil, err
+	}
+	s.facts = facts
+	return s.facts, nil
+}
+
+// Invalidate drops the cached running-config and vlans so the next read
+// fetches them again from the device.
+func (s *Session) Invalidate() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.config = nil
+	s.vlans = nil
+}
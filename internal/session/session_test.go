@@ -0,0 +1,556 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package session
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+	"terraform-provider-ios/internal/fakeios"
+)
+
+// newTestSession starts a device with the default configuration and opens a
+// session to it with options.
+func newTestSession(t *testing.T, options Options) (*Session, *fakeios.Server) {
+	t.Helper()
+	device := fakeios.New("")
+	device.EnableSecret = "enable"
+	server, err := fakeios.Serve(device, "admin", "cisco")
+	if err != nil {
+		t.Fatalf("failed to start the device: %s", err)
+	}
+	s := New(dialTest(server, nil), options)
+	t.Cleanup(func() {
+		s.close()
+		server.Close()
+	})
+	return s, server
+}
+
+// dialTest returns a dialer opening sessions to server, counting them in
+// dials when not nil.
+func dialTest(server *fakeios.Server, dials *atomic.Int32) Dialer {
+	return func() (Conn, error) {
+		if dials != nil {
+			dials.Add(1)
+		}
+		return DialSSH(SSHConfig{
+			Host:           server.Host(),
+			Port:           server.Port(),
+			Username:       "admin",
+			Password:       "cisco",
+			EnablePassword: "enable",
+			// The key of the device is checked by TestSSHHostKey.
+			InsecureIgnoreHostKey: true,
+		})
+	}
+}
+
+func TestSessionConfigure(t *testing.T) {
+	s, server := newTestSession(t, Options{})
+
+	err := s.Configure([]string{"vlan 10", " name users", "!", "ip route 10.0.0.0 255.0.0.0 192.168.0.254"})
+	if err != nil {
+		t.Fatalf("Configure() error = %s", err)
+	}
+	config := server.Device.RunningConfig()
+	for _, want := range []string{"vlan 10\n name users\n", "ip route 10.0.0.0 255.0.0.0 192.168.0.254\n"} {
+		if !strings.Contains(config, want) {
+			t.Errorf("running-config is missing %q:\n%s", want, config)
+		}
+	}
+
+	runningConfig, err := s.RunningConfig()
+	if err != nil {
+		t.Fatalf("RunningConfig() error = %s", err)
+	}
+	if len(runningConfig.Vlans) != 1 || runningConfig.Vlans[0].Name != "users" {
+		t.Errorf("RunningConfig() vlans = %+v, want vlan 10 users", runningConfig.Vlans)
+	}
+}
+
+func TestSessionConcurrent(t *testing.T) {
+	s, server := newTestSession(t, Options{})
+
+	// Reads and changes interleaved on the single channel each get their own
+	// output back.
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			output, err := s.Exec("show privilege")
+			if err == nil && output != "Current privilege level is 15" {
+				err = fmt.Errorf("show privilege = %q", output)
+			}
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			s.Lock()
+			defer s.Unlock()
+			errs <- s.Configure([]string{fmt.Sprintf("vlan %d", 100+i)})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	config := server.Device.RunningConfig()
+	for i := 0; i < 10; i++ {
+		if !strings.Contains(config, fmt.Sprintf("vlan %d\n", 100+i)) {
+			t.Errorf("running-config is missing vlan %d:\n%s", 100+i, config)
+		}
+	}
+}
+
+func TestSessionPool(t *testing.T) {
+	device := fakeios.New("")
+	device.EnableSecret = "enable"
+	server, err := fakeios.Serve(device, "admin", "cisco")
+	if err != nil {
+		t.Fatalf("failed to start the device: %s", err)
+	}
+	var dials atomic.Int32
+	s := New(dialTest(server, &dials), Options{MaxSessions: 3})
+	t.Cleanup(func() {
+		s.close()
+		server.Close()
+	})
+
+	// The reads fan out over two readers at most, the writer is only opened
+	// by the change.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			output, err := s.Exec("show privilege")
+			if err != nil || output != "Current privilege level is 15" {
+				t.Errorf("Exec() = %q, %v", output, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := dials.Load(); n < 1 || n > 2 {
+		t.Errorf("the reads opened %d sessions, want 1 or 2 readers", n)
+	}
+	readers := dials.Load()
+
+	err = s.Configure([]string{"vlan 10"})
+	if err != nil {
+		t.Fatalf("Configure() error = %s", err)
+	}
+	if n := dials.Load(); n != readers+1 {
+		t.Errorf("the change opened %d sessions, want the writer only", n-readers)
+	}
+}
+
+func TestSessionLazy(t *testing.T) {
+	server, err := fakeios.Serve(fakeios.New(""), "admin", "cisco")
+	if err != nil {
+		t.Fatalf("failed to start the device: %s", err)
+	}
+	var dials atomic.Int32
+	s := New(dialTest(server, &dials), Options{})
+	t.Cleanup(func() {
+		s.close()
+		server.Close()
+	})
+	if n := dials.Load(); n != 0 {
+		t.Fatalf("New() opened %d sessions, want none before the first command", n)
+	}
+	_, err = s.RunningConfig()
+	if err != nil {
+		t.Fatalf("RunningConfig() error = %s", err)
+	}
+	if n := dials.Load(); n != 1 {
+		t.Errorf("the first command opened %d sessions, want 1", n)
+	}
+
+	unknown := Unknown()
+	if !unknown.IsUnknown() {
+		t.Errorf("IsUnknown() = false for a session of an unknown configuration")
+	}
+	_, err = unknown.Exec("show vlan")
+	if !errors.Is(err, ErrUnknownConfig) {
+		t.Errorf("Exec() error = %v, want ErrUnknownConfig", err)
+	}
+	err = unknown.Configure([]string{"vlan 10"})
+	if !errors.Is(err, ErrUnknownConfig) {
+		t.Errorf("Configure() error = %v, want ErrUnknownConfig", err)
+	}
+}
+
+func TestSessionConfigureRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		want    []string
+		lacks   []string
+	}{
+		{
+			name:  "kept",
+			want:  []string{"interface GigabitEthernet0/1\n description printer\n"},
+			lacks: []string{"switchport access vlan 30"},
+		},
+		{
+			name:    "undone",
+			options: Options{UndoOnError: true},
+			want:    []string{"interface GigabitEthernet0/1\n!\n"},
+			lacks:   []string{"description printer"},
+		},
+		{
+			name:    "rolled back",
+			options: Options{RollbackOnError: true},
+			want:    []string{"interface GigabitEthernet0/1\n!\n"},
+			lacks:   []string{"description printer"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, server := newTestSession(t, tt.options)
+			server.Device.Reject("switchport access vlan")
+
+			err := s.Apply(func() ([]string, error) {
+				return []string{"interface GigabitEthernet0/1", " description printer", " switchport access vlan 30", "!"}, nil
+			})
+			var cmdErr *CommandError
+			if !errors.As(err, &cmdErr) {
+				t.Fatalf("Apply() error = %v, want a CommandError", err)
+			}
+			if cmdErr.Line != 3 {
+				t.Errorf("CommandError.Line = %d, want 3", cmdErr.Line)
+			}
+
+			config := server.Device.RunningConfig()
+			for _, want := range tt.want {
+				if !strings.Contains(config, want) {
+					t.Errorf("running-config is missing %q:\n%s", want, config)
+				}
+			}
+			for _, lacks := range tt.lacks {
+				if strings.Contains(config, lacks) {
+					t.Errorf("running-config still holds %q:\n%s", lacks, config)
+				}
+			}
+		})
+	}
+}
+
+func TestSessionUndo(t *testing.T) {
+	s, server := newTestSession(t, Options{UndoOnError: true})
+	err := s.Configure([]string{"vlan 10", " name users"})
+	if err != nil {
+		t.Fatalf("Configure() error = %s", err)
+	}
+	server.Device.Reject("switchport access vlan")
+
+	// The vlan existed and the interface had an address, neither may be
+	// removed by reverting the push.
+	err = s.Configure([]string{"vlan 10", " name staff", "vlan 20", "interface GigabitEthernet0/0", " no ip address", " switchport access vlan 30"})
+	if err == nil || !strings.Contains(err.Error(), "the applied lines were reverted") {
+		t.Fatalf("Configure() error = %v, want the applied lines reverted", err)
+	}
+	config := server.Device.RunningConfig()
+	for _, want := range []string{"vlan 10\n name users\n", "interface GigabitEthernet0/0\n no switchport\n ip address 192.168.0.2 255.255.255.0\n"} {
+		if !strings.Contains(config, want) {
+			t.Errorf("running-config is missing %q:\n%s", want, config)
+		}
+	}
+	for _, lacks := range []string{"name staff", "vlan 20"} {
+		if strings.Contains(config, lacks) {
+			t.Errorf("running-config still holds %q:\n%s", lacks, config)
+		}
+	}
+}
+
+func TestSessionReplaceFailed(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		want    string
+		// pushed is whether the change reached the device.
+		pushed bool
+	}{
+		{
+			name:    "rollback",
+			options: Options{RollbackOnError: true},
+			want:    "rolling back the running-config failed",
+			pushed:  true,
+		},
+		{
+			name:    "commit confirm",
+			options: Options{CommitConfirmTimeout: 5},
+			want:    "failed to arm the commit confirm timer",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, server := newTestSession(t, tt.options)
+			server.Device.Reject("switchport access vlan")
+			server.Device.RejectWith("configure replace", "%Error opening flash:terraform-rollback.cfg (Permission denied)")
+
+			err := s.Apply(func() ([]string, error) {
+				return []string{"interface GigabitEthernet0/1", " description printer", " switchport access vlan 30", "!"}, nil
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Apply() error = %v, want %q", err, tt.want)
+			}
+			if !strings.Contains(err.Error(), "Permission denied") {
+				t.Errorf("Apply() error = %v, want the error of configure replace", err)
+			}
+			var cmdErr *CommandError
+			if tt.pushed && (!errors.As(err, &cmdErr) || cmdErr.Line != 3) {
+				t.Errorf("Apply() error = %v, want the rejected line 3 kept", err)
+			}
+			if pushed := strings.Contains(server.Device.RunningConfig(), "description printer"); pushed != tt.pushed {
+				t.Errorf("change reached the device = %t, want %t", pushed, tt.pushed)
+			}
+		})
+	}
+}
+
+func TestSessionRollbackCleanup(t *testing.T) {
+	s, server := newTestSession(t, Options{RollbackOnError: true})
+	server.Device.RejectWith("delete /force", "%Error deleting flash:terraform-rollback.cfg (Device or resource busy)")
+	var logs bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &logs)
+
+	// The change succeeded, the snapshot left on flash is only reported.
+	err := s.With(ctx, "ios_vlan", "10").Configure([]string{"vlan 10", " name users"})
+	if err != nil {
+		t.Fatalf("Configure() error = %s", err)
+	}
+	if !strings.Contains(server.Device.RunningConfig(), "vlan 10\n name users\n") {
+		t.Errorf("running-config is missing vlan 10:\n%s", server.Device.RunningConfig())
+	}
+	if !strings.Contains(logs.String(), "Failed to delete the rollback snapshot") || !strings.Contains(logs.String(), "Device or resource busy") {
+		t.Errorf("logs = %s, want the failed delete warned", logs.String())
+	}
+}
+
+func TestSessionRetry(t *testing.T) {
+	s, server := newTestSession(t, Options{RetryMax: 2, RetryInterval: 10 * time.Millisecond})
+
+	_, err := s.Exec("show vlan")
+	if err != nil {
+		t.Fatalf("Exec() error = %s", err)
+	}
+	server.Drop()
+	output, err := s.Exec("show vlan")
+	if err != nil {
+		t.Fatalf("Exec() after the connection dropped error = %s", err)
+	}
+	if !strings.Contains(output, "default") {
+		t.Errorf("Exec() = %q, want the vlans", output)
+	}
+}
+
+func TestSessionRetryCancelled(t *testing.T) {
+	s, server := newTestSession(t, Options{RetryMax: 3, RetryInterval: time.Hour})
+	server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := s.With(ctx, "ios_vlan", "10").Exec("show vlan")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Exec() error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Minute {
+		t.Errorf("Exec() returned after %s, want the retry to stop once cancelled", elapsed)
+	}
+}
+
+func TestSessionSave(t *testing.T) {
+	s, server := newTestSession(t, Options{SaveConfig: SaveEndOfApply})
+
+	s.Lock()
+	// A second change waits for the session, the first one leaves the save
+	// to it.
+	done := make(chan error)
+	go func() {
+		s.Lock()
+		defer s.Unlock()
+		done <- s.Configure([]string{"vlan 30"})
+	}()
+	for {
+		s.lock.Lock()
+		pending := s.pending
+		s.lock.Unlock()
+		if pending == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	err := s.Configure([]string{"vlan 20"})
+	if err != nil {
+		t.Fatalf("Configure() error = %s", err)
+	}
+	if server.Device.StartupConfig() != "" {
+		t.Fatalf("startup-config saved while another change waits")
+	}
+	s.Unlock()
+
+	err = <-done
+	if err != nil {
+		t.Fatalf("Configure() error = %s", err)
+	}
+	for _, vlan := range []string{"vlan 20\n", "vlan 30\n"} {
+		if !strings.Contains(server.Device.StartupConfig(), vlan) {
+			t.Errorf("startup-config is missing %s:\n%s", vlan, server.Device.StartupConfig())
+		}
+	}
+}
+
+func TestSessionSaveOnUnlock(t *testing.T) {
+	s, server := newTestSession(t, Options{SaveConfig: SaveEndOfApply})
+	server.Device.Reject("copy running-config startup-config")
+
+	s.Lock()
+	// The second cycle makes no change, it is left to save the change of
+	// the first one.
+	done := make(chan error)
+	go func() {
+		s.Lock()
+		done <- s.Unlock()
+	}()
+	for {
+		s.lock.Lock()
+		pending := s.pending
+		s.lock.Unlock()
+		if pending == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	err := s.Configure([]string{"vlan 20"})
+	if err != nil {
+		t.Fatalf("Configure() error = %s", err)
+	}
+	err = s.Unlock()
+	if err != nil {
+		t.Fatalf("Unlock() error = %s, want the save left to the second cycle", err)
+	}
+
+	err = <-done
+	if err == nil || !strings.Contains(err.Error(), "not saved to the startup-config") {
+		t.Errorf("Unlock() error = %v, want the failed save", err)
+	}
+	if server.Device.StartupConfig() != "" {
+		t.Errorf("startup-config saved while the device rejects the copy:\n%s", server.Device.StartupConfig())
+	}
+}
+
+func TestSessionFacts(t *testing.T) {
+	s, _ := newTestSession(t, Options{})
+
+	calls := 0
+	gather := func() (any, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("unreachable")
+		}
+		return s.Exec("show privilege")
+	}
+	if _, err := s.Facts(gather); err == nil {
+		t.Fatalf("Facts() error = nil, want the error of gather")
+	}
+	for i := 0; i < 2; i++ {
+		facts, err := s.Facts(gather)
+		if err != nil {
+			t.Fatalf("Facts() error = %s", err)
+		}
+		if facts != "Current privilege level is 15" {
+			t.Errorf("Facts() = %v, want the output of show privilege", facts)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("gather ran %d times, want 2 as only the failures are retried", calls)
+	}
+}
+
+// countingConn answers every command with an empty output and counts the
+// commands run.
+type countingConn struct {
+	mu   sync.Mutex
+	runs map[string]int
+}
+
+func (c *countingConn) count(cmd string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.runs[cmd]
+}
+
+func (c *countingConn) Exec(cmd ...string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.runs[strings.Join(cmd, "")]++
+	return "", nil
+}
+
+func (c *countingConn) Confirm(cmd string) (string, error) {
+	return c.Exec(cmd)
+}
+
+func (c *countingConn) Configure(cmds []string) error {
+	return nil
+}
+
+func (c *countingConn) Close() error {
+	return nil
+}
+
+func TestSessionCache(t *testing.T) {
+	conn := &countingConn{runs: map[string]int{}}
+	s := New(func() (Conn, error) {
+		return conn, nil
+	}, Options{})
+	t.Cleanup(s.close)
+
+	read := func() {
+		t.Helper()
+		for i := 0; i < 3; i++ {
+			if _, err := s.RunningConfig(); err != nil {
+				t.Fatalf("RunningConfig() error = %s", err)
+			}
+			if _, err := s.Vlans(func() (any, error) { return s.Exec("show vlan") }); err != nil {
+				t.Fatalf("Vlans() error = %s", err)
+			}
+		}
+	}
+	check := func(when string, want int) {
+		t.Helper()
+		for _, cmd := range []string{"sh running-config", "show vlan"} {
+			if n := conn.count(cmd); n != want {
+				t.Errorf("%s ran %d times %s, want %d", cmd, n, when, want)
+			}
+		}
+	}
+
+	read()
+	check("over several reads", 1)
+	if err := s.Configure([]string{"vlan 10"}); err != nil {
+		t.Fatalf("Configure() error = %s", err)
+	}
+	read()
+	check("after a change", 2)
+	s.Invalidate()
+	read()
+	check("after Invalidate", 3)
+}
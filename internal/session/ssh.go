@@ -0,0 +1,241 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package session
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+var (
+	ErrHostKeyMismatch  = errors.New("host key verification failed")
+	ErrHostKeyUnchecked = errors.New("the host key cannot be verified without a known_hosts file or a fingerprint")
+)
+
+// SSHConfig holds the parameters used to open an SSH session to a device.
+type SSHConfig struct {
+	Host               string
+	Port               string
+	Username           string
+	Password           string
+	EnablePassword     string
+	PrivateKey         []byte
+	Passphrase         string
+	KnownHostsFile     string
+	HostKeyFingerprint string
+	// InsecureIgnoreHostKey accepts any host key when neither KnownHostsFile
+	// nor HostKeyFingerprint is set, instead of failing the connection.
+	InsecureIgnoreHostKey bool
+	// Bastion is the jump host the connection is tunnelled through, nil to
+	// connect directly.
+	Bastion *SSHConfig
+}
+
+// closers closes a chain of connections, the device first then the bastion.
+type closers []io.Closer
+
+func (c closers) Close() error {
+	var err error
+	for _, closer := range c {
+		if cerr := closer.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// DialSSH opens an interactive shell on the device.
+func DialSSH(config SSHConfig) (Conn, error) {
+	client, closer, err := config.dial()
+	if err != nil {
+		return nil, err
+	}
+	return openShell(client, config.EnablePassword, closer)
+}
+
+// dial connects to the host, through the bastion when one is set, like the
+// direct-tcpip forwarding of Terraform connection blocks.
+func (config SSHConfig) dial() (*ssh.Client, closers, error) {
+	clientConfig, err := config.clientConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	if config.Port == "" {
+		config.Port = "22"
+	}
+	addr := net.JoinHostPort(config.Host, config.Port)
+
+	if config.Bastion == nil {
+		client, err := ssh.Dial("tcp", addr, clientConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		return client, closers{client}, nil
+	}
+
+	bastion, bastionClosers, err := config.Bastion.dial()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to bastion %s: %w", config.Bastion.Host, err)
+	}
+	conn, err := bastion.Dial("tcp", addr)
+	if err != nil {
+		bastionClosers.Close()
+		return nil, nil, fmt.Errorf("failed to reach %s through bastion %s: %w", addr, config.Bastion.Host, err)
+	}
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, addr, clientConfig)
+	if err != nil {
+		conn.Close()
+		bastionClosers.Close()
+		return nil, nil, err
+	}
+	client := ssh.NewClient(clientConn, chans, reqs)
+	return client, append(closers{client}, bastionClosers...), nil
+}
+
+func openShell(client *ssh.Client, enable string, closer io.Closer) (Conn, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          0,
+		ssh.OCRNL:         0,
+		ssh.TTY_OP_ISPEED: 38400,
+		ssh.TTY_OP_OSPEED: 38400,
+	}
+	err = session.RequestPty("vt100", 0, 2000, modes)
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+	err = session.Shell()
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+
+	c := newCli(stdin, stdout, closer)
+	err = c.start(enable)
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (config SSHConfig) clientConfig() (*ssh.ClientConfig, error) {
+	auth := []ssh.AuthMethod{}
+	if len(config.PrivateKey) > 0 {
+		var signer ssh.Signer
+		var err error
+		if config.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(config.PrivateKey, []byte(config.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(config.PrivateKey)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if config.Password != "" {
+		auth = append(auth, ssh.Password(config.Password))
+		auth = append(auth, ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+			answers := make([]string, len(questions))
+			for i := range questions {
+				answers[i] = config.Password
+			}
+			return answers, nil
+		}))
+	}
+
+	hostKeyCallback, err := config.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ssh.ClientConfig{
+		User:            config.Username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         10 * time.Second,
+	}, nil
+}
+
+// hostKeyCallback checks the key presented by the device against the pinned
+// fingerprint and the known_hosts file. Without any of them the connection
+// fails with ErrHostKeyUnchecked, unless InsecureIgnoreHostKey is set.
+func (config SSHConfig) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	var callbacks []ssh.HostKeyCallback
+	if config.HostKeyFingerprint != "" {
+		callbacks = append(callbacks, fingerprintCallback(config.HostKeyFingerprint))
+	}
+	if config.KnownHostsFile != "" {
+		callback, err := knownhosts.New(config.KnownHostsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read known hosts file %s: %w", config.KnownHostsFile, err)
+		}
+		callbacks = append(callbacks, func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			err := callback(hostname, remote, key)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrHostKeyMismatch, err)
+			}
+			return nil
+		})
+	}
+	if len(callbacks) == 0 && config.InsecureIgnoreHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	if len(callbacks) == 0 {
+		return nil, ErrHostKeyUnchecked
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		for _, callback := range callbacks {
+			err := callback(hostname, remote, key)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// fingerprintCallback accepts only the key matching fingerprint, given either
+// as SHA256:<base64> or as a legacy MD5 colon separated hex string.
+func fingerprintCallback(fingerprint string) ssh.HostKeyCallback {
+	legacy := !strings.HasPrefix(fingerprint, "SHA256:")
+	if legacy {
+		fingerprint = strings.TrimPrefix(strings.ToLower(fingerprint), "md5:")
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		actual := ssh.FingerprintSHA256(key)
+		if legacy {
+			actual = ssh.FingerprintLegacyMD5(key)
+		}
+		if subtle.ConstantTimeCompare([]byte(actual), []byte(fingerprint)) != 1 {
+			return fmt.Errorf("%w: %s presented %s %s, expected %s", ErrHostKeyMismatch, hostname, key.Type(), actual, fingerprint)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package session
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"terraform-provider-ios/internal/fakeios"
+)
+
+// newHostKey returns a new ed25519 public key.
+func newHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate a key: %s", err)
+	}
+	key, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatalf("failed to convert the key: %s", err)
+	}
+	return key
+}
+
+func TestFingerprintCallback(t *testing.T) {
+	key := newHostKey(t)
+	other := newHostKey(t)
+	md5 := ssh.FingerprintLegacyMD5(key)
+	tests := []struct {
+		name        string
+		fingerprint string
+		key         ssh.PublicKey
+		mismatch    bool
+	}{
+		{"sha256", ssh.FingerprintSHA256(key), key, false},
+		{"sha256 other key", ssh.FingerprintSHA256(key), other, true},
+		{"md5", md5, key, false},
+		{"md5 prefixed and upper case", "MD5:" + strings.ToUpper(md5), key, false},
+		{"md5 other key", md5, other, true},
+		{"sha256 of the wrong case", "SHA256:" + strings.ToLower(strings.TrimPrefix(ssh.FingerprintSHA256(key), "SHA256:")), key, true},
+	}
+	remote := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 22}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := fingerprintCallback(tt.fingerprint)("192.0.2.1:22", remote, tt.key)
+			if tt.mismatch && !errors.Is(err, ErrHostKeyMismatch) {
+				t.Errorf("callback error = %v, want ErrHostKeyMismatch", err)
+			}
+			if !tt.mismatch && err != nil {
+				t.Errorf("callback error = %s", err)
+			}
+		})
+	}
+}
+
+func TestSSHHostKey(t *testing.T) {
+	server, err := fakeios.Serve(fakeios.New(""), "admin", "cisco")
+	if err != nil {
+		t.Fatalf("failed to start the device: %s", err)
+	}
+	defer server.Close()
+
+	// knownHosts writes a known_hosts file holding key for the device.
+	knownHosts := func(key ssh.PublicKey) string {
+		address := knownhosts.Normalize(net.JoinHostPort(server.Host(), server.Port()))
+		file := filepath.Join(t.TempDir(), "known_hosts")
+		err := os.WriteFile(file, []byte(knownhosts.Line([]string{address}, key)+"\n"), 0o600)
+		if err != nil {
+			t.Fatalf("failed to write known_hosts: %s", err)
+		}
+		return file
+	}
+	tests := []struct {
+		name   string
+		config SSHConfig
+		err    error
+	}{
+		{"fingerprint", SSHConfig{HostKeyFingerprint: ssh.FingerprintSHA256(server.HostKey())}, nil},
+		{"fingerprint of another key", SSHConfig{HostKeyFingerprint: ssh.FingerprintSHA256(newHostKey(t))}, ErrHostKeyMismatch},
+		{"known_hosts", SSHConfig{KnownHostsFile: knownHosts(server.HostKey())}, nil},
+		{"known_hosts holding another key", SSHConfig{KnownHostsFile: knownHosts(newHostKey(t))}, ErrHostKeyMismatch},
+		{"known_hosts and fingerprint of another key", SSHConfig{KnownHostsFile: knownHosts(server.HostKey()), HostKeyFingerprint: ssh.FingerprintSHA256(newHostKey(t))}, ErrHostKeyMismatch},
+		{"unchecked", SSHConfig{}, ErrHostKeyUnchecked},
+		{"insecure", SSHConfig{InsecureIgnoreHostKey: true}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.Host, config.Port = server.Host(), server.Port()
+			config.Username, config.Password = "admin", "cisco"
+			conn, err := DialSSH(config)
+			if err == nil {
+				conn.Close()
+			}
+			if tt.err == nil && err != nil {
+				t.Errorf("DialSSH() error = %s", err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("DialSSH() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSSHBastion(t *testing.T) {
+	server, err := fakeios.Serve(fakeios.New(""), "admin", "cisco")
+	if err != nil {
+		t.Fatalf("failed to start the device: %s", err)
+	}
+	defer server.Close()
+	bastion, err := fakeios.ServeBastion("jump", "secret")
+	if err != nil {
+		t.Fatalf("failed to start the bastion: %s", err)
+	}
+	defer bastion.Close()
+
+	device := net.JoinHostPort(server.Host(), server.Port())
+	tests := []struct {
+		name    string
+		bastion SSHConfig
+		device  SSHConfig
+		err     error
+		// forwarded is whether the bastion opened the tunnel to the device.
+		forwarded bool
+	}{
+		{"both verified", SSHConfig{HostKeyFingerprint: ssh.FingerprintSHA256(bastion.HostKey())}, SSHConfig{HostKeyFingerprint: ssh.FingerprintSHA256(server.HostKey())}, nil, true},
+		{"bastion of another key", SSHConfig{HostKeyFingerprint: ssh.FingerprintSHA256(newHostKey(t))}, SSHConfig{HostKeyFingerprint: ssh.FingerprintSHA256(server.HostKey())}, ErrHostKeyMismatch, false},
+		{"device of another key", SSHConfig{HostKeyFingerprint: ssh.FingerprintSHA256(bastion.HostKey())}, SSHConfig{HostKeyFingerprint: ssh.FingerprintSHA256(newHostKey(t))}, ErrHostKeyMismatch, true},
+		// The bastion presents its own key, not the one of the device.
+		{"bastion checked against the device key", SSHConfig{HostKeyFingerprint: ssh.FingerprintSHA256(server.HostKey())}, SSHConfig{HostKeyFingerprint: ssh.FingerprintSHA256(server.HostKey())}, ErrHostKeyMismatch, false},
+		{"bastion unchecked", SSHConfig{}, SSHConfig{HostKeyFingerprint: ssh.FingerprintSHA256(server.HostKey())}, ErrHostKeyUnchecked, false},
+		{"device unchecked", SSHConfig{HostKeyFingerprint: ssh.FingerprintSHA256(bastion.HostKey())}, SSHConfig{}, ErrHostKeyUnchecked, false},
+		{"bastion insecure", SSHConfig{InsecureIgnoreHostKey: true}, SSHConfig{HostKeyFingerprint: ssh.FingerprintSHA256(server.HostKey())}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(bastion.Forwarded())
+			jump := tt.bastion
+			jump.Host, jump.Port = bastion.Host(), bastion.Port()
+			jump.Username, jump.Password = "jump", "secret"
+			config := tt.device
+			config.Host, config.Port = server.Host(), server.Port()
+			config.Username, config.Password = "admin", "cisco"
+			config.Bastion = &jump
+			conn, err := DialSSH(config)
+			if err == nil {
+				defer conn.Close()
+			}
+			if tt.err == nil && err != nil {
+				t.Fatalf("DialSSH() error = %s", err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("DialSSH() error = %v, want %v", err, tt.err)
+			}
+			forwarded := bastion.Forwarded()[before:]
+			if tt.forwarded && (len(forwarded) != 1 || forwarded[0] != device) {
+				t.Errorf("bastion forwarded to %v, want [%s]", forwarded, device)
+			}
+			if !tt.forwarded && len(forwarded) != 0 {
+				t.Errorf("bastion forwarded to %v, want nothing", forwarded)
+			}
+			if err != nil {
+				return
+			}
+			output, err := conn.Exec("show running-config")
+			if err != nil {
+				t.Fatalf("show running-config through the bastion failed: %s", err)
+			}
+			if !strings.Contains(output, "hostname") {
+				t.Errorf("show running-config through the bastion = %q", output)
+			}
+		})
+	}
+
+	t.Run("wrong bastion password", func(t *testing.T) {
+		config := SSHConfig{
+			Host: server.Host(), Port: server.Port(), Username: "admin", Password: "cisco",
+			HostKeyFingerprint: ssh.FingerprintSHA256(server.HostKey()),
+			Bastion: &SSHConfig{
+				Host: bastion.Host(), Port: bastion.Port(), Username: "jump", Password: "wrong",
+				HostKeyFingerprint: ssh.FingerprintSHA256(bastion.HostKey()),
+			},
+		}
+		conn, err := DialSSH(config)
+		if err == nil {
+			conn.Close()
+			t.Fatal("DialSSH() succeeded with the wrong bastion password")
+		}
+		if !strings.Contains(err.Error(), "failed to connect to bastion") {
+			t.Errorf("DialSSH() error = %s, want a bastion error", err)
+		}
+	})
+}
@@ -0,0 +1,179 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package session
+
+import (
+	"errors"
+	"net"
+	"regexp"
+	"time"
+)
+
+var ErrAuthFailed = errors.New("authentication failed")
+
+var (
+	usernamePrompt = regexp.MustCompile(`(?i)user ?name:\s*$`)
+	loginFailed    = regexp.MustCompile(`% (Authentication failed|Login invalid|Bad passwords)`)
+)
+
+const (
+	telnetIAC  = 255
+	telnetDONT = 254
+	telnetDO   = 253
+	telnetWONT = 252
+	telnetWILL = 251
+	telnetSB   = 250
+	telnetSE   = 240
+	telnetEcho = 1
+	telnetSGA  = 3
+)
+
+// TelnetConfig holds the parameters used to open a telnet session to a
+// device. Credentials travel in clear text.
+type TelnetConfig struct {
+	Host           string
+	Port           string
+	Username       string
+	Password       string
+	EnablePassword string
+}
+
+// DialTelnet opens a telnet session to the device and answers the login
+// prompts.
+func DialTelnet(config TelnetConfig) (Conn, error) {
+	if config.Port == "" {
+		config.Port = "23"
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(config.Host, config.Port), 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	telnet := &telnetConn{Conn: conn}
+
+	c := newCli(telnet, telnet, telnet)
+	c.newline = "\r\n"
+	err = c.login(config.Username, config.Password)
+	if err == nil {
+		err = c.setup(config.EnablePassword)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// login answers the username and password prompts until the device shows
+// its prompt.
+func (c *cli) login(username string, password string) error {
+	sent := false
+	for {
+		text, match, err := c.readUntil(loginFailed, usernamePrompt, passwordPrompt, anyPrompt)
+		if err != nil {
+			return err
+		}
+		switch match {
+		case 0:
+			return ErrAuthFailed
+		case 1:
+			if sent {
+				return ErrAuthFailed
+			}
+			err = c.write(username)
+		case 2:
+			if sent {
+				return ErrAuthFailed
+			}
+			sent = true
+			err = c.write(password)
+		case 3:
+			c.learnPrompt(text)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// telnetConn strips the telnet option negotiation from the stream, agreeing
+// only to the device echoing and suppressing go-ahead.
+type telnetConn struct {
+	net.Conn
+	state  int
+	option byte
+}
+
+const (
+	stateData = iota
+	stateIAC
+	stateOption
+	stateSub
+	stateSubIAC
+)
+
+func (t *telnetConn) Read(p []byte) (int, error) {
+	for {
+		n, err := t.Conn.Read(p)
+		if n == 0 {
+			return 0, err
+		}
+		data := p[:0]
+		for _, b := range p[:n] {
+			switch t.state {
+			case stateData:
+				if b == telnetIAC {
+					t.state = stateIAC
+					continue
+				}
+				data = append(data, b)
+			case stateIAC:
+				switch b {
+				case telnetIAC:
+					data = append(data, b)
+					t.state = stateData
+				case telnetDO, telnetDONT, telnetWILL, telnetWONT:
+					t.option = b
+					t.state = stateOption
+				case telnetSB:
+					t.state = stateSub
+				default:
+					t.state = stateData
+				}
+			case stateOption:
+				t.negotiate(t.option, b)
+				t.state = stateData
+			case stateSub:
+				if b == telnetIAC {
+					t.state = stateSubIAC
+				}
+			case stateSubIAC:
+				if b == telnetSE {
+					t.state = stateData
+				} else {
+					t.state = stateSub
+				}
+			}
+		}
+		if len(data) > 0 || err != nil {
+			return len(data), err
+		}
+	}
+}
+
+func (t *telnetConn) negotiate(verb byte, option byte) {
+	var reply byte
+	switch verb {
+	case telnetDO:
+		reply = telnetWONT
+	case telnetWILL:
+		reply = telnetDONT
+		if option == telnetEcho || option == telnetSGA {
+			reply = telnetDO
+		}
+	default:
+		return
+	}
+	_, _ = t.Conn.Write([]byte{telnetIAC, reply, option})
+}
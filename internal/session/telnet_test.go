@@ -0,0 +1,78 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package session
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"terraform-provider-ios/internal/fakeios"
+)
+
+func TestTelnet(t *testing.T) {
+	device := fakeios.New("")
+	device.EnableSecret = "enable"
+	server, err := fakeios.ServeTelnet(device, "admin", "cisco")
+	if err != nil {
+		t.Fatalf("failed to start the device: %s", err)
+	}
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		password string
+		err      error
+	}{
+		{"login", "cisco", nil},
+		{"wrong password", "wrong", ErrAuthFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := DialTelnet(TelnetConfig{
+				Host:           server.Host(),
+				Port:           server.Port(),
+				Username:       "admin",
+				Password:       tt.password,
+				EnablePassword: "enable",
+			})
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("DialTelnet() error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DialTelnet() error = %s", err)
+			}
+			defer conn.Close()
+
+			output, err := conn.Exec("show privilege")
+			if err != nil || output != "Current privilege level is 15" {
+				t.Errorf("show privilege = %q, %v, want level 15", output, err)
+			}
+			err = conn.Configure([]string{"vlan 10", " name users"})
+			if err != nil {
+				t.Fatalf("Configure() error = %s", err)
+			}
+			if config := device.RunningConfig(); !strings.Contains(config, "vlan 10\n name users\n") {
+				t.Errorf("running-config is missing vlan 10:\n%s", config)
+			}
+		})
+	}
+
+	t.Run("enable rejected", func(t *testing.T) {
+		_, err := DialTelnet(TelnetConfig{
+			Host:           server.Host(),
+			Port:           server.Port(),
+			Username:       "admin",
+			Password:       "cisco",
+			EnablePassword: "wrong",
+		})
+		var privErr *PrivilegeError
+		if !errors.As(err, &privErr) || privErr.Level != 1 {
+			t.Errorf("DialTelnet() error = %v, want a PrivilegeError at level 1", err)
+		}
+	})
+}
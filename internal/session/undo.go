@@ -0,0 +1,130 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package session
+
+import (
+	"slices"
+	"strings"
+)
+
+// entry is a global line of the running-config, with the lines of its
+// sub-mode when it is a section.
+type entry struct {
+	line     string
+	children []string
+}
+
+// undo returns the commands reverting the applied lines of a push, from the
+// running-config shown before the push and after the rejected line.
+//
+// Only the configuration the applied lines touched is compared: the sections
+// they entered, and the global lines sharing their first keyword with one of
+// them. A line the push added is negated, a line it removed or replaced is
+// configured again, and a section it created is removed as a whole. Lines
+// starting with no are never negated, the line they replaced is restored
+// instead. Within a section only the lines right under the section are
+// compared, deeper sub-modes like address families are left as they are.
+func undo(before string, after string, applied []string) []string {
+	var keys []string
+	for _, cmd := range applied {
+		trimmed := strings.TrimSpace(cmd)
+		if cmd != trimmed || trimmed == "" || trimmed == "!" {
+			continue
+		}
+		trimmed = strings.TrimPrefix(trimmed, "no ")
+		trimmed = strings.TrimPrefix(trimmed, "default ")
+		keys = append(keys, trimmed)
+	}
+	touched := func(line string) bool {
+		for _, key := range keys {
+			if line == key || firstWord(line) == firstWord(key) {
+				return true
+			}
+		}
+		return false
+	}
+
+	old, current := parseEntries(before), parseEntries(after)
+	var reverted []string
+	// The lines the push changed in the sections that still exist.
+	for _, e := range current {
+		if !slices.Contains(keys, e.line) {
+			continue
+		}
+		i := slices.IndexFunc(old, func(o entry) bool { return o.line == e.line })
+		if i < 0 {
+			continue
+		}
+		var lines []string
+		for j := len(e.children) - 1; j >= 0; j-- {
+			child := e.children[j]
+			if isDirect(child) && !slices.Contains(old[i].children, child) && !strings.HasPrefix(child, " no ") {
+				lines = append(lines, " no"+child)
+			}
+		}
+		for _, child := range old[i].children {
+			if isDirect(child) && !slices.Contains(e.children, child) {
+				lines = append(lines, child)
+			}
+		}
+		if len(lines) > 0 {
+			reverted = append(reverted, e.line)
+			reverted = append(reverted, lines...)
+			reverted = append(reverted, "exit")
+		}
+	}
+	// The global lines and sections the push added.
+	for i := len(current) - 1; i >= 0; i-- {
+		line := current[i].line
+		if !strings.HasPrefix(line, "no ") && slices.Contains(keys, line) && !containsEntry(old, line) {
+			reverted = append(reverted, "no "+line)
+		}
+	}
+	// The global lines and sections the push removed or replaced.
+	for _, e := range old {
+		if !touched(e.line) || containsEntry(current, e.line) {
+			continue
+		}
+		reverted = append(reverted, e.line)
+		if len(e.children) > 0 {
+			reverted = append(reverted, e.children...)
+			reverted = append(reverted, "exit")
+		}
+	}
+	return reverted
+}
+
+// parseEntries splits the output of show running-config in global entries.
+func parseEntries(config string) []entry {
+	var entries []entry
+	for _, line := range strings.Split(config, "\n") {
+		line = strings.TrimRight(line, " \r")
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "" || trimmed == "!" || trimmed == "end":
+		case strings.HasPrefix(line, "Building configuration"), strings.HasPrefix(line, "Current configuration"):
+		case line != trimmed && len(entries) > 0:
+			last := &entries[len(entries)-1]
+			last.children = append(last.children, line)
+		case line == trimmed:
+			entries = append(entries, entry{line: line})
+		}
+	}
+	return entries
+}
+
+// isDirect reports whether a line of a section is right under it rather than
+// in one of its sub-modes.
+func isDirect(child string) bool {
+	return strings.HasPrefix(child, " ") && !strings.HasPrefix(child, "  ")
+}
+
+func containsEntry(entries []entry, line string) bool {
+	return slices.ContainsFunc(entries, func(e entry) bool { return e.line == line })
+}
+
+func firstWord(line string) string {
+	word, _, _ := strings.Cut(line, " ")
+	return word
+}
@@ -0,0 +1,90 @@
+// Copyright (c) Corentin Pitrel
+// SPDX-License-Identifier: MIT
+
+package session
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestUndo(t *testing.T) {
+	const before = `Building configuration...
+
+Current configuration : 200 bytes
+!
+hostname Switch
+!
+vlan 10
+ name users
+!
+interface GigabitEthernet0/1
+ description printer
+ ip address 10.0.0.1 255.255.255.0
+!
+router eigrp 10
+ network 10.0.0.0
+ address-family ipv4
+  network 10.1.0.0
+!
+ip route 0.0.0.0 0.0.0.0 10.0.0.254
+end
+`
+	tests := []struct {
+		name    string
+		applied []string
+		after   string
+		want    []string
+	}{
+		{
+			name:    "existing section kept",
+			applied: []string{"vlan 10", " name staff"},
+			after:   "hostname Switch\nvlan 10\n name staff\ninterface GigabitEthernet0/1\n description printer\n ip address 10.0.0.1 255.255.255.0\nrouter eigrp 10\n network 10.0.0.0\n address-family ipv4\n  network 10.1.0.0\nip route 0.0.0.0 0.0.0.0 10.0.0.254\n",
+			want:    []string{"vlan 10", " no name staff", " name users", "exit"},
+		},
+		{
+			name:    "new section removed",
+			applied: []string{"vlan 20", " name guests"},
+			after:   before + "vlan 20\n name guests\n",
+			want:    []string{"no vlan 20"},
+		},
+		{
+			name:    "negated line restored",
+			applied: []string{"interface GigabitEthernet0/1", " no ip address"},
+			after:   "hostname Switch\nvlan 10\n name users\ninterface GigabitEthernet0/1\n description printer\n no ip address\nrouter eigrp 10\n network 10.0.0.0\n address-family ipv4\n  network 10.1.0.0\nip route 0.0.0.0 0.0.0.0 10.0.0.254\n",
+			want:    []string{"interface GigabitEthernet0/1", " ip address 10.0.0.1 255.255.255.0", "exit"},
+		},
+		{
+			name:    "removed section restored",
+			applied: []string{"no vlan 10"},
+			after:   "hostname Switch\ninterface GigabitEthernet0/1\n description printer\n ip address 10.0.0.1 255.255.255.0\nrouter eigrp 10\n network 10.0.0.0\n address-family ipv4\n  network 10.1.0.0\nip route 0.0.0.0 0.0.0.0 10.0.0.254\n",
+			want:    []string{"vlan 10", " name users", "exit"},
+		},
+		{
+			name:    "replaced global line restored",
+			applied: []string{"hostname Core", "ip route 10.0.0.0 255.0.0.0 10.0.0.254"},
+			after:   "hostname Core\nvlan 10\n name users\ninterface GigabitEthernet0/1\n description printer\n ip address 10.0.0.1 255.255.255.0\nrouter eigrp 10\n network 10.0.0.0\n address-family ipv4\n  network 10.1.0.0\nip route 0.0.0.0 0.0.0.0 10.0.0.254\nip route 10.0.0.0 255.0.0.0 10.0.0.254\n",
+			want:    []string{"no ip route 10.0.0.0 255.0.0.0 10.0.0.254", "no hostname Core", "hostname Switch"},
+		},
+		{
+			name:    "sub-mode left as is",
+			applied: []string{"router eigrp 10", " address-family ipv4", "  network 10.2.0.0"},
+			after:   "hostname Switch\nvlan 10\n name users\ninterface GigabitEthernet0/1\n description printer\n ip address 10.0.0.1 255.255.255.0\nrouter eigrp 10\n network 10.0.0.0\n address-family ipv4\n  network 10.1.0.0\n  network 10.2.0.0\nip route 0.0.0.0 0.0.0.0 10.0.0.254\n",
+			want:    nil,
+		},
+		{
+			name:    "nothing changed",
+			applied: []string{"vlan 10", " name users"},
+			after:   before,
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := undo(before, tt.after, tt.applied)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("undo() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
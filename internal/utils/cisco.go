@@ -4,17 +4,72 @@
 package utils
 
 import (
-	"github.com/CorentinPtrl/cgnet"
+	"github.com/CorentinPtrl/cisconf"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"reflect"
 	"strings"
+	"terraform-provider-ios/internal/session"
 )
 
-func ConfigDevice(marshal string, device *cgnet.Device) error {
-	lines := strings.Split(marshal, "\n")
-	configs := []string{}
-	configs = append(configs, lines...)
-	err := device.Configure(configs)
+// ConfigDevice turns the object read from the running-config into the desired
+// one, both returned by change with a nil src when the object does not exist
+// yet. The device is sent the commands of the difference, or the patch of its
+// datastore. change is called again when the device drops the session
+// midway, so a replay only sends what is still missing.
+func ConfigDevice(device *session.Session, change func() (src any, dest any, err error)) error {
+	if device.Structured() {
+		return device.Edit(change)
+	}
+	return device.Apply(func() ([]string, error) {
+		config, err := Commands(change)
+		if err != nil {
+			return nil, err
+		}
+		return strings.Split(config, "\n"), nil
+	})
+}
+
+// Commands returns the commands turning the src returned by change into dest,
+// the whole configuration of dest when src is nil.
+func Commands(change func() (src any, dest any, err error)) (string, error) {
+	src, dest, err := change()
 	if err != nil {
-		return err
+		return "", err
+	}
+	if src == nil {
+		return cisconf.Marshal(dest)
+	}
+	return Diff(src, dest)
+}
+
+// Diff returns the commands turning src into dest, none when they are already
+// equal since cisconf repeats the fields of dest even when nothing changed.
+func Diff(src any, dest any) (string, error) {
+	if reflect.DeepEqual(src, dest) {
+		return "", nil
+	}
+	return cisconf.Diff(src, dest)
+}
+
+// PlannedCommands lists the lines of a marshaled configuration, as shown in
+// the planned_commands attribute of the resources.
+func PlannedCommands(marshal string) types.List {
+	lines := []attr.Value{}
+	for _, line := range strings.Split(marshal, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, types.StringValue(line))
+		}
+	}
+	return types.ListValueMust(types.StringType, lines)
+}
+
+// AppliedCommands returns the planned_commands of the state once planned is
+// applied. The state cannot hold an unknown value, the commands not known
+// during plan are stored as none.
+func AppliedCommands(planned types.List) types.List {
+	if planned.IsUnknown() {
+		return PlannedCommands("")
 	}
-	return err
+	return planned
 }
@@ -7,9 +7,12 @@ import (
 	"context"
 	"flag"
 	"log"
+	"os"
 
 	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"terraform-provider-ios/internal/generate"
 	"terraform-provider-ios/internal/provider"
+	"terraform-provider-ios/internal/session"
 )
 
 var (
@@ -22,6 +25,16 @@ var (
 )
 
 func main() {
+	// The generate command writes the configuration adopting the objects of
+	// an existing device, see internal/generate.
+	if len(os.Args) > 1 && os.Args[1] == "generate" {
+		err := generate.Run(context.Background(), os.Args[2:], os.Stdout, os.Stderr)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		return
+	}
+
 	var debug bool
 
 	flag.BoolVar(&debug, "debug", false, "set to true to run the provider with support for debuggers like delve")
@@ -36,6 +49,7 @@ func main() {
 	}
 
 	err := providerserver.Serve(context.Background(), provider.New(version), opts)
+	session.Shutdown()
 
 	if err != nil {
 		log.Fatal(err.Error())